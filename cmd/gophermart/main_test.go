@@ -29,7 +29,7 @@ func TestGracefulShutdown(t *testing.T) {
 	err := os.Setenv(config.EnvKeyAddress, ":8080") //nolint:tenv
 	require.NoError(t, err)
 	origValue1 := os.Getenv(config.EnvKeyDatabaseURI)
-	err = os.Setenv(config.EnvKeyDatabaseURI, ":8080") //nolint:tenv
+	err = os.Setenv(config.EnvKeyDatabaseURI, "postgres://localhost:5432/gophermart") //nolint:tenv
 	require.NoError(t, err)
 	t.Cleanup(func() {
 		_ = os.Setenv(config.EnvKeyAddress, origValue)
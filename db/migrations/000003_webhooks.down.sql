@@ -0,0 +1,13 @@
+BEGIN TRANSACTION;
+
+DROP TABLE IF EXISTS webhook_dead_letters;
+
+DROP INDEX IF EXISTS idx_webhook_deliveries_webhook_id;
+
+DROP INDEX IF EXISTS idx_webhook_deliveries_due;
+
+DROP TABLE IF EXISTS webhook_deliveries;
+
+DROP TABLE IF EXISTS webhooks;
+
+COMMIT;
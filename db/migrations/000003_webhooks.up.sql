@@ -0,0 +1,48 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id         BIGSERIAL PRIMARY KEY,
+    username   VARCHAR(72)   NOT NULL,
+    url        VARCHAR(2048) NOT NULL,
+    secret     VARCHAR(64)   NOT NULL,
+    created_at TIMESTAMPTZ   NOT NULL DEFAULT now(),
+    UNIQUE (username, url)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id               BIGSERIAL PRIMARY KEY,
+    webhook_id       BIGINT      NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event            VARCHAR(64) NOT NULL,
+    payload          TEXT        NOT NULL,
+    status           VARCHAR(16) NOT NULL,
+    attempts         INTEGER     NOT NULL DEFAULT 0,
+    next_attempt_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_status_code INTEGER     NOT NULL DEFAULT 0,
+    last_error       TEXT        NOT NULL DEFAULT '',
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at     TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due
+    ON webhook_deliveries (next_attempt_at) WHERE status = 'PENDING';
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id
+    ON webhook_deliveries (webhook_id, id);
+
+CREATE TABLE IF NOT EXISTS webhook_dead_letters
+(
+    id               BIGSERIAL PRIMARY KEY,
+    delivery_id      BIGINT        NOT NULL,
+    webhook_id       BIGINT        NOT NULL,
+    url              VARCHAR(2048) NOT NULL,
+    event            VARCHAR(64)   NOT NULL,
+    payload          TEXT          NOT NULL,
+    attempts         INTEGER       NOT NULL,
+    last_status_code INTEGER       NOT NULL,
+    last_error       TEXT          NOT NULL,
+    failed_at        TIMESTAMPTZ   NOT NULL DEFAULT now()
+);
+
+COMMIT;
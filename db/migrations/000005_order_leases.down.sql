@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS idx_orders_pending;
+
+ALTER TABLE orders DROP COLUMN IF EXISTS poll_lease_until;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS poll_lease_until TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_orders_pending ON orders (uploaded_at) WHERE status IN ('NEW', 'PROCESSING');
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS idx_orders_next_check;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS next_check_at,
+    DROP COLUMN IF EXISTS attempts,
+    DROP COLUMN IF EXISTS last_error,
+    DROP COLUMN IF EXISTS needs_review;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS poll_lease_until TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_orders_pending ON orders (uploaded_at) WHERE status IN ('NEW', 'PROCESSING');
+
+COMMIT;
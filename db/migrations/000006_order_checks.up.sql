@@ -0,0 +1,16 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS next_check_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS attempts      INTEGER     NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_error    TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS needs_review  BOOLEAN     NOT NULL DEFAULT false;
+
+DROP INDEX IF EXISTS idx_orders_pending;
+
+ALTER TABLE orders DROP COLUMN IF EXISTS poll_lease_until;
+
+CREATE INDEX IF NOT EXISTS idx_orders_next_check ON orders (next_check_at)
+    WHERE status IN ('NEW', 'PROCESSING') AND NOT needs_review;
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN TRANSACTION;
+
+DROP TABLE IF EXISTS withdraw_refunds;
+
+DROP INDEX IF EXISTS idx_withdraws_pending;
+
+ALTER TABLE withdraws
+    DROP COLUMN IF EXISTS status,
+    DROP COLUMN IF EXISTS updated_at;
+
+COMMIT;
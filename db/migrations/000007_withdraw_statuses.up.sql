@@ -0,0 +1,24 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE withdraws
+    ADD COLUMN IF NOT EXISTS status     VARCHAR(10) NOT NULL DEFAULT 'COMPLETED',
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+
+ALTER TABLE withdraws ALTER COLUMN status SET DEFAULT 'PENDING';
+
+CREATE INDEX IF NOT EXISTS idx_withdraws_pending ON withdraws (processed_at) WHERE status = 'PENDING';
+
+CREATE TABLE IF NOT EXISTS withdraw_refunds
+(
+    id          BIGSERIAL PRIMARY KEY,
+    withdraw_id INTEGER          NOT NULL REFERENCES withdraws (id),
+    username    VARCHAR(72)      NOT NULL,
+    sum         DOUBLE PRECISION NOT NULL,
+    reason      TEXT             NOT NULL,
+    created_at  TIMESTAMPTZ      NOT NULL DEFAULT now(),
+    UNIQUE (withdraw_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_withdraw_refunds_username ON withdraw_refunds (username);
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN TRANSACTION;
+
+DROP TABLE IF EXISTS ledger_entries;
+
+DROP FUNCTION IF EXISTS ledger_entries_immutable();
+
+DROP SEQUENCE IF EXISTS ledger_transactions_seq;
+
+COMMIT;
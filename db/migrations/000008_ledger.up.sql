@@ -0,0 +1,89 @@
+BEGIN TRANSACTION;
+
+CREATE SEQUENCE IF NOT EXISTS ledger_transactions_seq;
+
+CREATE TABLE IF NOT EXISTS ledger_entries
+(
+    id             BIGSERIAL PRIMARY KEY,
+    transaction_id BIGINT           NOT NULL,
+    account        VARCHAR(16)      NOT NULL,
+    username       VARCHAR(72)      NOT NULL,
+    amount         DOUBLE PRECISION NOT NULL,
+    kind           VARCHAR(32)      NOT NULL,
+    source         VARCHAR(16)      NOT NULL,
+    source_id      VARCHAR(64)      NOT NULL,
+    description    TEXT             NOT NULL DEFAULT '',
+    created_at     TIMESTAMPTZ      NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_user
+    ON ledger_entries (username, id) WHERE account = 'USER';
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_source
+    ON ledger_entries (source, source_id);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_transaction_id
+    ON ledger_entries (transaction_id);
+
+CREATE OR REPLACE FUNCTION ledger_entries_immutable() RETURNS TRIGGER AS
+$$
+BEGIN
+    RAISE EXCEPTION 'ledger entries are immutable';
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS ledger_entries_immutable ON ledger_entries;
+
+CREATE TRIGGER ledger_entries_immutable
+    BEFORE UPDATE OR DELETE ON ledger_entries
+    FOR EACH ROW EXECUTE FUNCTION ledger_entries_immutable();
+
+DO
+$$
+BEGIN
+    IF EXISTS (SELECT 1 FROM ledger_entries) THEN
+        RETURN;
+    END IF;
+
+    WITH src AS (
+        SELECT nextval('ledger_transactions_seq') AS tx, username, accrual, number, uploaded_at
+        FROM orders WHERE status = 'PROCESSED' AND accrual <> 0
+    )
+    INSERT INTO ledger_entries (transaction_id, account, username, amount, kind, source, source_id, created_at)
+    SELECT tx, 'ACCRUAL', username, -accrual, 'ACCRUAL', 'order', number, uploaded_at FROM src
+    UNION ALL
+    SELECT tx, 'USER', username, accrual, 'ACCRUAL', 'order', number, uploaded_at FROM src;
+
+    WITH src AS (
+        SELECT nextval('ledger_transactions_seq') AS tx, username, sum, id, processed_at
+        FROM withdraws
+    )
+    INSERT INTO ledger_entries (transaction_id, account, username, amount, kind, source, source_id, created_at)
+    SELECT tx, 'USER', username, -sum, 'WITHDRAWAL', 'withdraw', id::TEXT, processed_at FROM src
+    UNION ALL
+    SELECT tx, 'WITHDRAWALS', username, sum, 'WITHDRAWAL', 'withdraw', id::TEXT, processed_at FROM src;
+
+    WITH src AS (
+        SELECT nextval('ledger_transactions_seq') AS tx, username, sum, id, COALESCE(updated_at, now()) AS created_at
+        FROM withdraws WHERE status = 'CANCELLED'
+    )
+    INSERT INTO ledger_entries (transaction_id, account, username, amount, kind, source, source_id, created_at)
+    SELECT tx, 'WITHDRAWALS', username, -sum, 'WITHDRAWAL_CANCEL', 'withdraw', id::TEXT, created_at FROM src
+    UNION ALL
+    SELECT tx, 'USER', username, sum, 'WITHDRAWAL_CANCEL', 'withdraw', id::TEXT, created_at FROM src;
+
+    WITH src AS (
+        SELECT nextval('ledger_transactions_seq') AS tx, username, sum, withdraw_id, reason, created_at
+        FROM withdraw_refunds
+    )
+    INSERT INTO ledger_entries
+        (transaction_id, account, username, amount, kind, source, source_id, description, created_at)
+    SELECT tx, 'WITHDRAWALS', username, -sum, 'WITHDRAWAL_REFUND', 'withdraw', withdraw_id::TEXT, reason, created_at
+    FROM src
+    UNION ALL
+    SELECT tx, 'USER', username, sum, 'WITHDRAWAL_REFUND', 'withdraw', withdraw_id::TEXT, reason, created_at
+    FROM src;
+END
+$$;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE mart_users
+    DROP COLUMN IF EXISTS points_expired_at;
+
+COMMIT;
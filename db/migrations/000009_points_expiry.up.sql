@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE mart_users
+    ADD COLUMN IF NOT EXISTS points_expired_at TIMESTAMPTZ;
+
+COMMIT;
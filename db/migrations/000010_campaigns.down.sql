@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+DROP TABLE IF EXISTS campaign_redemptions;
+
+DROP TABLE IF EXISTS campaigns;
+
+COMMIT;
@@ -0,0 +1,27 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE IF NOT EXISTS campaigns
+(
+    id         SERIAL PRIMARY KEY,
+    name       VARCHAR(64)      NOT NULL UNIQUE,
+    kind       VARCHAR(16)      NOT NULL,
+    code       VARCHAR(32) UNIQUE,
+    amount     DOUBLE PRECISION NOT NULL DEFAULT 0,
+    multiplier DOUBLE PRECISION NOT NULL DEFAULT 1,
+    starts_at  TIMESTAMPTZ      NOT NULL,
+    ends_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ      NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_kind
+    ON campaigns (kind, starts_at);
+
+CREATE TABLE IF NOT EXISTS campaign_redemptions
+(
+    campaign_id INT         NOT NULL REFERENCES campaigns (id),
+    username    VARCHAR(72) NOT NULL,
+    redeemed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (campaign_id, username)
+);
+
+COMMIT;
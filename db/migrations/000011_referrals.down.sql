@@ -0,0 +1,10 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE ledger_entries
+    ALTER COLUMN source_id TYPE VARCHAR(64);
+
+DROP TABLE IF EXISTS referrals;
+
+DROP TABLE IF EXISTS referral_codes;
+
+COMMIT;
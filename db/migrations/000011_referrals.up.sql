@@ -0,0 +1,25 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE IF NOT EXISTS referral_codes
+(
+    username VARCHAR(72) PRIMARY KEY,
+    code     VARCHAR(16) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS referrals
+(
+    referee      VARCHAR(72) PRIMARY KEY,
+    referrer     VARCHAR(72) NOT NULL,
+    status       VARCHAR(10) NOT NULL DEFAULT 'PENDING',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    processed_at TIMESTAMPTZ,
+    CHECK (referrer <> referee)
+);
+
+CREATE INDEX IF NOT EXISTS idx_referrals_referrer
+    ON referrals (referrer, created_at);
+
+ALTER TABLE ledger_entries
+    ALTER COLUMN source_id TYPE VARCHAR(72);
+
+COMMIT;
@@ -6,15 +6,69 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/config"
 	flag2 "github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewConfig(t *testing.T) {
-	want := &config.Config{Address: "", ConnectionDB: "", Accrual: ""}
+	want := &config.Config{ //nolint:exhaustruct
+		Address: "", ConnectionDB: "", Accrual: "",
+		ShutdownTimeout:     10 * time.Second,
+		ReadHeaderTimeout:   5 * time.Second,
+		ReadTimeout:         15 * time.Second,
+		IdleTimeout:         time.Minute,
+		DBConnectTimeout:    10 * time.Second,
+		DBMaxConns:          10,
+		AccrualTimeout:      10 * time.Second,
+		AccrualWorkers:      16,
+		AccrualPollInterval: time.Second,
+		AccrualLease:        2 * time.Minute,
+		AccrualBackoff:      5 * time.Second,
+		AccrualMaxBackoff:   10 * time.Minute,
+		AccrualMaxAge:       72 * time.Hour,
+		WithdrawGracePeriod: 15 * time.Minute,
+		TransferDailyLimit:  1000,
+		TransferDailyCount:  10,
+		PointsExpiringSoon:  30 * 24 * time.Hour,
+		ReferralBonus:       100,
+		ReferralMaxRewards:  10,
+		HealthTimeout:       2 * time.Second,
+		AuthTokenTTL:        24 * time.Hour,
+		TLSReloadInterval:   time.Minute,
+		HSTSMaxAge:          365 * 24 * time.Hour,
+		CookieSameSite:      "lax",
+		RateLimitStore:      "memory",
+		RateLimits: map[string]config.RateLimit{
+			"POST /api/user/register": {Rate: 1, Burst: 10},
+			"POST /api/user/login":    {Rate: 1, Burst: 10},
+			"POST /api/user/orders":   {Rate: 2, Burst: 20},
+		},
+		Tiers: []config.Tier{
+			{Name: "Bronze", Threshold: 0, Multiplier: 1},
+			{Name: "Silver", Threshold: 1000, Multiplier: 1.05},
+			{Name: "Gold", Threshold: 5000, Multiplier: 1.1},
+		},
+		CompressLevel:     -1,
+		CompressMinLength: 1024,
+		DecompressMaxSize: 1 << 20,
+		SSEHeartbeat:      15 * time.Second,
+
+		WebhookTimeout:      5 * time.Second,
+		WebhookPollInterval: time.Second,
+		WebhookMaxAttempts:  8,
+		WebhookBackoff:      10 * time.Second,
+		WebhookMaxBackoff:   time.Hour,
+		OutboxPollInterval:  500 * time.Millisecond,
+		OutboxRetention:     24 * time.Hour,
+		ListenBackoff:       time.Second,
+		ListenMaxBackoff:    30 * time.Second,
+	}
 	got := config.NewConfig()
 	assert.Equal(t, want, got)
 }
@@ -53,11 +107,7 @@ func TestConfigStringer(t *testing.T) {
 }
 
 func TestLoadEmptyConfigNoErr(t *testing.T) {
-	want := &config.Config{
-		Address:      "",
-		ConnectionDB: "",
-		Accrual:      "",
-	}
+	want := newWantConfig("", "", "")
 	got := config.NewConfig()
 	err := config.LoadConfig(got, config.ProcessEnvServer)
 	assert.NoError(t, err, "error must be nil")
@@ -73,10 +123,17 @@ type argTestConfig struct {
 	flagAccrual string
 }
 
-var wantConfigEnv = &config.Config{
-	Address: "127.0.0.1:59483", ConnectionDB: "db_uri", Accrual: "accrual",
+func newWantConfig(address, db, accrual string) *config.Config {
+	cfg := config.NewConfig()
+	cfg.Address = address
+	cfg.ConnectionDB = db
+	cfg.Accrual = accrual
+
+	return cfg
 }
 
+var wantConfigEnv = newWantConfig("127.0.0.1:59483", "db_uri", "accrual")
+
 var testsCasesInitConfig = []struct {
 	name    string
 	args    argTestConfig
@@ -160,3 +217,151 @@ func envArgsInitConfig(t *testing.T, key string, value string) {
 		t.Cleanup(func() { _ = os.Setenv(key, origValue) })
 	}
 }
+
+func resetFlags(t *testing.T, args ...string) {
+	t.Helper()
+	osArgOrig := os.Args
+	flag2.CommandLine = flag2.NewFlagSet(os.Args[0], flag2.ContinueOnError)
+	flag2.CommandLine.SetOutput(io.Discard)
+	os.Args = append([]string{osArgOrig[0]}, args...)
+	t.Cleanup(func() { os.Args = osArgOrig })
+}
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	yamlFile := writeConfigFile(t, "gophermart.yaml", `
+address: "127.0.0.1:8081"
+database_uri: "postgres://file/db"
+accrual_system_address: "http://file:8080"
+log_level: debug
+shutdown_timeout: 3s
+db_max_conns: 4
+accrual_workers: 2
+`)
+	jsonFile := writeConfigFile(t, "gophermart.json", `{
+  "address": "127.0.0.1:8081",
+  "database_uri": "postgres://file/db",
+  "accrual_system_address": "http://file:8080",
+  "log_level": "debug",
+  "shutdown_timeout": "3s",
+  "db_max_conns": 4,
+  "accrual_workers": 2
+}`)
+	for _, path := range []string{yamlFile, jsonFile} {
+		want := newWantConfig("127.0.0.1:8081", "postgres://file/db", "http://file:8080")
+		want.LogLevel = "debug"
+		want.ShutdownTimeout = 3 * time.Second
+		want.DBMaxConns = 4
+		want.AccrualWorkers = 2
+
+		got := config.NewConfig()
+		require.NoError(t, config.LoadFile(got, path))
+		assert.Equal(t, want, got, path)
+	}
+}
+
+func TestLoadConfigFileErr(t *testing.T) {
+	unknownKey := writeConfigFile(t, "unknown.yaml", "adress: 127.0.0.1:8081\n")
+	badValue := writeConfigFile(t, "bad.yaml", "shutdown_timeout: soon\n")
+
+	assert.Error(t, config.LoadFile(config.NewConfig(), unknownKey))
+	assert.Error(t, config.LoadFile(config.NewConfig(), badValue))
+	assert.Error(t, config.LoadFile(config.NewConfig(), filepath.Join(t.TempDir(), "absent.yaml")))
+}
+
+func TestLoadConfigFileRateLimits(t *testing.T) {
+	path := writeConfigFile(t, "gophermart.yaml", `
+rate_limit_store: postgres
+rate_limit_default:
+  rate: 5
+  burst: 50
+rate_limits:
+  "POST /api/user/login":
+    rate: 0.5
+    burst: 3
+  "POST /api/user/balance/withdraw":
+    rate: 1
+    burst: 2
+`)
+	envArgsInitConfig(t, "RATE_LIMIT_BURST", "60")
+
+	got := config.NewConfig()
+	require.NoError(t, config.LoadFile(got, path))
+	require.NoError(t, config.ProcessEnvServer(got))
+
+	assert.Equal(t, "postgres", got.RateLimitStore)
+	assert.Equal(t, config.RateLimit{Rate: 5, Burst: 60}, got.RateLimitDefault)
+	assert.Equal(t, config.RateLimit{Rate: 0.5, Burst: 3}, got.RateLimitFor("POST", "/api/user/login"))
+	assert.Equal(t, config.RateLimit{Rate: 1, Burst: 2}, got.RateLimitFor("POST", "/api/user/balance/withdraw"))
+	assert.Equal(t, config.RateLimit{Rate: 1, Burst: 10}, got.RateLimitFor("POST", "/api/user/register"))
+	assert.Equal(t, config.RateLimit{Rate: 5, Burst: 60}, got.RateLimitFor("GET", "/api/user/balance"))
+}
+
+func TestTierFor(t *testing.T) {
+	cfg := config.NewConfig()
+
+	tier, next := cfg.TierFor(0)
+	assert.Equal(t, "Bronze", tier.Name)
+	require.NotNil(t, next)
+	assert.Equal(t, "Silver", next.Name)
+
+	tier, next = cfg.TierFor(1000)
+	assert.Equal(t, "Silver", tier.Name, "the threshold belongs to the tier")
+	require.NotNil(t, next)
+	assert.Equal(t, "Gold", next.Name)
+
+	tier, next = cfg.TierFor(10000)
+	assert.Equal(t, "Gold", tier.Name)
+	assert.Nil(t, next)
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "gophermart.yaml", `
+address: "file:1"
+database_uri: "file_db"
+accrual_system_address: "file_accrual"
+log_format: console
+`)
+	envArgsInitConfig(t, config.EnvKeyConfig, path)
+	envArgsInitConfig(t, "DATABASE_URI", "env_db")
+	envArgsInitConfig(t, "ACCRUAL_SYSTEM_ADDRESS", "env_accrual")
+	resetFlags(t, "-r", "flag_accrual")
+
+	want := newWantConfig("file:1", "env_db", "flag_accrual")
+	want.ConfigFile = path
+	want.LogFormat = "console"
+
+	got := config.NewConfig()
+	require.NoError(t, config.LoadConfig(got, config.ProcessEnvServer))
+	assert.Equal(t, want, got)
+}
+
+func TestLoadConfigFileFlag(t *testing.T) {
+	path := writeConfigFile(t, "gophermart.yaml", "address: \"file:1\"\n")
+	envArgsInitConfig(t, config.EnvKeyConfig, filepath.Join(t.TempDir(), "absent.yaml"))
+	resetFlags(t, "-c", path)
+
+	got := config.NewConfig()
+	require.NoError(t, config.LoadConfig(got, config.ProcessEnvServer))
+	assert.Equal(t, "file:1", got.Address)
+	assert.Equal(t, path, got.ConfigFile)
+}
+
+func TestLoadConfigFileMissing(t *testing.T) {
+	resetFlags(t, "-c", filepath.Join(t.TempDir(), "absent.yaml"))
+
+	assert.Error(t, config.LoadConfig(config.NewConfig(), config.ProcessEnvServer))
+}
+
+func TestLoadConfigExampleFile(t *testing.T) {
+	cfg := config.NewConfig()
+	require.NoError(t, config.LoadFile(cfg, "../../../../cmd/gophermart/config.example.yaml"))
+	assert.NoError(t, cfg.Validate())
+}
@@ -1,70 +1,445 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 	flag2 "github.com/spf13/pflag"
 	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
 )
 
 const (
 	EnvKeyAddress     = "RUN_ADDRESS"
 	EnvKeyDatabaseURI = "DATABASE_URI"
+	EnvKeyConfig      = "CONFIG"
 )
 
+// Defaults of the tunables.
+const (
+	DefaultShutdownTimeout   = 10 * time.Second
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultReadTimeout       = 15 * time.Second
+	DefaultIdleTimeout       = 60 * time.Second
+	DefaultDBConnectTimeout  = 10 * time.Second
+	DefaultDBMaxConns        = 10
+	DefaultAccrualTimeout    = 10 * time.Second
+	DefaultAccrualWorkers    = 16
+	DefaultAccrualPoll       = time.Second
+	DefaultAccrualLease      = 2 * time.Minute
+	DefaultAccrualBackoff    = 5 * time.Second
+	DefaultAccrualMaxBackoff = 10 * time.Minute
+	DefaultAccrualMaxAge     = 72 * time.Hour
+	DefaultWithdrawGrace     = 15 * time.Minute
+	DefaultTransferLimit     = 1000
+	DefaultTransferCount     = 10
+	DefaultExpiringSoon      = 30 * 24 * time.Hour
+	DefaultReferralBonus     = 100
+	DefaultReferralRewards   = 10
+	DefaultHealthTimeout     = 2 * time.Second
+	DefaultAuthTokenTTL      = 24 * time.Hour
+	DefaultTLSReloadInterval = time.Minute
+	DefaultHSTSMaxAge        = 365 * 24 * time.Hour
+	DefaultCookieSameSite    = "lax"
+	DefaultRateLimitStore    = "memory"
+	DefaultCompressLevel     = -1 // the default level of compress/flate
+	DefaultCompressMinLength = 1024
+	DefaultDecompressMaxSize = 1 << 20
+	DefaultSSEHeartbeat      = 15 * time.Second
+	DefaultWebhookTimeout    = 5 * time.Second
+	DefaultWebhookPoll       = time.Second
+	DefaultWebhookAttempts   = 8
+	DefaultWebhookBackoff    = 10 * time.Second
+	DefaultWebhookMaxBackoff = time.Hour
+	DefaultOutboxPoll        = 500 * time.Millisecond
+	DefaultOutboxRetention   = 24 * time.Hour
+	DefaultListenBackoff     = time.Second
+	DefaultListenMaxBackoff  = 30 * time.Second
+)
+
+// RateLimit is a token bucket limit of requests.
+type RateLimit struct {
+	// Rate is a count of requests per second, zero disables the limit.
+	Rate float64 `env:"RATE" yaml:"rate"`
+	// Burst is a count of requests which may come at once.
+	Burst int `env:"BURST" yaml:"burst"`
+}
+
+// DefaultRateLimits returns limits of the routes which are expensive or attractive for brute force.
+func DefaultRateLimits() map[string]RateLimit {
+	return map[string]RateLimit{
+		"POST /api/user/register": {Rate: 1, Burst: 10},
+		"POST /api/user/login":    {Rate: 1, Burst: 10},
+		"POST /api/user/orders":   {Rate: 2, Burst: 20},
+	}
+}
+
+// Tier is a loyalty tier of the users whose lifetime accrual reaches Threshold,
+// the accrual of their next orders is multiplied by Multiplier.
+type Tier struct {
+	Name       string  `yaml:"name"`
+	Threshold  float32 `yaml:"threshold"`
+	Multiplier float32 `yaml:"multiplier"`
+}
+
+// DefaultTiers returns the tiers from the lowest one.
+func DefaultTiers() []Tier {
+	return []Tier{
+		{Name: "Bronze", Threshold: 0, Multiplier: 1},
+		{Name: "Silver", Threshold: 1000, Multiplier: 1.05},
+		{Name: "Gold", Threshold: 5000, Multiplier: 1.1},
+	}
+}
+
 // Config represents a config of the server.
+//
+// The values are loaded with the precedence defaults < file < env < flags:
+// NewConfig sets the defaults, then the optional YAML/JSON file
+// from '-c' flag or CONFIG env overrides them,
+// then ENV variables and then explicitly passed flags.
+//
+//nolint:tagliatelle
 type Config struct {
-	Address      string `env:"RUN_ADDRESS"`
-	ConnectionDB string `env:"DATABASE_URI"`
-	Accrual      string `env:"ACCRUAL_SYSTEM_ADDRESS"`
+	// ConfigFile is a path of YAML or JSON file with the config.
+	ConfigFile string `yaml:"-"`
+
+	Address      string `env:"RUN_ADDRESS"            yaml:"address"`
+	ConnectionDB string `env:"DATABASE_URI"           yaml:"database_uri"`
+	Accrual      string `env:"ACCRUAL_SYSTEM_ADDRESS" yaml:"accrual_system_address"`
+
+	// TraceExporter is one of "none", "stdout", "file" or "otlp".
+	TraceExporter string `env:"TRACE_EXPORTER" yaml:"trace_exporter"`
+	// TraceEndpoint is host:port of OTLP/HTTP collector.
+	TraceEndpoint string `env:"TRACE_ENDPOINT" yaml:"trace_endpoint"`
+	// TraceFile is a path of the file for "file" exporter.
+	TraceFile string `env:"TRACE_FILE" yaml:"trace_file"`
+
+	// LogLevel is one of zap levels: "debug", "info", "warn", "error".
+	LogLevel string `env:"LOG_LEVEL" yaml:"log_level"`
+	// LogFormat is "json" or "console".
+	LogFormat string `env:"LOG_FORMAT" yaml:"log_format"`
+	// LogSampling enables sampling of repeated log entries.
+	LogSampling bool `env:"LOG_SAMPLING" yaml:"log_sampling"`
+	// LogFile is a path of the log file, logs go to stderr when it is empty.
+	LogFile string `env:"LOG_FILE" yaml:"log_file"`
+	// LogMaxSizeMB, LogMaxBackups and LogMaxAgeDays control rotation of LogFile.
+	LogMaxSizeMB  int `env:"LOG_MAX_SIZE_MB"  yaml:"log_max_size_mb"`
+	LogMaxBackups int `env:"LOG_MAX_BACKUPS"  yaml:"log_max_backups"`
+	LogMaxAgeDays int `env:"LOG_MAX_AGE_DAYS" yaml:"log_max_age_days"`
+
+	// AdminToken protects admin endpoints, they are disabled when it is empty.
+	AdminToken string `env:"ADMIN_TOKEN" yaml:"admin_token"`
+
+	// ShutdownTimeout limits the graceful shutdown of the server.
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" yaml:"shutdown_timeout"`
+	// ReadHeaderTimeout, ReadTimeout, WriteTimeout and IdleTimeout are timeouts of http.Server,
+	// zero WriteTimeout means no limit.
+	ReadHeaderTimeout time.Duration `env:"READ_HEADER_TIMEOUT" yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `env:"READ_TIMEOUT"        yaml:"read_timeout"`
+	WriteTimeout      time.Duration `env:"WRITE_TIMEOUT"       yaml:"write_timeout"`
+	IdleTimeout       time.Duration `env:"IDLE_TIMEOUT"        yaml:"idle_timeout"`
+
+	// DBConnectTimeout limits connecting to the DB and creating the tables.
+	DBConnectTimeout time.Duration `env:"DB_CONNECT_TIMEOUT" yaml:"db_connect_timeout"`
+	// DBMaxConns and DBMinConns are the size limits of the DB connection pool.
+	DBMaxConns int `env:"DB_MAX_CONNS" yaml:"db_max_conns"`
+	DBMinConns int `env:"DB_MIN_CONNS" yaml:"db_min_conns"`
+
+	// AccrualTimeout limits a request to the accrual system.
+	AccrualTimeout time.Duration `env:"ACCRUAL_TIMEOUT" yaml:"accrual_timeout"`
+	// AccrualWorkers limits background requests to the accrual system running at once.
+	AccrualWorkers int `env:"ACCRUAL_WORKERS" yaml:"accrual_workers"`
+	// AccrualPollInterval is how often the pending orders are claimed for polling the accrual system.
+	AccrualPollInterval time.Duration `env:"ACCRUAL_POLL_INTERVAL" yaml:"accrual_poll_interval"`
+	// AccrualLease is how long a claimed order is not polled by other instances,
+	// it expires if the instance dies before it polls the order.
+	AccrualLease time.Duration `env:"ACCRUAL_LEASE" yaml:"accrual_lease"`
+	// AccrualBackoff is a delay of the next check of an order after the first attempt,
+	// it doubles after each next one up to AccrualMaxBackoff, a random jitter takes up to half of it.
+	AccrualBackoff    time.Duration `env:"ACCRUAL_BACKOFF"     yaml:"accrual_backoff"`
+	AccrualMaxBackoff time.Duration `env:"ACCRUAL_MAX_BACKOFF" yaml:"accrual_max_backoff"`
+	// AccrualMaxAge is how long an order may stay not final before it is flagged for manual review.
+	AccrualMaxAge time.Duration `env:"ACCRUAL_MAX_AGE" yaml:"accrual_max_age"`
+
+	// WithdrawGracePeriod is how long a withdrawal stays pending and may be cancelled by its user.
+	WithdrawGracePeriod time.Duration `env:"WITHDRAW_GRACE_PERIOD" yaml:"withdraw_grace_period"`
+	// TransferDailyLimit limits the points a user transfers to other users for the last 24 hours
+	// and TransferDailyCount limits the count of the transfers, zero disables a limit.
+	TransferDailyLimit float32 `env:"TRANSFER_DAILY_LIMIT" yaml:"transfer_daily_limit"`
+	TransferDailyCount int     `env:"TRANSFER_DAILY_COUNT" yaml:"transfer_daily_count"`
+	// PointsExpireAfter is how long the credited points may be spent, zero disables the expiry.
+	// The withdrawals and the transfers spend the oldest points first.
+	PointsExpireAfter time.Duration `env:"POINTS_EXPIRE_AFTER" yaml:"points_expire_after"`
+	// Tiers are the loyalty tiers from the lowest one, the threshold of the lowest tier is zero;
+	// they are set by the config file only.
+	Tiers []Tier `yaml:"tiers"`
+	// PointsExpiringSoon is a period of the points expiring soon shown with the balance.
+	PointsExpiringSoon time.Duration `env:"POINTS_EXPIRING_SOON" yaml:"points_expiring_soon"`
+	// ReferralBonus is credited to the referrer when the first order of the referred user is processed,
+	// zero disables the rewards; ReferralMaxRewards limits the rewarded referrals of a referrer, zero is unlimited.
+	ReferralBonus      float32 `env:"REFERRAL_BONUS" yaml:"referral_bonus"`
+	ReferralMaxRewards int     `env:"REFERRAL_MAX_REWARDS" yaml:"referral_max_rewards"`
+
+	// HealthTimeout limits a check of a dependency in `/readyz`.
+	HealthTimeout time.Duration `env:"HEALTH_TIMEOUT" yaml:"health_timeout"`
+
+	// AuthTokenTTL is a lifetime of an authorization token.
+	AuthTokenTTL time.Duration `env:"AUTH_TOKEN_TTL" yaml:"auth_token_ttl"`
+
+	// TLSCertFile and TLSKeyFile enable HTTPS on Address when both are set.
+	TLSCertFile string `env:"TLS_CERT_FILE" yaml:"tls_cert_file"`
+	TLSKeyFile  string `env:"TLS_KEY_FILE"  yaml:"tls_key_file"`
+	// TLSReloadInterval is how often the certificate files are checked for changes.
+	TLSReloadInterval time.Duration `env:"TLS_RELOAD_INTERVAL" yaml:"tls_reload_interval"`
+	// HTTPRedirectAddress is an address of plain HTTP listener which redirects to HTTPS,
+	// it is not started when empty.
+	HTTPRedirectAddress string `env:"HTTP_REDIRECT_ADDRESS" yaml:"http_redirect_address"`
+	// HSTSMaxAge is max-age of 'Strict-Transport-Security' header sent over HTTPS, zero disables it.
+	HSTSMaxAge time.Duration `env:"HSTS_MAX_AGE" yaml:"hsts_max_age"`
+
+	// CookieSecure marks the auth cookie as Secure without TLS, e.g. behind a TLS proxy.
+	CookieSecure bool `env:"COOKIE_SECURE" yaml:"cookie_secure"`
+	// CookieSameSite is "strict", "lax" or "none".
+	CookieSameSite string `env:"COOKIE_SAME_SITE" yaml:"cookie_same_site"`
+
+	// RateLimitStore is "memory" for limits of the instance
+	// or "postgres" for limits shared by the instances.
+	RateLimitStore string `env:"RATE_LIMIT_STORE" yaml:"rate_limit_store"`
+	// RateLimitDefault applies to the routes which are absent in RateLimits,
+	// it is set by RATE_LIMIT_RATE and RATE_LIMIT_BURST.
+	RateLimitDefault RateLimit `envPrefix:"RATE_LIMIT_" yaml:"rate_limit_default"`
+	// RateLimits are limits of the routes keyed by "METHOD /path", they are set by the config file only.
+	RateLimits map[string]RateLimit `yaml:"rate_limits"`
+
+	// CompressLevel is a level of gzip/deflate responses from -2 (Huffman only) to 9 (best),
+	// -1 is the default level.
+	CompressLevel int `env:"COMPRESS_LEVEL" yaml:"compress_level"`
+	// CompressMinLength is a size of the body since which the responses are compressed.
+	CompressMinLength int `env:"COMPRESS_MIN_LENGTH" yaml:"compress_min_length"`
+	// DecompressMaxSize limits a decoded size of gzip/deflate request bodies.
+	DecompressMaxSize int64 `env:"DECOMPRESS_MAX_SIZE" yaml:"decompress_max_size"`
+
+	// SSEHeartbeat is an interval of comments sent to idle event streams,
+	// so proxies keep the connections open.
+	SSEHeartbeat time.Duration `env:"SSE_HEARTBEAT" yaml:"sse_heartbeat"`
+
+	// WebhookTimeout limits a delivery of an event to a webhook.
+	WebhookTimeout time.Duration `env:"WEBHOOK_TIMEOUT" yaml:"webhook_timeout"`
+	// WebhookPollInterval is how often the due deliveries are looked for.
+	WebhookPollInterval time.Duration `env:"WEBHOOK_POLL_INTERVAL" yaml:"webhook_poll_interval"`
+	// WebhookMaxAttempts is a count of attempts after which a delivery goes to the dead letters.
+	WebhookMaxAttempts int `env:"WEBHOOK_MAX_ATTEMPTS" yaml:"webhook_max_attempts"`
+	// WebhookBackoff is a delay after the first failed attempt, it doubles after each next one
+	// up to WebhookMaxBackoff.
+	WebhookBackoff    time.Duration `env:"WEBHOOK_BACKOFF"     yaml:"webhook_backoff"`
+	WebhookMaxBackoff time.Duration `env:"WEBHOOK_MAX_BACKOFF" yaml:"webhook_max_backoff"`
+	// WebhookAllowPrivate allows webhooks on loopback and private networks, e.g. for development.
+	WebhookAllowPrivate bool `env:"WEBHOOK_ALLOW_PRIVATE" yaml:"webhook_allow_private"`
+
+	// OutboxPollInterval is how often the pending events of the outbox are dispatched.
+	OutboxPollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" yaml:"outbox_poll_interval"`
+	// OutboxRetention is how long the dispatched events are kept in the outbox.
+	OutboxRetention time.Duration `env:"OUTBOX_RETENTION" yaml:"outbox_retention"`
+	// ListenBackoff is a delay before reconnecting the listener of the events of other instances,
+	// it doubles after each next failed attempt up to ListenMaxBackoff.
+	ListenBackoff    time.Duration `env:"LISTEN_BACKOFF"     yaml:"listen_backoff"`
+	ListenMaxBackoff time.Duration `env:"LISTEN_MAX_BACKOFF" yaml:"listen_max_backoff"`
+
+	// OpenAPIValidateResponses logs the responses which do not match the OpenAPI document,
+	// it is meant for development and tests.
+	OpenAPIValidateResponses bool `env:"OPENAPI_VALIDATE_RESPONSES" yaml:"openapi_validate_responses"`
 }
 
-// NewConfig creates an instance of Config.
+// NewConfig creates an instance of Config with the defaults.
 func NewConfig() *Config {
-	return &Config{Address: "", ConnectionDB: "", Accrual: ""}
+	return &Config{
+		ConfigFile: "",
+		Address:    "", ConnectionDB: "", Accrual: "",
+		TraceExporter: "", TraceEndpoint: "", TraceFile: "",
+		LogLevel: "", LogFormat: "", LogSampling: false, LogFile: "",
+		LogMaxSizeMB: 0, LogMaxBackups: 0, LogMaxAgeDays: 0,
+		AdminToken:          "",
+		ShutdownTimeout:     DefaultShutdownTimeout,
+		ReadHeaderTimeout:   DefaultReadHeaderTimeout,
+		ReadTimeout:         DefaultReadTimeout,
+		WriteTimeout:        0,
+		IdleTimeout:         DefaultIdleTimeout,
+		DBConnectTimeout:    DefaultDBConnectTimeout,
+		DBMaxConns:          DefaultDBMaxConns,
+		DBMinConns:          0,
+		AccrualTimeout:      DefaultAccrualTimeout,
+		AccrualWorkers:      DefaultAccrualWorkers,
+		AccrualPollInterval: DefaultAccrualPoll,
+		AccrualLease:        DefaultAccrualLease,
+		AccrualBackoff:      DefaultAccrualBackoff,
+		AccrualMaxBackoff:   DefaultAccrualMaxBackoff,
+		AccrualMaxAge:       DefaultAccrualMaxAge,
+		WithdrawGracePeriod: DefaultWithdrawGrace,
+		TransferDailyLimit:  DefaultTransferLimit,
+		TransferDailyCount:  DefaultTransferCount,
+		PointsExpireAfter:   0,
+		PointsExpiringSoon:  DefaultExpiringSoon,
+		Tiers:               DefaultTiers(),
+		ReferralBonus:       DefaultReferralBonus,
+		ReferralMaxRewards:  DefaultReferralRewards,
+		HealthTimeout:       DefaultHealthTimeout,
+		AuthTokenTTL:        DefaultAuthTokenTTL,
+		TLSCertFile:         "",
+		TLSKeyFile:          "",
+		TLSReloadInterval:   DefaultTLSReloadInterval,
+		HTTPRedirectAddress: "",
+		HSTSMaxAge:          DefaultHSTSMaxAge,
+		CookieSecure:        false,
+		CookieSameSite:      DefaultCookieSameSite,
+		RateLimitStore:      DefaultRateLimitStore,
+		RateLimitDefault:    RateLimit{Rate: 0, Burst: 0},
+		RateLimits:          DefaultRateLimits(),
+		CompressLevel:       DefaultCompressLevel,
+		CompressMinLength:   DefaultCompressMinLength,
+		DecompressMaxSize:   DefaultDecompressMaxSize,
+		SSEHeartbeat:        DefaultSSEHeartbeat,
+		WebhookTimeout:      DefaultWebhookTimeout,
+		WebhookPollInterval: DefaultWebhookPoll,
+		WebhookMaxAttempts:  DefaultWebhookAttempts,
+		WebhookBackoff:      DefaultWebhookBackoff,
+		WebhookMaxBackoff:   DefaultWebhookMaxBackoff,
+		WebhookAllowPrivate: false,
+		OutboxPollInterval:  DefaultOutboxPoll,
+		OutboxRetention:     DefaultOutboxRetention,
+		ListenBackoff:       DefaultListenBackoff,
+		ListenMaxBackoff:    DefaultListenMaxBackoff,
+
+		OpenAPIValidateResponses: false,
+	}
+}
+
+// RateLimitFor returns the limit of the route.
+func (cfg Config) RateLimitFor(method string, path string) RateLimit {
+	if limit, ok := cfg.RateLimits[method+" "+path]; ok {
+		return limit
+	}
+
+	return cfg.RateLimitDefault
+}
+
+// TierFor returns the tier of the lifetime accrual and the next tier, the next tier is nil for the highest one.
+func (cfg Config) TierFor(lifetime float32) (Tier, *Tier) {
+	var tier Tier
+	for i := range cfg.Tiers {
+		if lifetime < cfg.Tiers[i].Threshold {
+			return tier, &cfg.Tiers[i]
+		}
+		tier = cfg.Tiers[i]
+	}
+
+	return tier, nil
+}
+
+// TLSEnabled reports whether the server is configured to serve HTTPS.
+func (cfg Config) TLSEnabled() bool {
+	return cfg.TLSCertFile != "" && cfg.TLSKeyFile != ""
 }
 
 // ProcessEnv receives and sets up the Config.
 type ProcessEnv func(config *Config) error
 
+var errNilConfig = errors.New("config is nil")
+
 // LoadConfig loads data to the passed Config.
 func LoadConfig(cfg *Config, processing ProcessEnv) error {
+	if cfg == nil {
+		return fmt.Errorf("config: cannot process ENV variables: %w", errNilConfig)
+	}
+
+	flags := parseFlags(cfg)
+
+	cfg.ConfigFile = os.Getenv(EnvKeyConfig)
+	if flags.isSet("c") {
+		cfg.ConfigFile = flags.configFile
+	}
+	if cfg.ConfigFile != "" {
+		if err := LoadFile(cfg, cfg.ConfigFile); err != nil {
+			return err
+		}
+	}
+
 	if err := processing(cfg); err != nil {
 		return fmt.Errorf("config: cannot process ENV variables: %w", err)
 	}
 
-	processFlags(cfg)
+	flags.apply(cfg)
+
+	return nil
+}
+
+// LoadFile overrides cfg by values of YAML or JSON file,
+// the keys which are absent in the file keep their values.
+func LoadFile(cfg *Config, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config: cannot open file: %w", err)
+	}
+	defer file.Close()
+
+	// YAML is a superset of JSON, so the decoder reads both formats.
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err = decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("config: cannot parse file %s: %w", path, err)
+	}
 
 	return nil
 }
 
-func processFlags(cfg *Config) {
+type parsedFlags struct {
+	set        *flag2.FlagSet
+	address    string
+	accrual    string
+	db         string
+	configFile string
+}
+
+func parseFlags(cfg *Config) *parsedFlags {
 	flag2.CommandLine.ParseErrorsWhitelist.UnknownFlags = true
-	aFlag, dFlag, rFlag := "", "", ""
+	flags := &parsedFlags{set: flag2.CommandLine, address: "", accrual: "", db: "", configFile: ""}
 
-	addFlags(cfg, &aFlag, &rFlag, &dFlag)
+	flag2.StringVarP(&flags.address, "a", "a", cfg.Address, "address of the server, RUN_ADDRESS")
+	flag2.StringVarP(&flags.accrual, "r", "r", cfg.Accrual, "address of the accrual system, ACCRUAL_SYSTEM_ADDRESS")
+	flag2.StringVarP(&flags.db, "d", "d", cfg.ConnectionDB, "db uri, DATABASE_URI")
+	flag2.StringVarP(&flags.configFile, "c", "c", "", "path of YAML or JSON config file, CONFIG")
 
 	flag2.Parse()
 
-	cfg.Address = aFlag
-	cfg.ConnectionDB = dFlag
-	cfg.Accrual = rFlag
+	return flags
+}
+
+func (f *parsedFlags) isSet(name string) bool {
+	return f.set.Changed(name)
 }
 
-func addFlags(cfg *Config, aFlag *string, rFlag *string, dFlag *string) {
-	flag2.StringVarP(aFlag, "a", "a", cfg.Address, "")
-	flag2.StringVarP(rFlag, "r", "r", cfg.Accrual, "")
-	flag2.StringVarP(dFlag, "d", "d", cfg.ConnectionDB, "")
+// apply sets only the flags which are passed explicitly,
+// so defaults of the flags never override the file and ENV values.
+func (f *parsedFlags) apply(cfg *Config) {
+	if f.isSet("a") {
+		cfg.Address = f.address
+	}
+	if f.isSet("d") {
+		cfg.ConnectionDB = f.db
+	}
+	if f.isSet("r") {
+		cfg.Accrual = f.accrual
+	}
 }
 
 func ProcessEnvServer(config *Config) error {
-	zap.S().Infoln(os.Environ())
+	zap.S().Debugln(os.Environ())
 
 	opts := env.Options{ //nolint:exhaustruct
 		OnSet: func(tag string, value interface{}, isDefault bool) {
-			zap.S().Infof("Set %s to %v (default? %v)\n", tag, value, isDefault)
+			zap.S().Debugf("Set %s to %v (default? %v)\n", tag, value, isDefault)
 		},
 	}
 
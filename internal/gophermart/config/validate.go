@@ -0,0 +1,274 @@
+package config
+
+import (
+	"compress/flate"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap/zapcore"
+)
+
+// ValidationError aggregates all problems found by Config.Validate.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config: " + strings.Join(e.Problems, "; ")
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) positive(name string, value time.Duration) {
+	if value <= 0 {
+		v.addf("%s must be positive, got %s", name, value)
+	}
+}
+
+func (v *validator) nonNegative(name string, value int) {
+	if value < 0 {
+		v.addf("%s must not be negative, got %d", name, value)
+	}
+}
+
+// Validate checks formats and ranges of the values,
+// the returned *ValidationError lists all the problems at once.
+func (cfg Config) Validate() error {
+	validator := &validator{problems: nil}
+
+	cfg.validateAddresses(validator)
+	cfg.validateObservability(validator)
+	cfg.validateTunables(validator)
+	cfg.validateTLS(validator)
+	cfg.validateRateLimits(validator)
+	cfg.validateWebhooks(validator)
+	cfg.validateTiers(validator)
+
+	if len(validator.problems) > 0 {
+		return &ValidationError{Problems: validator.problems}
+	}
+
+	return nil
+}
+
+func (cfg Config) validateAddresses(validator *validator) {
+	if cfg.Address == "" {
+		validator.addf("server address is empty")
+	} else if _, _, err := net.SplitHostPort(cfg.Address); err != nil {
+		validator.addf("server address [%s] is not host:port", cfg.Address)
+	}
+
+	if cfg.ConnectionDB == "" {
+		validator.addf("db uri is empty")
+	} else if _, err := pgconn.ParseConfig(cfg.ConnectionDB); err != nil {
+		validator.addf("db uri is malformed")
+	}
+
+	if cfg.Accrual != "" {
+		accrualURL, err := url.Parse(cfg.Accrual)
+		if err != nil || (accrualURL.Scheme != "http" && accrualURL.Scheme != "https") || accrualURL.Host == "" {
+			validator.addf("accrual system address [%s] is not http(s) URL", cfg.Accrual)
+		}
+	}
+}
+
+func (cfg Config) validateObservability(validator *validator) {
+	switch cfg.TraceExporter {
+	case "", "none", "stdout":
+	case "file":
+		if cfg.TraceFile == "" {
+			validator.addf("trace file is empty for \"file\" trace exporter")
+		}
+	case "otlp":
+		if _, _, err := net.SplitHostPort(cfg.TraceEndpoint); cfg.TraceEndpoint != "" && err != nil {
+			validator.addf("trace endpoint [%s] is not host:port", cfg.TraceEndpoint)
+		}
+	default:
+		validator.addf("unknown trace exporter [%s]", cfg.TraceExporter)
+	}
+
+	if cfg.LogLevel != "" {
+		if _, err := zapcore.ParseLevel(cfg.LogLevel); err != nil {
+			validator.addf("unknown log level [%s]", cfg.LogLevel)
+		}
+	}
+	switch strings.ToLower(cfg.LogFormat) {
+	case "", "json", "console":
+	default:
+		validator.addf("unknown log format [%s]", cfg.LogFormat)
+	}
+	validator.nonNegative("log max size", cfg.LogMaxSizeMB)
+	validator.nonNegative("log max backups", cfg.LogMaxBackups)
+	validator.nonNegative("log max age", cfg.LogMaxAgeDays)
+}
+
+func (cfg Config) validateTunables(validator *validator) {
+	validator.positive("shutdown timeout", cfg.ShutdownTimeout)
+	validator.positive("read header timeout", cfg.ReadHeaderTimeout)
+	validator.positive("read timeout", cfg.ReadTimeout)
+	if cfg.WriteTimeout < 0 {
+		validator.addf("write timeout must not be negative, got %s", cfg.WriteTimeout)
+	}
+	validator.positive("idle timeout", cfg.IdleTimeout)
+	validator.positive("db connect timeout", cfg.DBConnectTimeout)
+	validator.positive("accrual timeout", cfg.AccrualTimeout)
+	validator.positive("accrual poll interval", cfg.AccrualPollInterval)
+	if cfg.AccrualLease <= cfg.AccrualTimeout {
+		validator.addf("accrual lease must be greater than accrual timeout, got %s", cfg.AccrualLease)
+	}
+	validator.positive("accrual backoff", cfg.AccrualBackoff)
+	if cfg.AccrualMaxBackoff < cfg.AccrualBackoff {
+		validator.addf("accrual max backoff must not be less than accrual backoff, got %s", cfg.AccrualMaxBackoff)
+	}
+	validator.positive("accrual max age", cfg.AccrualMaxAge)
+	if cfg.WithdrawGracePeriod < 0 {
+		validator.addf("withdraw grace period must not be negative, got %s", cfg.WithdrawGracePeriod)
+	}
+	if cfg.TransferDailyLimit < 0 {
+		validator.addf("transfer daily limit must not be negative, got %v", cfg.TransferDailyLimit)
+	}
+	validator.nonNegative("transfer daily count", cfg.TransferDailyCount)
+	if cfg.PointsExpireAfter < 0 {
+		validator.addf("points expire after must not be negative, got %s", cfg.PointsExpireAfter)
+	}
+	validator.positive("points expiring soon", cfg.PointsExpiringSoon)
+	if cfg.ReferralBonus < 0 {
+		validator.addf("referral bonus must not be negative, got %v", cfg.ReferralBonus)
+	}
+	validator.nonNegative("referral max rewards", cfg.ReferralMaxRewards)
+	validator.positive("health timeout", cfg.HealthTimeout)
+	validator.positive("auth token ttl", cfg.AuthTokenTTL)
+	validator.positive("sse heartbeat", cfg.SSEHeartbeat)
+	validator.positive("outbox poll interval", cfg.OutboxPollInterval)
+	validator.positive("outbox retention", cfg.OutboxRetention)
+	validator.positive("listen backoff", cfg.ListenBackoff)
+	if cfg.ListenMaxBackoff < cfg.ListenBackoff {
+		validator.addf("listen max backoff must not be less than listen backoff, got %s", cfg.ListenMaxBackoff)
+	}
+
+	if cfg.DBMaxConns < 1 || cfg.DBMaxConns > math.MaxInt32 {
+		validator.addf("db max conns must be in [1, %d], got %d", math.MaxInt32, cfg.DBMaxConns)
+	}
+	if cfg.DBMinConns < 0 || cfg.DBMinConns > cfg.DBMaxConns {
+		validator.addf("db min conns must be in [0, db max conns], got %d", cfg.DBMinConns)
+	}
+	if cfg.AccrualWorkers < 1 {
+		validator.addf("accrual workers must be positive, got %d", cfg.AccrualWorkers)
+	}
+
+	if cfg.CompressLevel < flate.HuffmanOnly || cfg.CompressLevel > flate.BestCompression {
+		validator.addf("compress level must be in [%d, %d], got %d",
+			flate.HuffmanOnly, flate.BestCompression, cfg.CompressLevel)
+	}
+	validator.nonNegative("compress min length", cfg.CompressMinLength)
+	if cfg.DecompressMaxSize < 1 {
+		validator.addf("decompress max size must be positive, got %d", cfg.DecompressMaxSize)
+	}
+}
+
+func (cfg Config) validateTLS(validator *validator) {
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		validator.addf("tls cert file and tls key file must be set together")
+	}
+	validator.positive("tls reload interval", cfg.TLSReloadInterval)
+	if cfg.HTTPRedirectAddress != "" {
+		if !cfg.TLSEnabled() {
+			validator.addf("http redirect address requires tls")
+		}
+		if _, _, err := net.SplitHostPort(cfg.HTTPRedirectAddress); err != nil {
+			validator.addf("http redirect address [%s] is not host:port", cfg.HTTPRedirectAddress)
+		}
+	}
+	if cfg.HSTSMaxAge < 0 {
+		validator.addf("hsts max age must not be negative, got %s", cfg.HSTSMaxAge)
+	}
+
+	switch strings.ToLower(cfg.CookieSameSite) {
+	case "strict", "lax":
+	case "none":
+		if !cfg.TLSEnabled() && !cfg.CookieSecure {
+			validator.addf("cookie same site \"none\" requires tls or secure cookie")
+		}
+	default:
+		validator.addf("unknown cookie same site [%s]", cfg.CookieSameSite)
+	}
+}
+
+func (cfg Config) validateRateLimits(validator *validator) {
+	switch cfg.RateLimitStore {
+	case "", "memory", "postgres":
+	default:
+		validator.addf("unknown rate limit store [%s]", cfg.RateLimitStore)
+	}
+
+	validateRateLimit(validator, "default", cfg.RateLimitDefault)
+	routes := make([]string, 0, len(cfg.RateLimits))
+	for route := range cfg.RateLimits {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		method, path, ok := strings.Cut(route, " ")
+		if !ok || method == "" || method != strings.ToUpper(method) || !strings.HasPrefix(path, "/") {
+			validator.addf("rate limit route [%s] is not \"METHOD /path\"", route)
+		}
+		validateRateLimit(validator, route, cfg.RateLimits[route])
+	}
+}
+
+func validateRateLimit(validator *validator, name string, limit RateLimit) {
+	if limit.Rate < 0 {
+		validator.addf("rate limit [%s] rate must not be negative, got %v", name, limit.Rate)
+	}
+	if limit.Rate > 0 && limit.Burst < 1 {
+		validator.addf("rate limit [%s] burst must be positive, got %d", name, limit.Burst)
+	}
+}
+
+func (cfg Config) validateTiers(validator *validator) {
+	if len(cfg.Tiers) == 0 {
+		validator.addf("at least one tier is required")
+
+		return
+	}
+	if cfg.Tiers[0].Threshold != 0 {
+		validator.addf("tier [%s] threshold must be zero as it is the lowest tier, got %v",
+			cfg.Tiers[0].Name, cfg.Tiers[0].Threshold)
+	}
+	for i, tier := range cfg.Tiers {
+		if tier.Name == "" {
+			validator.addf("tier #%d name is required", i+1)
+		}
+		if tier.Multiplier < 1 {
+			validator.addf("tier [%s] multiplier must not be less than 1, got %v", tier.Name, tier.Multiplier)
+		}
+		if i > 0 && tier.Threshold <= cfg.Tiers[i-1].Threshold {
+			validator.addf("tier [%s] threshold must be greater than the one of the previous tier, got %v",
+				tier.Name, tier.Threshold)
+		}
+	}
+}
+
+func (cfg Config) validateWebhooks(validator *validator) {
+	validator.positive("webhook timeout", cfg.WebhookTimeout)
+	validator.positive("webhook poll interval", cfg.WebhookPollInterval)
+	if cfg.WebhookMaxAttempts < 1 {
+		validator.addf("webhook max attempts must be positive, got %d", cfg.WebhookMaxAttempts)
+	}
+	validator.positive("webhook backoff", cfg.WebhookBackoff)
+	if cfg.WebhookMaxBackoff < cfg.WebhookBackoff {
+		validator.addf("webhook max backoff must not be less than webhook backoff, got %s", cfg.WebhookMaxBackoff)
+	}
+}
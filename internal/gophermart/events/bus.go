@@ -0,0 +1,129 @@
+// Package events delivers changes of orders to the subscribers inside the process.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/metrics"
+)
+
+// SubscriptionBuffer is a count of events kept for a subscriber,
+// the events beyond it are dropped, so a slow subscriber never blocks a publisher.
+const SubscriptionBuffer = 16
+
+// OrderEvent is a change of the status or the accrual of an order.
+type OrderEvent struct {
+	ID        uint64    `json:"-"`
+	Number    string    `json:"number"`
+	Status    string    `json:"status"`
+	Accrual   float32   `json:"accrual"`
+	ChangedAt time.Time `json:"changed_at"` //nolint:tagliatelle
+	Username  string    `json:"-"`
+}
+
+// Subscription receives the events of a user until it is cancelled or the bus is closed.
+type Subscription struct {
+	C        <-chan OrderEvent
+	events   chan OrderEvent
+	username string
+}
+
+// Bus fans out the events to the subscriptions of their users.
+type Bus struct {
+	mu            sync.Mutex
+	lastID        uint64
+	closed        bool
+	subscriptions map[string]map[*Subscription]struct{}
+}
+
+// NewBus creates an instance of Bus.
+func NewBus() *Bus {
+	return &Bus{mu: sync.Mutex{}, lastID: 0, closed: false, subscriptions: make(map[string]map[*Subscription]struct{})}
+}
+
+var defaultBus = NewBus()
+
+// Default returns the bus of the process.
+func Default() *Bus {
+	return defaultBus
+}
+
+// Subscribe subscribes to the events of the user,
+// the channel of the subscription is closed at once if the bus is closed.
+func (b *Bus) Subscribe(username string) *Subscription {
+	events := make(chan OrderEvent, SubscriptionBuffer)
+	sub := &Subscription{C: events, events: events, username: username}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(events)
+
+		return sub
+	}
+	if b.subscriptions[username] == nil {
+		b.subscriptions[username] = make(map[*Subscription]struct{})
+	}
+	b.subscriptions[username][sub] = struct{}{}
+	metrics.EventSubscribed()
+
+	return sub
+}
+
+// Unsubscribe cancels the subscription and closes its channel.
+func (b *Bus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	subs, ok := b.subscriptions[sub.username]
+	if !ok {
+		return
+	}
+	if _, ok = subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.subscriptions, sub.username)
+	}
+	close(sub.events)
+	metrics.EventUnsubscribed()
+}
+
+// Publish sends the event to the subscriptions of its user and returns the event with ID set.
+// It never blocks, the event is dropped for a subscription with the full buffer.
+func (b *Bus) Publish(event OrderEvent) OrderEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastID++
+	event.ID = b.lastID
+	if event.ChangedAt.IsZero() {
+		event.ChangedAt = time.Now()
+	}
+	for sub := range b.subscriptions[event.Username] {
+		select {
+		case sub.events <- event:
+		default:
+			metrics.ObserveEventDropped()
+		}
+	}
+
+	return event
+}
+
+// Close closes all the subscriptions, e.g. to finish the streams on shutdown.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for username, subs := range b.subscriptions {
+		for sub := range subs {
+			close(sub.events)
+			metrics.EventUnsubscribed()
+		}
+		delete(b.subscriptions, username)
+	}
+}
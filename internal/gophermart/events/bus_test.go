@@ -0,0 +1,107 @@
+package events_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBusPublish(t *testing.T) {
+	bus := events.NewBus()
+	sub1 := bus.Subscribe("user1")
+	sub2 := bus.Subscribe("user1")
+	other := bus.Subscribe("user2")
+	defer bus.Unsubscribe(other)
+
+	published := bus.Publish(events.OrderEvent{ //nolint:exhaustruct
+		Number: "79927398713", Status: "PROCESSED", Accrual: 500, Username: "user1",
+	})
+	assert.Equal(t, uint64(1), published.ID)
+	assert.False(t, published.ChangedAt.IsZero())
+
+	for _, sub := range []*events.Subscription{sub1, sub2} {
+		select {
+		case got := <-sub.C:
+			assert.Equal(t, published, got)
+		default:
+			require.Fail(t, "the event is not delivered")
+		}
+	}
+	assert.Empty(t, other.C)
+
+	bus.Unsubscribe(sub1)
+	_, ok := <-sub1.C
+	assert.False(t, ok)
+	assert.NotPanics(t, func() { bus.Unsubscribe(sub1) })
+
+	next := bus.Publish(events.OrderEvent{ //nolint:exhaustruct
+		Number: "79927398713", Status: "INVALID", Username: "user1",
+	})
+	assert.Equal(t, uint64(2), next.ID)
+	assert.Equal(t, next, <-sub2.C)
+	bus.Unsubscribe(sub2)
+}
+
+func TestBusDropsForSlowSubscriber(t *testing.T) {
+	bus := events.NewBus()
+	sub := bus.Subscribe("user1")
+	defer bus.Unsubscribe(sub)
+
+	for i := 0; i < events.SubscriptionBuffer+5; i++ {
+		bus.Publish(events.OrderEvent{Number: "1", Status: "PROCESSING", Username: "user1"}) //nolint:exhaustruct
+	}
+	assert.Len(t, sub.C, events.SubscriptionBuffer)
+	assert.Equal(t, uint64(1), (<-sub.C).ID)
+}
+
+func TestBusClose(t *testing.T) {
+	bus := events.NewBus()
+	sub := bus.Subscribe("user1")
+
+	bus.Close()
+	_, ok := <-sub.C
+	assert.False(t, ok)
+	assert.NotPanics(t, func() { bus.Unsubscribe(sub) })
+	assert.NotPanics(t, bus.Close)
+
+	late := bus.Subscribe("user1")
+	_, ok = <-late.C
+	assert.False(t, ok)
+}
+
+func TestDefault(t *testing.T) {
+	assert.Same(t, events.Default(), events.Default())
+}
+
+func TestBusHandleRecord(t *testing.T) {
+	bus := events.NewBus()
+	sub := bus.Subscribe("user1")
+	defer bus.Unsubscribe(sub)
+
+	changedAt := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+	err := bus.HandleRecord(context.Background(), events.Record{
+		ID: 1, Type: events.TypeOrderUpdated, Username: "user1", CreatedAt: changedAt,
+		Payload: []byte(`{"number":"79927398713","status":"PROCESSED","accrual":500,"changed_at":"2023-11-14T22:13:20Z"}`),
+	})
+	require.NoError(t, err)
+	require.Len(t, sub.C, 1)
+	got := <-sub.C
+	assert.Equal(t, events.OrderEvent{
+		ID: 1, Number: "79927398713", Status: "PROCESSED", Accrual: 500, ChangedAt: changedAt, Username: "user1",
+	}, got)
+
+	err = bus.HandleRecord(context.Background(), events.Record{ //nolint:exhaustruct
+		ID: 2, Type: events.TypeWithdrawalCreated, Username: "user1", Payload: []byte(`{}`),
+	})
+	require.NoError(t, err)
+	assert.Empty(t, sub.C)
+
+	err = bus.HandleRecord(context.Background(), events.Record{ //nolint:exhaustruct
+		ID: 3, Type: events.TypeOrderUpdated, Username: "user1", Payload: []byte(`{`),
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,42 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Types of the domain events.
+const (
+	TypeOrderUpdated        = "order.updated"
+	TypeWithdrawalCreated   = "withdrawal.created"
+	TypeWithdrawalCancelled = "withdrawal.cancelled"
+	TypeWithdrawalRefunded  = "withdrawal.refunded"
+)
+
+// Record is a domain event written to the outbox in the transaction of the change,
+// Payload is JSON of the changed entity.
+type Record struct {
+	ID        int64
+	Type      string
+	Username  string
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+// HandleRecord publishes an order change of the outbox to the subscriptions,
+// the records of other types are ignored.
+func (b *Bus) HandleRecord(_ context.Context, record Record) error {
+	if record.Type != TypeOrderUpdated {
+		return nil
+	}
+	event := OrderEvent{} //nolint:exhaustruct
+	if err := json.Unmarshal(record.Payload, &event); err != nil {
+		return fmt.Errorf("events: failed to decode record %d: %w", record.ID, err)
+	}
+	event.Username = record.Username
+	b.Publish(event)
+
+	return nil
+}
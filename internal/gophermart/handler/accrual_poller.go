@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/metrics"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/notifier"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository/cooldown"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+	"go.uber.org/zap"
+)
+
+// RunAccrualPoller polls the accrual system for the due orders each AccrualPollInterval until ctx is done.
+// The schedule of the orders is kept in the DB, so each of them is polled by one instance at a time
+// and the pending work survives restarts.
+func (h *BaseHandler) RunAccrualPoller(ctx context.Context) {
+	ticker := time.NewTicker(h.cfg.AccrualPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := h.PollPendingOrders(ctx); err != nil {
+				zap.S().Warnf("accrual poller: %s", err.Error())
+			}
+		}
+	}
+}
+
+// PollPendingOrders claims as many due orders as there are free accrual workers
+// and polls them in background, it returns count of the claimed orders.
+// Nothing is claimed while the accrual system asks to cool down.
+func (h *BaseHandler) PollPendingOrders(ctx context.Context) (int, error) {
+	if _, ok := cooldown.CooldownUntil(); ok {
+		return 0, nil
+	}
+	free := 0
+	for h.accrualWorkers.TryAcquire(1) {
+		free++
+	}
+	if free == 0 {
+		return 0, nil
+	}
+	orders, err := sqldb.ClaimPendingOrders(ctx, h.conn, free, h.cfg.AccrualLease)
+	if err != nil {
+		h.accrualWorkers.Release(int64(free))
+
+		return 0, fmt.Errorf("%w", err)
+	}
+	if unused := free - len(orders); unused > 0 {
+		h.accrualWorkers.Release(int64(unused))
+	}
+	for _, order := range orders {
+		order := order
+		h.goBackground(func() {
+			defer h.accrualWorkers.Release(1)
+			h.pollOrder(ctx, order)
+		})
+	}
+
+	return len(orders), nil
+}
+
+// pollOrder runs SendAccRequest for the claimed order and schedules its next check unless it is final.
+func (h *BaseHandler) pollOrder(ctx context.Context, order accrual.PendingOrder) {
+	polled, err := SendAccRequest(ctx, h.conn, h.accrualClient(), order.Number, order.Username, h.orderBonuses())
+	if err == nil && (polled.Status == accrual.OrderStatusProcessed || polled.Status == accrual.OrderStatusInvalid) {
+		return
+	}
+	h.scheduleOrderCheck(ctx, order, err)
+}
+
+// orderBonuses returns the bonuses of the processed orders by the config.
+func (h *BaseHandler) orderBonuses() *sqldb.OrderBonuses {
+	return &sqldb.OrderBonuses{
+		Multiplier: func(lifetime float32) float32 {
+			tier, _ := h.cfg.TierFor(lifetime)
+
+			return tier.Multiplier
+		},
+		ReferralBonus:      h.cfg.ReferralBonus,
+		ReferralMaxRewards: h.cfg.ReferralMaxRewards,
+	}
+}
+
+// scheduleOrderCheck moves the next check of the order, lastErr is nil if the order is not final yet.
+// Only a failed poll counts as an attempt and grows the backoff,
+// an order which is still processed is checked again after AccrualBackoff.
+func (h *BaseHandler) scheduleOrderCheck(ctx context.Context, order accrual.PendingOrder, lastErr error) {
+	logger := logging.FromContext(ctx).With("order", order.Number)
+	attempts := order.Attempts
+	var delay time.Duration
+	var retryAfter *retryAfterError
+	switch {
+	case errors.As(lastErr, &retryAfter):
+		delay = retryAfter.delay
+		if delay < h.cfg.AccrualBackoff {
+			delay = h.cfg.AccrualBackoff
+		}
+	case lastErr != nil:
+		attempts++
+		delay = withJitter(notifier.Backoff(h.cfg.AccrualBackoff, h.cfg.AccrualMaxBackoff, attempts))
+	default:
+		delay = withJitter(h.cfg.AccrualBackoff)
+	}
+	lastError := ""
+	if lastErr != nil {
+		lastError = lastErr.Error()
+		logger.Infof("accrual poll failed: %s, next check in %s", lastError, delay)
+	}
+
+	flagged, err := sqldb.ScheduleOrderCheck(ctx, h.conn, order.Number,
+		attempts, time.Now().Add(delay), lastError, time.Now().Add(-h.cfg.AccrualMaxAge))
+	if err != nil {
+		logger.Warnf("failed to schedule order check: %s", err.Error())
+
+		return
+	}
+	if flagged {
+		metrics.ObserveOrderFlagged()
+		logger.Warnf("order is not final after %s and %d attempts, flagged for manual review",
+			h.cfg.AccrualMaxAge, attempts)
+	}
+}
+
+// withJitter returns a random delay in [delay/2, delay), so the orders failed at once are not retried at once.
+func withJitter(delay time.Duration) time.Duration {
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+
+	return half + time.Duration(rand.Int63n(int64(half))) //nolint:gosec
+}
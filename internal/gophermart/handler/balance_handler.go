@@ -1,25 +1,40 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository"
 	"github.com/labstack/echo/v4"
-	"go.uber.org/zap"
 )
 
-// BalanceHandler handles GET `/api/user/balance`.
+// AdjustmentRequest is a body of an adjustment of a balance,
+// a positive amount is added to the balance and a negative one is taken from it.
+type AdjustmentRequest struct {
+	Amount float32 `json:"amount"`
+	Reason string  `json:"reason"`
+}
+
+// BalanceHandler handles GET `/api/user/balance`,
+// the points expiring within PointsExpiringSoon are shown if the points expire.
 func (h *BaseHandler) BalanceHandler(ctx echo.Context) error {
 	username := GetAuthFromCtx(ctx)
-	zap.S().Infoln("BalanceHandler:", "username:", username)
+	logging.FromEcho(ctx).Infoln("BalanceHandler:", "username:", username)
 
-	balance, err := repository.GetBalance(h.conn, username)
+	balance, err := repository.GetBalance(ctx.Request().Context(), h.conn, username)
 	if err != nil {
-		zap.S().Warnf("BalanceHandler: internal error %s", err.Error())
-		_ = ctx.NoContent(http.StatusInternalServerError)
-
-		return nil
+		return problem.Internal(err)
+	}
+	if h.cfg.PointsExpireAfter > 0 {
+		balance.ExpiringSoon, err = repository.GetExpiringPoints(ctx.Request().Context(), h.conn, username,
+			h.cfg.PointsExpireAfter, time.Now().Add(h.cfg.PointsExpiringSoon))
+		if err != nil {
+			return problem.Internal(err)
+		}
 	}
 
 	if err = ctx.JSON(http.StatusOK, balance); err != nil {
@@ -30,3 +45,30 @@ func (h *BaseHandler) BalanceHandler(ctx echo.Context) error {
 
 	return nil
 }
+
+// BalanceAdjustHandler handles POST `/admin/users/:login/adjustments`,
+// the adjustment is written to the ledger and the new balance of the user is returned.
+func (h *BaseHandler) BalanceAdjustHandler(ctx echo.Context) error {
+	request := AdjustmentRequest{} //nolint:exhaustruct
+	if err := ctx.Bind(&request); err != nil {
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidAdjustment, "failed to parse adjustment", err)
+	}
+	username := ctx.Param("login")
+	balance, err := repository.AdjustBalance(ctx.Request().Context(), h.conn, username, request.Amount, request.Reason)
+	switch {
+	case errors.Is(err, repository.ErrAdjustmentInvalid):
+		return problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidAdjustment,
+			"the amount and the reason are required", err)
+	case errors.Is(err, repository.ErrUserNameNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeNotFound, "user not found", err)
+	case errors.Is(err, repository.ErrAdjustmentNoMoney):
+		return problem.New(http.StatusPaymentRequired, problem.CodeInsufficientFunds,
+			"there are not enough points to take", err)
+	case err != nil:
+		return problem.Internal(err)
+	}
+	logging.FromEcho(ctx).Infoln("BalanceAdjustHandler:", "user:", username, "amount:", request.Amount,
+		"reason:", request.Reason)
+
+	return writeJSON(ctx, balance)
+}
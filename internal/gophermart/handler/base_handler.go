@@ -2,41 +2,113 @@ package handler
 
 import (
 	"fmt"
+	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/config"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/events"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+	"github.com/go-resty/resty/v2"
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"golang.org/x/sync/semaphore"
 )
 
 // BaseHandler holds *pgx.Conn.
 type BaseHandler struct {
 	conn *sqldb.PgxIface
 	cfg  config.Config
+	// accrualWorkers limits background requests to the accrual system.
+	accrualWorkers *semaphore.Weighted
+	// bus delivers changes of orders to the event streams.
+	bus *events.Bus
+	// background tracks the goroutines started by the handlers.
+	background sync.WaitGroup
 }
 
 // NewBaseHandler returns a new BaseHandler.
 func NewBaseHandler(dbConn *sqldb.PgxIface, cfg config.Config) *BaseHandler {
+	workers := cfg.AccrualWorkers
+	if workers < 1 {
+		workers = config.DefaultAccrualWorkers
+	}
+
 	return &BaseHandler{
-		conn: dbConn,
-		cfg:  cfg,
+		conn:           dbConn,
+		cfg:            cfg,
+		accrualWorkers: semaphore.NewWeighted(int64(workers)),
+		bus:            events.Default(),
 	}
 }
 
-func AddAuthHeaders(ctx echo.Context, message string) {
-	auth := fmt.Sprintf("Authorization:[%s]", message)
-	ctx.Response().Header().Add("Authorization", auth)
-	ctx.Response().Header().Add("Set-Cookie", auth)
+// goBackground runs f in a goroutine which Wait waits for.
+func (h *BaseHandler) goBackground(f func()) {
+	h.background.Add(1)
+	go func() {
+		defer h.background.Done()
+		f()
+	}()
+}
+
+// Wait waits for the background requests to the accrual system started by the handlers.
+func (h *BaseHandler) Wait() {
+	h.background.Wait()
+}
+
+// accrualClient returns a client of the accrual system.
+func (h *BaseHandler) accrualClient() *resty.Client {
+	return resty.New().
+		SetBaseURL(h.cfg.Accrual).
+		SetTimeout(h.cfg.AccrualTimeout).
+		SetTransport(otelhttp.NewTransport(http.DefaultTransport))
 }
 
-func WrapHandlerErr(ctx echo.Context, statusCode int, msg string, errIn error) error {
-	err := ctx.String(statusCode, fmt.Sprintf(msg, errIn))
-	if err != nil {
-		err = fmt.Errorf("%w", err)
+// AuthCookieName is the name of the cookie which carries the authorization.
+const AuthCookieName = "Authorization"
+
+// CookieOptions are attributes of the auth cookie.
+type CookieOptions struct {
+	TTL      time.Duration
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// NewCookieOptions returns CookieOptions configured by cfg,
+// the cookie is Secure when the server serves HTTPS or cfg forces it.
+func NewCookieOptions(cfg config.Config) CookieOptions {
+	sameSite := http.SameSiteLaxMode
+	switch strings.ToLower(cfg.CookieSameSite) {
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
 	}
 
-	return err
+	return CookieOptions{
+		TTL:      cfg.AuthTokenTTL,
+		Secure:   cfg.TLSEnabled() || cfg.CookieSecure,
+		SameSite: sameSite,
+	}
+}
+
+// AddAuthHeaders adds the authorization to 'Authorization' header
+// and to HttpOnly cookie of the response.
+func AddAuthHeaders(ctx echo.Context, message string, opts CookieOptions) {
+	auth := fmt.Sprintf("Authorization:[%s]", message)
+	ctx.Response().Header().Add("Authorization", auth)
+	ctx.SetCookie(&http.Cookie{ //nolint:exhaustruct
+		Name:     AuthCookieName,
+		Value:    auth,
+		Path:     "/",
+		Expires:  time.Now().Add(opts.TTL).UTC(),
+		MaxAge:   int(opts.TTL.Seconds()),
+		HttpOnly: true,
+		Secure:   opts.Secure,
+		SameSite: opts.SameSite,
+	})
 }
 
 var ErrUnauthorised = fmt.Errorf("unauthorized request")
@@ -49,22 +121,29 @@ func IsAuthorized(ctx echo.Context, dbConn *sqldb.PgxIface) bool {
 		return false
 	}
 
-	cred, _ := repository.GetCredentials(dbConn, auth)
+	cred, _ := repository.GetCredentials(ctx.Request().Context(), dbConn, auth)
 
 	return cred != nil
 }
 
+// GetAuthFromCtx returns the login from 'Authorization' header or, if there is no header, from the cookie.
 func GetAuthFromCtx(ctx echo.Context) string {
 	authHeader := ctx.Request().Header.Get("Authorization")
 	if authHeader == "" {
-		return ""
+		cookie, err := ctx.Cookie(AuthCookieName)
+		if err != nil {
+			return ""
+		}
+		authHeader = cookie.Value
 	}
 	authValues := strings.Split(authHeader, ":[")
 	if rightLen := 2; len(authValues) != rightLen {
 		return ""
 	}
 	auth := authValues[1]
-	auth = auth[:len(auth)-1]
+	if auth == "" || !strings.HasSuffix(auth, "]") {
+		return ""
+	}
 
-	return auth
+	return auth[:len(auth)-1]
 }
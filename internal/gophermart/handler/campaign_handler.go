@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/campaign"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// CampaignAddHandler handles POST `/admin/campaigns`.
+func (h *BaseHandler) CampaignAddHandler(ctx echo.Context) error {
+	income := campaign.IncomeCampaign{} //nolint:exhaustruct
+	if err := ctx.Bind(&income); err != nil {
+		return problem.New(http.StatusBadRequest, problem.CodeBadRequest, "failed to parse campaign", err)
+	}
+	item, err := repository.AddCampaign(ctx.Request().Context(), h.conn, income, time.Now())
+	switch {
+	case errors.Is(err, repository.ErrCampaignInvalid):
+		return problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidCampaign, err.Error(), err)
+	case errors.Is(err, repository.ErrCampaignExists):
+		return problem.New(http.StatusConflict, problem.CodeCampaignExists,
+			"the name or the code is already used", err)
+	case err != nil:
+		return problem.Internal(err)
+	}
+	logging.FromEcho(ctx).Infoln("CampaignAddHandler:", "id:", item.ID, "name:", item.Name, "kind:", item.Kind)
+
+	return ctx.JSON(http.StatusCreated, item) //nolint:wrapcheck
+}
+
+// CampaignsListHandler handles GET `/admin/campaigns`.
+func (h *BaseHandler) CampaignsListHandler(ctx echo.Context) error {
+	items, err := repository.GetCampaigns(ctx.Request().Context(), h.conn)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return writeJSON(ctx, items)
+}
+
+// PromoHandler handles POST `/api/user/promo`,
+// the bonus of the promo code is credited to the balance once per user.
+func (h *BaseHandler) PromoHandler(ctx echo.Context) error {
+	request := campaign.PromoRequest{Code: ""}
+	if err := ctx.Bind(&request); err != nil {
+		return problem.New(http.StatusBadRequest, problem.CodeBadRequest, "failed to parse promo code", err)
+	}
+	username := GetAuthFromCtx(ctx)
+	redemption, err := repository.RedeemPromo(ctx.Request().Context(), h.conn, username, request.Code, time.Now())
+	switch {
+	case errors.Is(err, repository.ErrPromoInvalid):
+		return problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidPromo, "the code is required", err)
+	case errors.Is(err, repository.ErrPromoNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeNotFound, "promo code not found", err)
+	case errors.Is(err, repository.ErrPromoRedeemed):
+		return problem.New(http.StatusConflict, problem.CodePromoRedeemed,
+			"the promo code is already redeemed", err)
+	case err != nil:
+		return problem.Internal(err)
+	}
+	logging.FromEcho(ctx).Infoln("PromoHandler:", "username:", username, "campaign:", redemption.Campaign,
+		"amount:", redemption.Amount)
+
+	return writeJSON(ctx, redemption)
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/labstack/echo/v4"
+)
+
+// HTTPErrorHandler renders errors of handlers and middlewares
+// as 'application/problem+json' responses.
+func HTTPErrorHandler(err error, ctx echo.Context) {
+	if ctx.Response().Committed {
+		return
+	}
+	logger := logging.FromEcho(ctx)
+	probErr := toProblem(err)
+	if probErr.Status >= http.StatusInternalServerError {
+		logger.Errorf("%s %s: %s", ctx.Request().Method, ctx.Request().URL, err.Error())
+	} else {
+		logger.Warnf("%s %s: %s", ctx.Request().Method, ctx.Request().URL, err.Error())
+	}
+
+	requestID := ctx.Response().Header().Get(echo.HeaderXRequestID)
+	if requestID == "" {
+		requestID = ctx.Request().Header.Get(echo.HeaderXRequestID)
+	}
+	details := probErr.Details(ctx.Request().URL.Path, requestID)
+
+	if ctx.Request().Method == http.MethodHead {
+		err = ctx.NoContent(details.Status)
+	} else {
+		ctx.Response().Header().Set(echo.HeaderContentType, problem.MIMEProblemJSON)
+		err = ctx.JSON(details.Status, details)
+	}
+	if err != nil {
+		logger.Warnf("HTTPErrorHandler: failed to write a response: %s", err.Error())
+	}
+}
+
+func toProblem(err error) *problem.Error {
+	var probErr *problem.Error
+	if errors.As(err, &probErr) {
+		return probErr
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		msg := http.StatusText(httpErr.Code)
+		if httpErr.Code < http.StatusInternalServerError {
+			if strMsg, ok := httpErr.Message.(string); ok {
+				msg = strMsg
+			}
+		}
+
+		return problem.New(httpErr.Code, problem.CodeByStatus(httpErr.Code), msg, fmt.Errorf("%w", err))
+	}
+
+	return problem.Internal(err)
+}
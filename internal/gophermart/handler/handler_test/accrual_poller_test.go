@@ -0,0 +1,143 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/config"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository/cooldown"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pendingOrderColumns = []string{"number", "status", "accrual", "username", "uploaded_at", "attempts"}
+
+func newPollerHandler(
+	t *testing.T, accrualStatus int, accrualBody string,
+) (*handler.BaseHandler, pgxmock.PgxConnIface, *config.Config) {
+	t.Helper()
+	accrualSystem := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Header().Set("Retry-After", "1")
+		writer.WriteHeader(accrualStatus)
+		_, _ = writer.Write([]byte(accrualBody))
+	}))
+	t.Cleanup(accrualSystem.Close)
+
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn sqldb.PgxIface = mock
+	cfg := config.NewConfig()
+	cfg.Accrual = accrualSystem.URL
+	cfg.AccrualWorkers = 3
+
+	return handler.NewBaseHandler(&pgConn, *cfg), mock, cfg
+}
+
+func expectClaimPendingOrders(mock pgxmock.PgxConnIface, cfg *config.Config, attempts int) {
+	mock.ExpectQuery("WITH due AS").
+		WithArgs("NEW", "PROCESSING", 3, cfg.AccrualLease.Seconds()).
+		WillReturnRows(pgxmock.NewRows(pendingOrderColumns).
+			AddRow("79927398713", "NEW", float32(0), "login2", time.Now(), attempts))
+}
+
+// checkAtArg matches the time of the next check scheduled within [least, most) from now.
+type checkAtArg struct {
+	start time.Time
+	least time.Duration
+	most  time.Duration
+}
+
+func (a checkAtArg) Match(value interface{}) bool {
+	checkAt, ok := value.(time.Time)
+
+	return ok && !checkAt.Before(a.start.Add(a.least)) && checkAt.Before(time.Now().Add(a.most))
+}
+
+func waitForExpectations(t *testing.T, mock pgxmock.PgxConnIface) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		return mock.ExpectationsWereMet() == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestPollPendingOrdersNoOrders(t *testing.T) {
+	baseH, mock, cfg := newPollerHandler(t, http.StatusOK, "")
+	mock.ExpectQuery("WITH due AS").
+		WithArgs("NEW", "PROCESSING", 3, cfg.AccrualLease.Seconds()).
+		WillReturnRows(pgxmock.NewRows(pendingOrderColumns))
+	mock.ExpectQuery("WITH due AS").
+		WithArgs("NEW", "PROCESSING", 3, cfg.AccrualLease.Seconds()).
+		WillReturnError(http.ErrAbortHandler)
+
+	claimed, err := baseH.PollPendingOrders(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, claimed)
+	// the workers are released, so the same count of orders is claimed again.
+	_, err = baseH.PollPendingOrders(context.Background())
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPollPendingOrdersProcessing(t *testing.T) {
+	baseH, mock, cfg := newPollerHandler(t, http.StatusOK,
+		`{"order":"79927398713","status":"PROCESSING"}`)
+	expectClaimPendingOrders(mock, cfg, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE orders SET status").WithArgs("PROCESSING", float32(0), "79927398713").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs("order.updated", "login2", pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	// the order is not failed, so the attempts and the backoff do not grow.
+	checkAt := checkAtArg{start: time.Now(), least: cfg.AccrualBackoff / 2, most: cfg.AccrualBackoff}
+	mock.ExpectQuery("UPDATE orders SET attempts").
+		WithArgs("79927398713", 2, checkAt, "", pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"needs_review"}).AddRow(false))
+
+	claimed, err := baseH.PollPendingOrders(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, claimed)
+	waitForExpectations(t, mock)
+}
+
+func TestPollPendingOrdersFailed(t *testing.T) {
+	baseH, mock, cfg := newPollerHandler(t, http.StatusInternalServerError, "")
+	expectClaimPendingOrders(mock, cfg, 0)
+	mock.ExpectQuery("UPDATE orders SET attempts").
+		WithArgs("79927398713", 1, pgxmock.AnyArg(), "unexpected status of the accrual system: 500", pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"needs_review"}).AddRow(true))
+
+	_, err := baseH.PollPendingOrders(context.Background())
+	require.NoError(t, err)
+	waitForExpectations(t, mock)
+}
+
+func TestPollPendingOrdersTooManyRequests(t *testing.T) {
+	defer func() {
+		cooldown.NeedAccrualCooldown(-2)
+		cooldown.IsAccrualReady()
+	}()
+	baseH, mock, cfg := newPollerHandler(t, http.StatusTooManyRequests, "")
+	expectClaimPendingOrders(mock, cfg, 2)
+	// the attempt is not counted.
+	mock.ExpectQuery("UPDATE orders SET attempts").
+		WithArgs("79927398713", 2, pgxmock.AnyArg(), "accrual system asks to retry after 1s", pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"needs_review"}).AddRow(false))
+
+	_, err := baseH.PollPendingOrders(context.Background())
+	require.NoError(t, err)
+	waitForExpectations(t, mock)
+
+	// nothing is claimed during the cooldown.
+	claimed, err := baseH.PollPendingOrders(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, claimed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
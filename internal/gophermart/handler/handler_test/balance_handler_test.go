@@ -10,6 +10,7 @@ import (
 
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/config"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
 	"github.com/labstack/echo/v4"
 	"github.com/pashagolub/pgxmock/v2"
@@ -28,13 +29,9 @@ func TestBalanceHandler(t *testing.T) {
 		require.NoError(t, err)
 	}(mock, context.Background())
 
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(accrual\\),0\\)").
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\)").
 		WithArgs("login2").
-		WillReturnRows(mock.NewRows([]string{"accrual"}).AddRow(float32(44)))
-
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(sum\\),0\\)").
-		WithArgs("login2").
-		WillReturnRows(pgxmock.NewRows([]string{"sum"}).AddRow(float32(2)))
+		WillReturnRows(pgxmock.NewRows([]string{"current", "withdrawn"}).AddRow(float32(42), float32(2)))
 
 	var pgConn sqldb.PgxIface = mock
 
@@ -83,7 +80,7 @@ func TestBalanceHandlerInternalErr(t *testing.T) {
 		require.NoError(t, err)
 	}(mock, context.Background())
 
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(accrual\\),0\\)").
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\)").
 		WithArgs("login2").
 		WillReturnError(io.EOF)
 
@@ -106,7 +103,8 @@ func TestBalanceHandlerInternalErr(t *testing.T) {
 	baseH := handler.NewBaseHandler(&pgConn, *cfg)
 
 	err = baseH.BalanceHandler(ctx)
-	assert.NoError(t, err)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 
@@ -128,13 +126,9 @@ func TestBalanceHandlerErr(t *testing.T) {
 		require.NoError(t, err)
 	}(mock, context.Background())
 
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(accrual\\),0\\)").
-		WithArgs("login2").
-		WillReturnRows(mock.NewRows([]string{"accrual"}).AddRow(float32(44)))
-
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(sum\\),0\\)").
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\)").
 		WithArgs("login2").
-		WillReturnRows(pgxmock.NewRows([]string{"sum"}).AddRow(float32(2)))
+		WillReturnRows(pgxmock.NewRows([]string{"current", "withdrawn"}).AddRow(float32(42), float32(2)))
 
 	var pgConn sqldb.PgxIface = mock
 
@@ -160,3 +154,71 @@ func TestBalanceHandlerErr(t *testing.T) {
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
+
+func TestBalanceAdjustHandler(t *testing.T) {
+	baseH, mock, ctx, rec := newAuthContext(t, http.MethodPost, `{"amount":10,"reason":"compensation"}`, "")
+	ctx.SetParamNames("login")
+	ctx.SetParamValues(loginNameTestingWebhook)
+	mock.ExpectQuery("select name, password from mart_users").WithArgs(loginNameTestingWebhook).
+		WillReturnRows(pgxmock.NewRows([]string{"name", "password"}).AddRow(loginNameTestingWebhook, "hash"))
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT 1 FROM mart_users WHERE name = \\$1 FOR UPDATE").WithArgs(loginNameTestingWebhook).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\)").WithArgs(loginNameTestingWebhook).
+		WillReturnRows(pgxmock.NewRows([]string{"current", "withdrawn"}).AddRow(float32(42), float32(2)))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("ADJUSTMENTS", loginNameTestingWebhook, "USER", loginNameTestingWebhook, float32(10),
+			"ADJUSTMENT", "adjustment", "", "compensation").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\)").WithArgs(loginNameTestingWebhook).
+		WillReturnRows(pgxmock.NewRows([]string{"current", "withdrawn"}).AddRow(float32(52), float32(2)))
+
+	require.NoError(t, baseH.BalanceAdjustHandler(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"current":52,"withdrawn":2}`, rec.Body.String())
+}
+
+func TestBalanceAdjustHandlerErrors(t *testing.T) {
+	baseH, _, ctx, rec := newAuthContext(t, http.MethodPost, `{"amount":10}`, "")
+	ctx.SetParamNames("login")
+	ctx.SetParamValues(loginNameTestingWebhook)
+
+	err := baseH.BalanceAdjustHandler(ctx)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), problem.CodeInvalidAdjustment)
+
+	baseH, mock, ctx, rec := newAuthContext(t, http.MethodPost, `{"amount":10,"reason":"compensation"}`, "")
+	ctx.SetParamNames("login")
+	ctx.SetParamValues("nobody")
+	mock.ExpectQuery("select name, password from mart_users").WithArgs("nobody").
+		WillReturnRows(pgxmock.NewRows([]string{"name", "password"}))
+
+	err = baseH.BalanceAdjustHandler(ctx)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	baseH, mock, ctx, rec = newAuthContext(t, http.MethodPost, `{"amount":-50,"reason":"chargeback"}`, "")
+	ctx.SetParamNames("login")
+	ctx.SetParamValues(loginNameTestingWebhook)
+	mock.ExpectQuery("select name, password from mart_users").WithArgs(loginNameTestingWebhook).
+		WillReturnRows(pgxmock.NewRows([]string{"name", "password"}).AddRow(loginNameTestingWebhook, "hash"))
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT 1 FROM mart_users WHERE name = \\$1 FOR UPDATE").WithArgs(loginNameTestingWebhook).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\)").WithArgs(loginNameTestingWebhook).
+		WillReturnRows(pgxmock.NewRows([]string{"current", "withdrawn"}).AddRow(float32(42), float32(2)))
+	mock.ExpectRollback()
+
+	err = baseH.BalanceAdjustHandler(ctx)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusPaymentRequired, rec.Code)
+	assert.Contains(t, rec.Body.String(), problem.CodeInsufficientFunds)
+}
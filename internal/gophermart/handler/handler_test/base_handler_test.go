@@ -1,11 +1,11 @@
 package handler_test
 
 import (
-	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/config"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
@@ -27,12 +27,63 @@ func TestAddAuthHeaders(t *testing.T) {
 	want := "Authorization:[asdf]"
 	message := "asdf"
 
-	handler.AddAuthHeaders(ctx, message)
+	handler.AddAuthHeaders(ctx, message, handler.CookieOptions{
+		TTL: time.Hour, Secure: true, SameSite: http.SameSiteStrictMode,
+	})
 
 	gotAuth1 := ctx.Response().Header().Get("Authorization")
-	gotAuth2 := ctx.Response().Header().Get("Set-Cookie")
 	assert.Equal(t, want, gotAuth1)
-	assert.Equal(t, want, gotAuth2)
+
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, handler.AuthCookieName, cookies[0].Name)
+	assert.Equal(t, want, cookies[0].Value)
+	assert.Equal(t, "/", cookies[0].Path)
+	assert.Equal(t, 3600, cookies[0].MaxAge)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), cookies[0].Expires, time.Minute)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.True(t, cookies[0].Secure)
+	assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+}
+
+func TestNewCookieOptions(t *testing.T) {
+	cfg := config.NewConfig()
+	got := handler.NewCookieOptions(*cfg)
+	assert.Equal(t, handler.CookieOptions{
+		TTL: config.DefaultAuthTokenTTL, Secure: false, SameSite: http.SameSiteLaxMode,
+	}, got)
+
+	cfg.TLSCertFile = "cert.pem"
+	cfg.TLSKeyFile = "key.pem"
+	cfg.CookieSameSite = "Strict"
+	assert.True(t, handler.NewCookieOptions(*cfg).Secure)
+	assert.Equal(t, http.SameSiteStrictMode, handler.NewCookieOptions(*cfg).SameSite)
+
+	cfg = config.NewConfig()
+	cfg.CookieSecure = true
+	cfg.CookieSameSite = "none"
+	assert.True(t, handler.NewCookieOptions(*cfg).Secure)
+	assert.Equal(t, http.SameSiteNoneMode, handler.NewCookieOptions(*cfg).SameSite)
+}
+
+func TestGetAuthFromCtxCookie(t *testing.T) {
+	echoFr := echo.New()
+	defer echoFr.Close()
+
+	req := httptest.NewRequest(echo.GET, "http://localhost:1323/api/user/balance", nil)
+	req.AddCookie(&http.Cookie{Name: handler.AuthCookieName, Value: "Authorization:[login2]"}) //nolint:exhaustruct
+	ctx := echoFr.NewContext(req, httptest.NewRecorder())
+	assert.Equal(t, "login2", handler.GetAuthFromCtx(ctx))
+
+	req = httptest.NewRequest(echo.GET, "http://localhost:1323/api/user/balance", nil)
+	req.Header.Set("Authorization", "Authorization:[login1]")
+	req.AddCookie(&http.Cookie{Name: handler.AuthCookieName, Value: "Authorization:[login2]"}) //nolint:exhaustruct
+	ctx = echoFr.NewContext(req, httptest.NewRecorder())
+	assert.Equal(t, "login1", handler.GetAuthFromCtx(ctx))
+
+	req = httptest.NewRequest(echo.GET, "http://localhost:1323/api/user/balance", nil)
+	ctx = echoFr.NewContext(req, httptest.NewRecorder())
+	assert.Equal(t, "", handler.GetAuthFromCtx(ctx))
 }
 
 func TestNewBaseHandler(t *testing.T) {
@@ -62,51 +113,6 @@ func TestNewBaseHandler(t *testing.T) {
 	}
 }
 
-var errIn1 = fmt.Errorf("error")
-
-func TestWrapHandlerErr(t *testing.T) {
-	echoFr := echo.New()
-	req := httptest.NewRequest(echo.GET, "http://localhost:1323/admin/user_points/settings", nil)
-	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
-
-	rec := httptest.NewRecorder()
-	ctx := echoFr.NewContext(req, rec)
-	defer echoFr.Close()
-	type args struct {
-		ctx        echo.Context
-		statusCode int
-		msg        string
-		errIn      error
-	}
-	tests := []struct {
-		name    string
-		args    args
-		wantErr bool
-	}{
-		{
-			name: "test 500",
-			args: args{
-				statusCode: http.StatusInternalServerError,
-				ctx:        ctx,
-				msg:        "error",
-				errIn:      errIn1,
-			},
-			wantErr: false,
-		},
-	}
-	for _, test := range tests {
-		tt := test
-		t.Run(tt.name, func(t *testing.T) {
-			err := handler.WrapHandlerErr(tt.args.ctx, tt.args.statusCode, tt.args.msg, tt.args.errIn)
-			if tt.wantErr {
-				assert.Error(t, err)
-			} else {
-				assert.NoError(t, err)
-			}
-		})
-	}
-}
-
 type testresponsewriter struct {
 	w http.ResponseWriter
 	// buf  bytes.Buffer
@@ -137,33 +143,6 @@ func (rw *testresponsewriter) Done() (int64, error) {
 	*/
 }
 
-func TestWrapHandlerErrIO(t *testing.T) {
-	echoFr := echo.New()
-	req := httptest.NewRequest(echo.POST, "http://localhost:1323/admin/user_points/settings", nil)
-	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
-	rec := httptest.NewRecorder()
-	ctx := echoFr.NewContext(req, rec)
-	ctx.Response().Writer = &testresponsewriter{w: rec} //nolint:exhaustruct
-
-	type args struct {
-		ctx        echo.Context
-		statusCode int
-		msg        string
-		errIn      error
-	}
-
-	arguments := args{
-		statusCode: http.StatusInternalServerError,
-		ctx:        ctx,
-		msg:        "error",
-		errIn:      errIn1,
-	}
-
-	err := handler.WrapHandlerErr(arguments.ctx, arguments.statusCode, arguments.msg, arguments.errIn)
-	assert.Error(t, err)
-	assert.ErrorIs(t, err, io.EOF)
-}
-
 func TestGetAuthFromCtxEmpty(t *testing.T) {
 	echoFr := echo.New()
 	defer func(echoFramework *echo.Echo) {
@@ -182,6 +161,11 @@ func TestGetAuthFromCtxEmpty(t *testing.T) {
 
 	got = handler.GetAuthFromCtx(ctx)
 	assert.Empty(t, got)
+
+	for _, value := range []string{"Authorization:[", "Authorization:[login1"} {
+		ctx.Request().Header.Set("Authorization", value)
+		assert.Empty(t, handler.GetAuthFromCtx(ctx), "malformed %q", value)
+	}
 }
 
 func TestIsAuthorizedFalse(t *testing.T) {
@@ -0,0 +1,190 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCampaignAddHandler(t *testing.T) {
+	body := `{"name":"Double points","kind":"ORDER","multiplier":2,` +
+		`"starts_at":"2026-11-01T00:00:00Z","ends_at":"2026-11-08T00:00:00Z"}`
+	baseH, mock, ctx, rec := newAuthContext(t, http.MethodPost, body, "")
+	startsAt := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	endsAt := startsAt.Add(7 * 24 * time.Hour)
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	mock.ExpectQuery("INSERT INTO campaigns").
+		WithArgs("Double points", "ORDER", "", float32(0), float32(2), startsAt, &endsAt).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "created_at"}).AddRow(int64(3), createdAt))
+
+	require.NoError(t, baseH.CampaignAddHandler(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"id":3,"name":"Double points","kind":"ORDER","multiplier":2,`+
+		`"starts_at":"2026-11-01T00:00:00Z","ends_at":"2026-11-08T00:00:00Z",`+
+		`"created_at":"`+createdAt.Format(time.RFC3339)+`"}`, rec.Body.String())
+}
+
+func TestCampaignAddHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		exists     bool
+		wantStatus int
+	}{
+		{name: "bad json", body: `{`, wantStatus: http.StatusBadRequest},
+		{name: "no name", body: `{"kind":"WELCOME","amount":100}`, wantStatus: http.StatusUnprocessableEntity},
+		{
+			name:       "unknown kind",
+			body:       `{"name":"x","kind":"GIFT","amount":1}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "promo without code",
+			body:       `{"name":"x","kind":"PROMO","amount":1}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "welcome with code",
+			body:       `{"name":"x","kind":"WELCOME","code":"HELLO","amount":100}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "small multiplier",
+			body:       `{"name":"x","kind":"ORDER","multiplier":1}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{name: "no amount", body: `{"name":"x","kind":"WELCOME"}`, wantStatus: http.StatusUnprocessableEntity},
+		{
+			name: "ends before start",
+			body: `{"name":"x","kind":"WELCOME","amount":100,` +
+				`"starts_at":"2026-11-01T00:00:00Z","ends_at":"2026-10-01T00:00:00Z"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "exists",
+			body:       `{"name":"Welcome","kind":"WELCOME","amount":100}`,
+			exists:     true,
+			wantStatus: http.StatusConflict,
+		},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			baseH, mock, ctx, rec := newAuthContext(t, http.MethodPost, test.body, "")
+			if test.exists {
+				mock.ExpectQuery("INSERT INTO campaigns").
+					WithArgs("Welcome", "WELCOME", "", float32(100), float32(1), pgxmock.AnyArg(), pgxmock.AnyArg()).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "created_at"}))
+			}
+
+			if err := baseH.CampaignAddHandler(ctx); err != nil {
+				handler.HTTPErrorHandler(err, ctx)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+			assert.Equal(t, test.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestCampaignsListHandler(t *testing.T) {
+	baseH, mock, ctx, rec := newAuthContext(t, http.MethodGet, "", "")
+	startsAt := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT id, name, kind, COALESCE\\(code, ''\\)").
+		WillReturnRows(pgxmock.NewRows([]string{
+			"id", "name", "kind", "code", "amount", "multiplier", "starts_at", "ends_at", "created_at",
+		}).AddRow(int64(1), "Spring", "PROMO", "SPRING", float32(50), float32(1), startsAt, nil, startsAt))
+
+	require.NoError(t, baseH.CampaignsListHandler(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[{"id":1,"name":"Spring","kind":"PROMO","code":"SPRING","amount":50,"multiplier":1,`+
+		`"starts_at":"2026-11-01T00:00:00Z","created_at":"2026-11-01T00:00:00Z"}]`, rec.Body.String())
+}
+
+func TestPromoHandler(t *testing.T) {
+	baseH, mock, ctx, rec := newAuthContext(t, http.MethodPost, `{"code":"SPRING"}`, "")
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, name, amount FROM campaigns").WithArgs("SPRING", pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "name", "amount"}).AddRow(int64(1), "Spring", float32(50)))
+	mock.ExpectExec("INSERT INTO campaign_redemptions").WithArgs(int64(1), loginNameTestingWebhook).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("CAMPAIGNS", loginNameTestingWebhook, "USER", loginNameTestingWebhook, float32(50),
+			"CAMPAIGN_BONUS", "campaign", "1", "Spring").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectCommit()
+
+	require.NoError(t, baseH.PromoHandler(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"campaign":"Spring","amount":50`)
+}
+
+func TestPromoHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		expect     func(mock pgxmock.PgxConnIface)
+		wantStatus int
+	}{
+		{name: "bad json", body: `{`, wantStatus: http.StatusBadRequest},
+		{name: "no code", body: `{"code":""}`, wantStatus: http.StatusUnprocessableEntity},
+		{
+			name: "not found",
+			body: `{"code":"WINTER"}`,
+			expect: func(mock pgxmock.PgxConnIface) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT id, name, amount FROM campaigns").WithArgs("WINTER", pgxmock.AnyArg()).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "name", "amount"}))
+				mock.ExpectCommit()
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "redeemed",
+			body: `{"code":"SPRING"}`,
+			expect: func(mock pgxmock.PgxConnIface) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT id, name, amount FROM campaigns").WithArgs("SPRING", pgxmock.AnyArg()).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "name", "amount"}).AddRow(int64(1), "Spring", float32(50)))
+				mock.ExpectExec("INSERT INTO campaign_redemptions").WithArgs(int64(1), loginNameTestingWebhook).
+					WillReturnResult(pgxmock.NewResult("INSERT", 0))
+				mock.ExpectCommit()
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "db error",
+			body: `{"code":"SPRING"}`,
+			expect: func(mock pgxmock.PgxConnIface) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT id, name, amount FROM campaigns").WithArgs("SPRING", pgxmock.AnyArg()).
+					WillReturnError(io.EOF)
+				mock.ExpectRollback()
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			baseH, mock, ctx, rec := newAuthContext(t, http.MethodPost, test.body, "")
+			if test.expect != nil {
+				test.expect(mock)
+			}
+
+			if err := baseH.PromoHandler(ctx); err != nil {
+				handler.HTTPErrorHandler(err, ctx)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+			assert.Equal(t, test.wantStatus, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPErrorHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantDetail string
+	}{
+		{
+			name:       "problem",
+			err:        problem.New(http.StatusPaymentRequired, problem.CodeInsufficientFunds, "no money", io.EOF),
+			wantStatus: http.StatusPaymentRequired,
+			wantCode:   problem.CodeInsufficientFunds,
+			wantDetail: "no money",
+		},
+		{
+			name:       "echo error",
+			err:        echo.ErrNotFound,
+			wantStatus: http.StatusNotFound,
+			wantCode:   problem.CodeNotFound,
+			wantDetail: "Not Found",
+		},
+		{
+			name:       "unknown error",
+			err:        io.EOF,
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   problem.CodeInternal,
+			wantDetail: "internal server error",
+		},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			echoFr := echo.New()
+			defer echoFr.Close()
+			req := httptest.NewRequest(echo.GET, "http://localhost:1323/api/user/balance", nil)
+			req.Header.Set(echo.HeaderXRequestID, "42")
+			rec := httptest.NewRecorder()
+			ctx := echoFr.NewContext(req, rec)
+
+			handler.HTTPErrorHandler(test.err, ctx)
+
+			assert.Equal(t, test.wantStatus, rec.Code)
+			assert.Equal(t, problem.MIMEProblemJSON, rec.Header().Get(echo.HeaderContentType))
+			got := problem.Details{} //nolint:exhaustruct
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			want := problem.Details{
+				Type:      "urn:gophermart:problem:" + test.wantCode,
+				Title:     http.StatusText(test.wantStatus),
+				Status:    test.wantStatus,
+				Detail:    test.wantDetail,
+				Instance:  "/api/user/balance",
+				Code:      test.wantCode,
+				RequestID: "42",
+			}
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestHTTPErrorHandlerHead(t *testing.T) {
+	echoFr := echo.New()
+	defer echoFr.Close()
+	req := httptest.NewRequest(echo.HEAD, "http://localhost:1323/", nil)
+	rec := httptest.NewRecorder()
+	ctx := echoFr.NewContext(req, rec)
+
+	handler.HTTPErrorHandler(echo.ErrUnauthorized, ctx)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestHTTPErrorHandlerCommitted(t *testing.T) {
+	echoFr := echo.New()
+	defer echoFr.Close()
+	req := httptest.NewRequest(echo.GET, "http://localhost:1323/", nil)
+	rec := httptest.NewRecorder()
+	ctx := echoFr.NewContext(req, rec)
+	require.NoError(t, ctx.NoContent(http.StatusOK))
+
+	handler.HTTPErrorHandler(io.EOF, ctx)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestHTTPErrorHandlerWriteErr(t *testing.T) {
+	echoFr := echo.New()
+	defer echoFr.Close()
+	req := httptest.NewRequest(echo.GET, "http://localhost:1323/", nil)
+	rec := httptest.NewRecorder()
+	ctx := echoFr.NewContext(req, rec)
+	ctx.Response().Writer = &testresponsewriter{w: rec} //nolint:exhaustruct
+
+	assert.NotPanics(t, func() {
+		handler.HTTPErrorHandler(io.EOF, ctx)
+	})
+}
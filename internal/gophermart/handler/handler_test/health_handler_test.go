@@ -0,0 +1,132 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/config"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository/cooldown"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+	"github.com/labstack/echo/v4"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthzHandler(t *testing.T) {
+	echoFr := echo.New()
+	defer echoFr.Close()
+	req := httptest.NewRequest(echo.GET, "http://localhost:1323/healthz", nil)
+	rec := httptest.NewRecorder()
+	ctx := echoFr.NewContext(req, rec)
+
+	baseH := handler.NewBaseHandler(nil, *config.NewConfig())
+	err := baseH.HealthzHandler(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "{\"status\":\"ok\"}\n", rec.Body.String())
+}
+
+func callReadyz(t *testing.T, pgConn *sqldb.PgxIface) (int, handler.Readiness) {
+	t.Helper()
+	echoFr := echo.New()
+	defer echoFr.Close()
+	req := httptest.NewRequest(echo.GET, "http://localhost:1323/readyz", nil)
+	rec := httptest.NewRecorder()
+	ctx := echoFr.NewContext(req, rec)
+
+	baseH := handler.NewBaseHandler(pgConn, *config.NewConfig())
+	err := baseH.ReadyzHandler(ctx)
+	require.NoError(t, err)
+
+	got := handler.Readiness{} //nolint:exhaustruct
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+
+	return rec.Code, got
+}
+
+func TestReadyzHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		pingErr      error
+		version      int64
+		dirty        bool
+		cooldown     bool
+		wantStatus   int
+		wantReadyz   string
+		wantDB       string
+		wantMigrate  string
+		wantAccrual  string
+		skipVersions bool
+	}{
+		{
+			name: "ready", version: sqldb.SchemaVersion,
+			wantStatus: http.StatusOK, wantReadyz: "ok",
+			wantDB: "ok", wantMigrate: "ok", wantAccrual: "ok",
+		},
+		{
+			name: "accrual cooldown", version: sqldb.SchemaVersion, cooldown: true,
+			wantStatus: http.StatusOK, wantReadyz: "degraded",
+			wantDB: "ok", wantMigrate: "ok", wantAccrual: "degraded",
+		},
+		{
+			name: "old schema", version: sqldb.SchemaVersion - 1,
+			wantStatus: http.StatusServiceUnavailable, wantReadyz: "fail",
+			wantDB: "ok", wantMigrate: "fail", wantAccrual: "ok",
+		},
+		{
+			name: "dirty schema", version: sqldb.SchemaVersion, dirty: true,
+			wantStatus: http.StatusServiceUnavailable, wantReadyz: "fail",
+			wantDB: "ok", wantMigrate: "fail", wantAccrual: "ok",
+		},
+		{
+			name: "no db", pingErr: io.EOF, skipVersions: true,
+			wantStatus: http.StatusServiceUnavailable, wantReadyz: "fail",
+			wantDB: "fail", wantMigrate: "fail", wantAccrual: "ok",
+		},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			mock, err := pgxmock.NewConn(pgxmock.MonitorPingsOption(true))
+			require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
+			defer closeMockDB(t, mock)
+			mock.MatchExpectationsInOrder(false)
+			mock.ExpectPing().WillReturnError(test.pingErr)
+			query := mock.ExpectQuery("SELECT version, dirty FROM schema_migrations")
+			if test.skipVersions {
+				query.WillReturnError(io.EOF)
+			} else {
+				query.WillReturnRows(pgxmock.NewRows([]string{"version", "dirty"}).AddRow(test.version, test.dirty))
+			}
+			if test.cooldown {
+				cooldown.NeedAccrualCooldown(100)
+				t.Cleanup(func() {
+					cooldown.NeedAccrualCooldown(-2)
+					cooldown.IsAccrualReady()
+				})
+			}
+			var pgConn sqldb.PgxIface = mock
+
+			gotStatus, got := callReadyz(t, &pgConn)
+			assert.Equal(t, test.wantStatus, gotStatus)
+			assert.Equal(t, test.wantReadyz, got.Status)
+			assert.Equal(t, test.wantDB, got.Checks["db"].Status)
+			assert.Equal(t, test.wantMigrate, got.Checks["migration"].Status)
+			assert.Equal(t, test.wantAccrual, got.Checks["accrual"].Status)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReadyzHandlerNoConnection(t *testing.T) {
+	gotStatus, got := callReadyz(t, nil)
+	assert.Equal(t, http.StatusServiceUnavailable, gotStatus)
+	assert.Equal(t, "fail", got.Status)
+	assert.Equal(t, "no connection", got.Checks["db"].Details)
+}
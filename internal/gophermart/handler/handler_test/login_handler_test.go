@@ -86,7 +86,8 @@ func TestLoginHandlerBadRequest(t *testing.T) {
 	baseH := handler.NewBaseHandler(nil, *cfg)
 
 	err := baseH.LoginHandler(ctx)
-	assert.NoError(t, err)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
 
 	got := rec.Result()
 	defer got.Body.Close()
@@ -132,7 +133,8 @@ func TestLoginHandlerUnauthorizedUserNotFound(t *testing.T) {
 	baseH := handler.NewBaseHandler(&pgConn, *cfg)
 
 	err = baseH.LoginHandler(ctx)
-	assert.NoError(t, err)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 
@@ -181,7 +183,8 @@ func TestLoginHandlerUnauthorizedWrongPassword(t *testing.T) {
 	baseH := handler.NewBaseHandler(&pgConn, *cfg)
 
 	err = baseH.LoginHandler(ctx)
-	assert.NoError(t, err)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 
@@ -227,7 +230,8 @@ func TestLoginHandlerInternalErr(t *testing.T) {
 	baseH := handler.NewBaseHandler(&pgConn, *cfg)
 
 	err = baseH.LoginHandler(ctx)
-	assert.NoError(t, err)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 
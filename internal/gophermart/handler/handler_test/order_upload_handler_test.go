@@ -87,6 +87,7 @@ func TestOrderUploadHandlerStatus200(t *testing.T) {
 
 	err = baseH.OrderUploadHandler(*ctx)
 	assert.NoError(t, err)
+	baseH.Wait()
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 
@@ -136,7 +137,9 @@ func TestOrderUploadHandlerStatusConflict(t *testing.T) {
 	baseH := handler.NewBaseHandler(&pgConn, *cfg)
 
 	err = baseH.OrderUploadHandler(ctx)
-	assert.NoError(t, err)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
+	baseH.Wait()
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 
@@ -171,7 +174,9 @@ func TestOrderUploadHandlerStatusInternalErr(t *testing.T) {
 	baseH := handler.NewBaseHandler(&pgConn, *cfg)
 
 	err = baseH.OrderUploadHandler(*ctx)
-	assert.NoError(t, err)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, *ctx)
+	baseH.Wait()
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 
@@ -211,6 +216,7 @@ func TestOrderUploadHandlerStatusAccepted(t *testing.T) {
 
 	err = baseH.OrderUploadHandler(*ctx)
 	assert.NoError(t, err)
+	baseH.Wait()
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 
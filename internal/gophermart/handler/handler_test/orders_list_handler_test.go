@@ -11,6 +11,7 @@ import (
 
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/config"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
 	"github.com/labstack/echo/v4"
 	"github.com/pashagolub/pgxmock/v2"
@@ -107,7 +108,8 @@ func TestOrdersListHandlerStatus500(t *testing.T) {
 	baseH := handler.NewBaseHandler(&pgConn, *cfg)
 
 	err = baseH.OrdersListHandler(ctx)
-	assert.NoError(t, err)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 
@@ -118,7 +120,7 @@ func TestOrdersListHandlerStatus500(t *testing.T) {
 	assert.Equal(t, wantStatusCode, got.StatusCode, "StatusCode got: %v, want: %v", got.StatusCode, wantStatusCode)
 	gotBody, err := io.ReadAll(got.Body)
 	require.NoError(t, err)
-	assert.Empty(t, gotBody)
+	assert.Contains(t, string(gotBody), problem.CodeInternal)
 }
 
 func TestOrdersListHandlerStatusNoContent(t *testing.T) {
@@ -0,0 +1,71 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/config"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/events"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrdersStreamHandler(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.SSEHeartbeat = 50 * time.Millisecond
+	baseH := handler.NewBaseHandler(nil, *cfg)
+
+	echoFr := echo.New()
+	defer echoFr.Close()
+	echoFr.GET("/api/user/orders/stream", baseH.OrdersStreamHandler)
+	server := httptest.NewServer(echoFr)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/user/orders/stream", nil)
+	require.NoError(t, err)
+	req.Header.Add("Authorization", fmt.Sprintf("Authorization:[%s]", "stream_user"))
+
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, handler.MIMETextEventStream, resp.Header.Get(echo.HeaderContentType))
+
+	events.Default().Publish(events.OrderEvent{ //nolint:exhaustruct
+		Number: "79927398713", Status: "PROCESSED", Accrual: 729.98, Username: "another_user",
+	})
+	published := events.Default().Publish(events.OrderEvent{ //nolint:exhaustruct
+		Number: "12345678903", Status: "PROCESSED", Accrual: 500, Username: "stream_user",
+	})
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	heartbeats := 0
+	for len(lines) < 3 || heartbeats == 0 {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == ": heartbeat":
+			heartbeats++
+		case line != "":
+			lines = append(lines, line)
+		}
+	}
+
+	assert.Equal(t, fmt.Sprintf("id: %d", published.ID), lines[0])
+	assert.Equal(t, "event: "+handler.EventOrder, lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "data: "))
+	assert.JSONEq(t, fmt.Sprintf(`{"number":"12345678903","status":"PROCESSED","accrual":500,"changed_at":"%s"}`,
+		published.ChangedAt.Format(time.RFC3339Nano)), strings.TrimPrefix(lines[2], "data: "))
+}
@@ -0,0 +1,72 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/config"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+	"github.com/labstack/echo/v4"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testExpireAfter = 365 * 24 * time.Hour
+
+func newExpiringHandler(t *testing.T) (*handler.BaseHandler, pgxmock.PgxConnIface) {
+	t.Helper()
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn sqldb.PgxIface = mock
+	cfg := config.NewConfig()
+	cfg.PointsExpireAfter = testExpireAfter
+
+	return handler.NewBaseHandler(&pgConn, *cfg), mock
+}
+
+func TestExpirePoints(t *testing.T) {
+	baseH, mock := newExpiringHandler(t)
+	mock.ExpectQuery("SELECT u.name FROM mart_users u WHERE EXISTS").
+		WithArgs(pgxmock.AnyArg(), 100).
+		WillReturnRows(pgxmock.NewRows([]string{"name"}).AddRow(loginNameTestingWebhook))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE mart_users SET points_expired_at").WithArgs(loginNameTestingWebhook, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(remaining\\), 0\\) FROM lots").
+		WithArgs(loginNameTestingWebhook, testExpireAfter.Seconds(), pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"expired"}).AddRow(float32(30)))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("USER", loginNameTestingWebhook, "EXPIRED", loginNameTestingWebhook, float32(30),
+			"EXPIRY", "expiry", "", "").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectCommit()
+
+	require.NoError(t, baseH.ExpirePoints(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBalanceHandlerExpiringSoon(t *testing.T) {
+	baseH, mock := newExpiringHandler(t)
+	day := time.Date(2023, 11, 14, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\)").WithArgs(loginNameTestingWebhook).
+		WillReturnRows(pgxmock.NewRows([]string{"current", "withdrawn"}).AddRow(float32(42), float32(2)))
+	mock.ExpectQuery("GROUP BY day ORDER BY day").
+		WithArgs(loginNameTestingWebhook, testExpireAfter.Seconds(), pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"sum", "day"}).AddRow(float32(10), day))
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost:1323/", strings.NewReader(""))
+	req.Header.Add("Authorization", "Authorization:["+loginNameTestingWebhook+"]")
+	rec := httptest.NewRecorder()
+	ctx := echo.New().NewContext(req, rec)
+
+	require.NoError(t, baseH.BalanceHandler(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.JSONEq(t, `{"current":42,"withdrawn":2,"expiring_soon":[{"amount":10,"date":"2023-11-14T00:00:00Z"}]}`,
+		rec.Body.String())
+}
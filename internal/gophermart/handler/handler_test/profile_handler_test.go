@@ -0,0 +1,63 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		lifetime float32
+		want     string
+	}{
+		{
+			name:     "lowest tier",
+			lifetime: 250,
+			want: `{"login":"login3","lifetime_accrual":250,"tier":"Bronze","multiplier":1,` +
+				`"next_tier":"Silver","next_tier_threshold":1000,"progress":0.25}`,
+		},
+		{
+			name:     "middle tier",
+			lifetime: 3000,
+			want: `{"login":"login3","lifetime_accrual":3000,"tier":"Silver","multiplier":1.05,` +
+				`"next_tier":"Gold","next_tier_threshold":5000,"progress":0.5}`,
+		},
+		{
+			name:     "highest tier",
+			lifetime: 7000,
+			want:     `{"login":"login3","lifetime_accrual":7000,"tier":"Gold","multiplier":1.1,"progress":1}`,
+		},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			baseH, mock, ctx, rec := newAuthContext(t, http.MethodGet, "", "")
+			mock.ExpectQuery("SELECT COALESCE\\(SUM\\(accrual\\), 0\\) FROM orders").WithArgs(loginNameTestingWebhook).
+				WillReturnRows(pgxmock.NewRows([]string{"lifetime"}).AddRow(test.lifetime))
+
+			require.NoError(t, baseH.ProfileHandler(ctx))
+			assert.NoError(t, mock.ExpectationsWereMet())
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.JSONEq(t, test.want, rec.Body.String())
+		})
+	}
+}
+
+func TestProfileHandlerErr(t *testing.T) {
+	baseH, mock, ctx, rec := newAuthContext(t, http.MethodGet, "", "")
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(accrual\\), 0\\) FROM orders").WithArgs(loginNameTestingWebhook).
+		WillReturnError(io.EOF)
+
+	if err := baseH.ProfileHandler(ctx); err != nil {
+		handler.HTTPErrorHandler(err, ctx)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
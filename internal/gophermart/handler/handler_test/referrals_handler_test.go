@@ -0,0 +1,46 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferralsHandler(t *testing.T) {
+	baseH, mock, ctx, rec := newAuthContext(t, http.MethodGet, "", "")
+	registeredAt := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	processedAt := registeredAt.Add(24 * time.Hour)
+	mock.ExpectQuery("INSERT INTO referral_codes").WithArgs(loginNameTestingWebhook, pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"code"}).AddRow("ABCDEFGH"))
+	mock.ExpectQuery("SELECT referee, status, created_at, processed_at FROM referrals").
+		WithArgs(loginNameTestingWebhook).
+		WillReturnRows(pgxmock.NewRows([]string{"referee", "status", "created_at", "processed_at"}).
+			AddRow("login4", "PENDING", registeredAt, nil).
+			AddRow("login2", "REWARDED", registeredAt, &processedAt))
+
+	require.NoError(t, baseH.ReferralsHandler(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"code":"ABCDEFGH","bonus":100,"max_rewards":10,"rewarded":1,"referrals":[`+
+		`{"login":"login4","status":"PENDING","registered_at":"2026-10-01T00:00:00Z"},`+
+		`{"login":"login2","status":"REWARDED","registered_at":"2026-10-01T00:00:00Z",`+
+		`"processed_at":"2026-10-02T00:00:00Z"}]}`, rec.Body.String())
+}
+
+func TestReferralsHandlerErr(t *testing.T) {
+	baseH, mock, ctx, rec := newAuthContext(t, http.MethodGet, "", "")
+	mock.ExpectQuery("INSERT INTO referral_codes").WithArgs(loginNameTestingWebhook, pgxmock.AnyArg()).
+		WillReturnError(io.EOF)
+
+	if err := baseH.ReferralsHandler(ctx); err != nil {
+		handler.HTTPErrorHandler(err, ctx)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
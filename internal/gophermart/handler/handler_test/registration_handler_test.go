@@ -38,6 +38,15 @@ func TestRegistrationHandler(t *testing.T) {
 	mock.ExpectExec("insert into mart_users").
 		WithArgs("login2", pgxmock.AnyArg()).
 		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, name, amount FROM campaigns").WithArgs("WELCOME", pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "name", "amount"}).AddRow(int64(1), "Welcome", float32(100)))
+	mock.ExpectExec("INSERT INTO campaign_redemptions").WithArgs(int64(1), "login2").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("CAMPAIGNS", "login2", "USER", "login2", float32(100), "CAMPAIGN_BONUS", "campaign", "1", "Welcome").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectCommit()
 
 	var pgConn sqldb.PgxIface = mock
 
@@ -107,7 +116,8 @@ func TestRegistrationHandlerAddCredentialsErr(t *testing.T) {
 	baseH := handler.NewBaseHandler(&pgConn, *cfg)
 
 	err = baseH.RegistrationHandler(ctx)
-	assert.NoError(t, err)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 
@@ -157,7 +167,8 @@ func TestRegistrationHandlerBadCredentialsErr(t *testing.T) {
 	baseH := handler.NewBaseHandler(&pgConn, *cfg)
 
 	err = baseH.RegistrationHandler(ctx)
-	assert.NoError(t, err)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 
@@ -209,7 +220,8 @@ func TestRegistrationHandlerNoUserErr(t *testing.T) {
 	baseH := handler.NewBaseHandler(&pgConn, *cfg)
 
 	err = baseH.RegistrationHandler(ctx)
-	assert.Error(t, err)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 
@@ -240,7 +252,8 @@ func TestRegistrationHandlerBadRequest(t *testing.T) {
 	baseH := handler.NewBaseHandler(nil, *cfg)
 
 	err := baseH.RegistrationHandler(ctx)
-	assert.NoError(t, err)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
 
 	wantStatusCode := http.StatusBadRequest
 	assert.Equal(t, wantStatusCode, rec.Code)
@@ -286,7 +299,8 @@ func TestRegistrationHandlerUnknownCredentialsErr(t *testing.T) {
 	baseH := handler.NewBaseHandler(&pgConn, *cfg)
 
 	err = baseH.RegistrationHandler(ctx)
-	assert.NoError(t, err)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 
@@ -298,3 +312,78 @@ func TestRegistrationHandlerUnknownCredentialsErr(t *testing.T) {
 	require.NoError(t, err)
 	assert.Empty(t, gotHeaderA)
 }
+
+func TestRegistrationHandlerWelcomeBonusErr(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	mock.ExpectQuery("select name, password from mart_users where name=\\$1").WithArgs("login2").
+		WillReturnRows(pgxmock.NewRows([]string{"name", "password"}))
+	mock.ExpectExec("insert into mart_users").WithArgs("login2", pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, name, amount FROM campaigns").WithArgs("WELCOME", pgxmock.AnyArg()).
+		WillReturnError(io.EOF)
+	mock.ExpectRollback()
+	var pgConn sqldb.PgxIface = mock
+
+	req := httptest.NewRequest(echo.POST, "http://localhost:1323/api/user/register", strings.NewReader(testLoginRightBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	ctx := echo.New().NewContext(req, rec)
+
+	baseH := handler.NewBaseHandler(&pgConn, *config.NewConfig())
+	require.NoError(t, baseH.RegistrationHandler(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, rec.Code, "the user is registered without the welcome bonus")
+}
+
+func TestRegistrationHandlerReferral(t *testing.T) {
+	tests := []struct {
+		name       string
+		referrer   string
+		wantStatus int
+	}{
+		{name: "referred", referrer: "login3", wantStatus: http.StatusOK},
+		{name: "unknown code", referrer: "", wantStatus: http.StatusUnprocessableEntity},
+		{name: "self referral", referrer: "login2", wantStatus: http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			mock, err := pgxmock.NewConn()
+			require.NoError(t, err)
+			mock.ExpectQuery("select name, password from mart_users where name=\\$1").WithArgs("login2").
+				WillReturnRows(pgxmock.NewRows([]string{"name", "password"}))
+			rows := pgxmock.NewRows([]string{"username"})
+			if test.referrer != "" {
+				rows.AddRow(test.referrer)
+			}
+			mock.ExpectQuery("SELECT username FROM referral_codes").WithArgs("ABCDEFGH").WillReturnRows(rows)
+			if test.wantStatus == http.StatusOK {
+				mock.ExpectExec("insert into mart_users").WithArgs("login2", pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT id, name, amount FROM campaigns").WithArgs("WELCOME", pgxmock.AnyArg()).
+					WillReturnRows(pgxmock.NewRows([]string{"id", "name", "amount"}))
+				mock.ExpectCommit()
+				mock.ExpectExec("INSERT INTO referrals").WithArgs("login2", test.referrer).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			}
+			var pgConn sqldb.PgxIface = mock
+
+			body := `{"login":"login2","password":"password2","referral_code":"ABCDEFGH"}`
+			req := httptest.NewRequest(echo.POST, "http://localhost:1323/api/user/register",
+				strings.NewReader(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			ctx := echo.New().NewContext(req, rec)
+
+			baseH := handler.NewBaseHandler(&pgConn, *config.NewConfig())
+			if err = baseH.RegistrationHandler(ctx); err != nil {
+				handler.HTTPErrorHandler(err, ctx)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+			assert.Equal(t, test.wantStatus, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var transactionColumns = []string{
+	"id", "kind", "amount", "balance", "order", "counterparty", "description", "created_at",
+}
+
+func TestTransactionsHandler(t *testing.T) {
+	baseH, mock, ctx, rec := newAuthContext(t, http.MethodGet, "", "")
+	ctx.Request().URL.RawQuery = "limit=2&after=3"
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	mock.ExpectQuery("WITH entries AS").WithArgs(loginNameTestingWebhook, int64(3), 3).
+		WillReturnRows(pgxmock.NewRows(transactionColumns).
+			AddRow(int64(5), "ACCRUAL", float32(600), float32(600), "79927398713", "", "", createdAt).
+			AddRow(int64(7), "WITHDRAWAL", float32(-100), float32(500), "2377225624", "", "", createdAt).
+			AddRow(int64(9), "TRANSFER", float32(-10), float32(490), "", "login2", "", createdAt))
+
+	require.NoError(t, baseH.TransactionsHandler(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, rec.Code)
+	wantAt := createdAt.Format(time.RFC3339)
+	assert.JSONEq(t, `{"transactions":[`+
+		`{"id":5,"type":"ACCRUAL","amount":600,"balance":600,"order":"79927398713","created_at":"`+wantAt+`"},`+
+		`{"id":7,"type":"WITHDRAWAL","amount":-100,"balance":500,"order":"2377225624","created_at":"`+wantAt+`"}`+
+		`],"next_after":7}`, rec.Body.String())
+}
+
+func TestTransactionsHandlerLastPage(t *testing.T) {
+	baseH, mock, ctx, rec := newAuthContext(t, http.MethodGet, "", "")
+	mock.ExpectQuery("WITH entries AS").WithArgs(loginNameTestingWebhook, int64(0), 51).
+		WillReturnRows(pgxmock.NewRows(transactionColumns).
+			AddRow(int64(5), "ACCRUAL", float32(600), float32(600), "79927398713", "", "", time.Now()))
+
+	require.NoError(t, baseH.TransactionsHandler(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "next_after")
+}
+
+func TestTransactionsHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		rows       *pgxmock.Rows
+		err        error
+		wantStatus int
+	}{
+		{name: "no items", rows: pgxmock.NewRows(transactionColumns), wantStatus: http.StatusNoContent},
+		{name: "db error", err: io.EOF, wantStatus: http.StatusInternalServerError},
+		{name: "big limit", query: "limit=1000", wantStatus: http.StatusBadRequest},
+		{name: "bad after", query: "after=x", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			baseH, mock, ctx, rec := newAuthContext(t, http.MethodGet, "", "")
+			ctx.Request().URL.RawQuery = test.query
+			switch {
+			case test.rows != nil:
+				mock.ExpectQuery("WITH entries AS").WithArgs(loginNameTestingWebhook, int64(0), 51).
+					WillReturnRows(test.rows)
+			case test.err != nil:
+				mock.ExpectQuery("WITH entries AS").WithArgs(loginNameTestingWebhook, int64(0), 51).
+					WillReturnError(test.err)
+			}
+
+			if err := baseH.TransactionsHandler(ctx); err != nil {
+				handler.HTTPErrorHandler(err, ctx)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+			assert.Equal(t, test.wantStatus, rec.Code)
+		})
+	}
+}
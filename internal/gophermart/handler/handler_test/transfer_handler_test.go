@@ -0,0 +1,93 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var transferStateColumns = []string{"recipient_exists", "balance", "sent_sum", "sent_count"}
+
+func expectTransferState(mock pgxmock.PgxConnIface, recipient string, state *pgxmock.Rows) {
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT 1 FROM mart_users WHERE name = \\$1 FOR UPDATE").WithArgs(loginNameTestingWebhook).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(loginNameTestingWebhook, recipient, pgxmock.AnyArg()).
+		WillReturnRows(state)
+}
+
+func TestTransferHandler(t *testing.T) {
+	baseH, mock, ctx, rec := newAuthContext(t, http.MethodPost, `{"recipient":"login2","amount":10}`, "")
+	expectTransferState(mock, "login2",
+		pgxmock.NewRows(transferStateColumns).AddRow(true, float32(42), float32(100), 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("USER", loginNameTestingWebhook, "USER", "login2", float32(10), "TRANSFER", "transfer", "", "").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectCommit()
+
+	require.NoError(t, baseH.TransferHandler(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"recipient":"login2","amount":10`)
+}
+
+func TestTransferHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		state      *pgxmock.Rows
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name: "to self", body: `{"recipient":"` + loginNameTestingWebhook + `","amount":10}`,
+			wantStatus: http.StatusUnprocessableEntity, wantCode: problem.CodeInvalidTransfer,
+		},
+		{
+			name: "not positive", body: `{"recipient":"login2","amount":-10}`,
+			wantStatus: http.StatusUnprocessableEntity, wantCode: problem.CodeInvalidTransfer,
+		},
+		{
+			name: "no recipient", body: `{"recipient":"login2","amount":10}`,
+			state:      pgxmock.NewRows(transferStateColumns).AddRow(false, float32(42), float32(0), 0),
+			wantStatus: http.StatusNotFound, wantCode: problem.CodeNotFound,
+		},
+		{
+			name: "no money", body: `{"recipient":"login2","amount":50}`,
+			state:      pgxmock.NewRows(transferStateColumns).AddRow(true, float32(42), float32(0), 0),
+			wantStatus: http.StatusPaymentRequired, wantCode: problem.CodeInsufficientFunds,
+		},
+		{
+			name: "daily sum", body: `{"recipient":"login2","amount":10}`,
+			state:      pgxmock.NewRows(transferStateColumns).AddRow(true, float32(42), float32(995), 1),
+			wantStatus: http.StatusForbidden, wantCode: problem.CodeTransferLimit,
+		},
+		{
+			name: "daily count", body: `{"recipient":"login2","amount":10}`,
+			state:      pgxmock.NewRows(transferStateColumns).AddRow(true, float32(42), float32(10), 10),
+			wantStatus: http.StatusForbidden, wantCode: problem.CodeTransferLimit,
+		},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			baseH, mock, ctx, rec := newAuthContext(t, http.MethodPost, test.body, "")
+			if test.state != nil {
+				expectTransferState(mock, "login2", test.state)
+				mock.ExpectRollback()
+			}
+
+			err := baseH.TransferHandler(ctx)
+			require.Error(t, err)
+			handler.HTTPErrorHandler(err, ctx)
+			assert.NoError(t, mock.ExpectationsWereMet())
+			assert.Equal(t, test.wantStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), test.wantCode)
+		})
+	}
+}
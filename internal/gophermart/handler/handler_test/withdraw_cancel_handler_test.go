@@ -0,0 +1,138 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var withdrawColumns = []string{"id", "number", "sum", "processed_at", "status", "username"}
+
+func TestWithdrawCancelHandler(t *testing.T) {
+	baseH, mock, ctx, rec := newAuthContext(t, http.MethodPost, "", "")
+	ctx.SetParamNames("order")
+	ctx.SetParamValues("79927398713")
+	processedAt := time.Now().UTC().Truncate(time.Second)
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE withdraws SET status = 'CANCELLED'").
+		WithArgs(loginNameTestingWebhook, "79927398713", pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows(withdrawColumns).
+			AddRow(int64(5), "79927398713", float32(10), processedAt, "CANCELLED", loginNameTestingWebhook))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("WITHDRAWALS", loginNameTestingWebhook, "USER", loginNameTestingWebhook, float32(10),
+			"WITHDRAWAL_CANCEL", "withdraw", "5", "").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs("withdrawal.cancelled", loginNameTestingWebhook, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, baseH.WithdrawCancelHandler(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"order":"79927398713","sum":10,"processed_at":"`+processedAt.Format(time.RFC3339)+
+		`","status":"CANCELLED"}`, rec.Body.String())
+}
+
+func TestWithdrawCancelHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		last       *pgxmock.Rows
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name: "not found", last: pgxmock.NewRows(withdrawColumns),
+			wantStatus: http.StatusNotFound, wantCode: problem.CodeNotFound,
+		},
+		{
+			name: "completed",
+			last: pgxmock.NewRows(withdrawColumns).
+				AddRow(int64(5), "79927398713", float32(10), time.Now(), "COMPLETED", loginNameTestingWebhook),
+			wantStatus: http.StatusConflict, wantCode: problem.CodeNotCancellable,
+		},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			baseH, mock, ctx, rec := newAuthContext(t, http.MethodPost, "", "")
+			ctx.SetParamNames("order")
+			ctx.SetParamValues("79927398713")
+			mock.ExpectBegin()
+			mock.ExpectQuery("UPDATE withdraws SET status = 'CANCELLED'").
+				WithArgs(loginNameTestingWebhook, "79927398713", pgxmock.AnyArg()).
+				WillReturnRows(pgxmock.NewRows(withdrawColumns))
+			mock.ExpectCommit()
+			mock.ExpectQuery("SELECT id, number, sum, processed_at, status, username FROM withdraws").
+				WithArgs("79927398713", loginNameTestingWebhook).
+				WillReturnRows(test.last)
+
+			err := baseH.WithdrawCancelHandler(ctx)
+			require.Error(t, err)
+			handler.HTTPErrorHandler(err, ctx)
+			assert.NoError(t, mock.ExpectationsWereMet())
+			assert.Equal(t, test.wantStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), test.wantCode)
+		})
+	}
+}
+
+func TestWithdrawRefundHandler(t *testing.T) {
+	baseH, mock, ctx, rec := newAuthContext(t, http.MethodPost, `{"reason":"fraud"}`, "")
+	ctx.SetParamNames("order")
+	ctx.SetParamValues("79927398713")
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE withdraws SET status = 'REFUNDED'").WithArgs("79927398713").
+		WillReturnRows(pgxmock.NewRows(withdrawColumns).
+			AddRow(int64(5), "79927398713", float32(10), time.Now(), "REFUNDED", "user1"))
+	mock.ExpectExec("INSERT INTO withdraw_refunds").WithArgs(int64(5), "fraud").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("WITHDRAWALS", "user1", "USER", "user1", float32(10), "WITHDRAWAL_REFUND", "withdraw", "5", "fraud").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs("withdrawal.refunded", "user1", pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, baseH.WithdrawRefundHandler(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"status":"REFUNDED"`)
+}
+
+func TestWithdrawRefundHandlerErrors(t *testing.T) {
+	baseH, mock, ctx, rec := newAuthContext(t, http.MethodPost, `{}`, "")
+	ctx.SetParamNames("order")
+	ctx.SetParamValues("79927398713")
+
+	err := baseH.WithdrawRefundHandler(ctx)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), problem.CodeInvalidRefund)
+
+	baseH, mock, ctx, rec = newAuthContext(t, http.MethodPost, `{"reason":"fraud"}`, "")
+	ctx.SetParamNames("order")
+	ctx.SetParamValues("79927398713")
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE withdraws SET status = 'REFUNDED'").WithArgs("79927398713").
+		WillReturnRows(pgxmock.NewRows(withdrawColumns))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT id, number, sum, processed_at, status, username FROM withdraws").
+		WithArgs("79927398713", "").
+		WillReturnRows(pgxmock.NewRows(withdrawColumns).
+			AddRow(int64(5), "79927398713", float32(10), time.Now(), "CANCELLED", "user1"))
+
+	err = baseH.WithdrawRefundHandler(ctx)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), problem.CodeNotRefundable)
+}
@@ -11,6 +11,8 @@ import (
 
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/config"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
 	"github.com/labstack/echo/v4"
 	"github.com/pashagolub/pgxmock/v2"
@@ -31,17 +33,23 @@ func TestWithdrawHandler(t *testing.T) {
 		require.NoError(t, err)
 	}(mock, context.Background())
 
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(accrual\\),0\\)").
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT 1 FROM mart_users WHERE name = \\$1 FOR UPDATE").WithArgs(loginNameTestingWithdraw).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\)").
 		WithArgs(loginNameTestingWithdraw).
-		WillReturnRows(mock.NewRows([]string{"accrual"}).AddRow(float32(44)))
-
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(sum\\),0\\)").
-		WithArgs(loginNameTestingWithdraw).
-		WillReturnRows(pgxmock.NewRows([]string{"sum"}).AddRow(float32(2)))
-
-	mock.ExpectExec("insert into withdraws").
+		WillReturnRows(pgxmock.NewRows([]string{"current", "withdrawn"}).AddRow(float32(42), float32(2)))
+	mock.ExpectQuery("insert into withdraws").
 		WithArgs("2377225624", float32(2), loginNameTestingWithdraw, pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int64(1)))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("USER", loginNameTestingWithdraw, "WITHDRAWALS", loginNameTestingWithdraw, float32(2),
+			"WITHDRAWAL", "withdraw", "1", "").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs("withdrawal.created", loginNameTestingWithdraw, pgxmock.AnyArg()).
 		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
 
 	var pgConn sqldb.PgxIface = mock
 
@@ -92,7 +100,8 @@ func TestWithdrawHandlerBadRequest(t *testing.T) { // Mock echo
 	baseH := handler.NewBaseHandler(nil, *cfg)
 
 	err := baseH.WithdrawHandler(ctx)
-	assert.NoError(t, err)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
 
 	got := rec.Result()
 	defer got.Body.Close()
@@ -101,6 +110,36 @@ func TestWithdrawHandlerBadRequest(t *testing.T) { // Mock echo
 	assert.Equal(t, wantStatusCode, got.StatusCode, "StatusCode got: %v, want: %v", got.StatusCode, wantStatusCode)
 }
 
+func TestWithdrawHandlerNegativeSum(t *testing.T) {
+	echoFr := echo.New()
+	defer echoFr.Close()
+
+	bodyStr := "{\"order\": \"2377225624\",\n    \"sum\": -751\n}"
+	req := httptest.NewRequest(echo.GET, "http://localhost:1323/", strings.NewReader(bodyStr))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Add("Authorization",
+		fmt.Sprintf("Authorization:[%s]", loginNameTestingWithdraw))
+
+	rec := httptest.NewRecorder()
+	ctx := echoFr.NewContext(req, rec)
+
+	cfg := config.NewConfig()
+
+	// no DB connection: the sum is rejected before the balance is touched.
+	baseH := handler.NewBaseHandler(nil, *cfg)
+
+	err := baseH.WithdrawHandler(ctx)
+	require.ErrorIs(t, err, repository.ErrWithdrawInvalid)
+	handler.HTTPErrorHandler(err, ctx)
+
+	got := rec.Result()
+	defer got.Body.Close()
+
+	wantStatusCode := http.StatusUnprocessableEntity
+	assert.Equal(t, wantStatusCode, got.StatusCode, "StatusCode got: %v, want: %v", got.StatusCode, wantStatusCode)
+	assert.Contains(t, rec.Body.String(), problem.CodeInvalidWithdraw)
+}
+
 func TestWithdrawHandlerNoMoney(t *testing.T) {
 	// Mock db
 	// DB connection
@@ -112,13 +151,13 @@ func TestWithdrawHandlerNoMoney(t *testing.T) {
 		require.NoError(t, err)
 	}(mock, context.Background())
 
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(accrual\\),0\\)").
-		WithArgs(loginNameTestingWithdraw).
-		WillReturnRows(mock.NewRows([]string{"accrual"}).AddRow(float32(44)))
-
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(sum\\),0\\)").
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT 1 FROM mart_users WHERE name = \\$1 FOR UPDATE").WithArgs(loginNameTestingWithdraw).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\)").
 		WithArgs(loginNameTestingWithdraw).
-		WillReturnRows(pgxmock.NewRows([]string{"sum"}).AddRow(float32(2)))
+		WillReturnRows(pgxmock.NewRows([]string{"current", "withdrawn"}).AddRow(float32(42), float32(2)))
+	mock.ExpectRollback()
 
 	var pgConn sqldb.PgxIface = mock
 
@@ -140,7 +179,8 @@ func TestWithdrawHandlerNoMoney(t *testing.T) {
 	baseH := handler.NewBaseHandler(&pgConn, *cfg)
 
 	err = baseH.WithdrawHandler(ctx)
-	assert.NoError(t, err)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 
@@ -162,17 +202,16 @@ func TestWithdrawHandlerInternalErr(t *testing.T) {
 		require.NoError(t, err)
 	}(mock, context.Background())
 
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(accrual\\),0\\)").
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT 1 FROM mart_users WHERE name = \\$1 FOR UPDATE").WithArgs(loginNameTestingWithdraw).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\)").
 		WithArgs(loginNameTestingWithdraw).
-		WillReturnRows(mock.NewRows([]string{"accrual"}).AddRow(float32(44)))
-
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(sum\\),0\\)").
-		WithArgs(loginNameTestingWithdraw).
-		WillReturnRows(pgxmock.NewRows([]string{"sum"}).AddRow(float32(2)))
-
-	mock.ExpectExec("insert into withdraws").
+		WillReturnRows(pgxmock.NewRows([]string{"current", "withdrawn"}).AddRow(float32(42), float32(2)))
+	mock.ExpectQuery("insert into withdraws").
 		WithArgs("2377225624", float32(2), loginNameTestingWithdraw, pgxmock.AnyArg()).
 		WillReturnError(io.EOF)
+	mock.ExpectRollback()
 
 	var pgConn sqldb.PgxIface = mock
 
@@ -194,7 +233,8 @@ func TestWithdrawHandlerInternalErr(t *testing.T) {
 	baseH := handler.NewBaseHandler(&pgConn, *cfg)
 
 	err = baseH.WithdrawHandler(ctx)
-	assert.NoError(t, err)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 
@@ -11,6 +11,7 @@ import (
 
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/config"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
 	"github.com/labstack/echo/v4"
 	"github.com/pashagolub/pgxmock/v2"
@@ -29,10 +30,10 @@ func TestWithdrawsListHandler(t *testing.T) {
 		require.NoError(t, err)
 	}(mock, context.Background())
 	now := time.Now()
-	rows := pgxmock.NewRows([]string{"number", "sum", "processed_at"}).
-		AddRow("79927398713", float32(0), now)
+	rows := pgxmock.NewRows([]string{"number", "sum", "processed_at", "status"}).
+		AddRow("79927398713", float32(0), now, "COMPLETED")
 
-	mock.ExpectQuery("SELECT number, sum, processed_at FROM withdraws WHERE username=\\$1").
+	mock.ExpectQuery("SELECT number, sum, processed_at, status FROM withdraws WHERE username=\\$1").
 		WithArgs(loginNameTestingWithdraw).
 		WillReturnRows(rows)
 
@@ -65,7 +66,8 @@ func TestWithdrawsListHandler(t *testing.T) {
 	assert.Equal(t, wantStatusCode, got.StatusCode, "StatusCode got: %v, want: %v", got.StatusCode, wantStatusCode)
 	marshaledNow, err := now.MarshalText()
 	require.NoError(t, err)
-	want := "[{\"order\":\"79927398713\",\"sum\":0,\"processed_at\":\"" + string(marshaledNow) + "\"}]\n"
+	want := "[{\"order\":\"79927398713\",\"sum\":0,\"processed_at\":\"" + string(marshaledNow) +
+		"\",\"status\":\"COMPLETED\"}]\n"
 	gotBody, err := io.ReadAll(got.Body)
 	require.NoError(t, err)
 	assert.Equal(t, want, string(gotBody), "Body got: %v, want: %v", string(gotBody), want)
@@ -82,10 +84,10 @@ func TestWithdrawsListHandlerResponseErr(t *testing.T) {
 		require.NoError(t, err)
 	}(mock, context.Background())
 	now := time.Now()
-	rows := pgxmock.NewRows([]string{"number", "sum", "processed_at"}).
-		AddRow("79927398713", float32(0), now)
+	rows := pgxmock.NewRows([]string{"number", "sum", "processed_at", "status"}).
+		AddRow("79927398713", float32(0), now, "COMPLETED")
 
-	mock.ExpectQuery("SELECT number, sum, processed_at FROM withdraws WHERE username=\\$1").
+	mock.ExpectQuery("SELECT number, sum, processed_at, status FROM withdraws WHERE username=\\$1").
 		WithArgs(loginNameTestingWithdraw).
 		WillReturnRows(rows)
 
@@ -133,9 +135,9 @@ func TestWithdrawsListHandlerEmptyResult(t *testing.T) {
 		require.NoError(t, err)
 	}(mock, context.Background())
 
-	rows := pgxmock.NewRows([]string{"number", "sum", "processed_at"})
+	rows := pgxmock.NewRows([]string{"number", "sum", "processed_at", "status"})
 
-	mock.ExpectQuery("SELECT number, sum, processed_at FROM withdraws WHERE username=\\$1").
+	mock.ExpectQuery("SELECT number, sum, processed_at, status FROM withdraws WHERE username=\\$1").
 		WithArgs(loginNameTestingWithdraw).
 		WillReturnRows(rows)
 
@@ -183,7 +185,7 @@ func TestWithdrawsListHandlerInternalErr(t *testing.T) {
 		require.NoError(t, err)
 	}(mock, context.Background())
 
-	mock.ExpectQuery("SELECT number, sum, processed_at FROM withdraws WHERE username=\\$1").
+	mock.ExpectQuery("SELECT number, sum, processed_at, status FROM withdraws WHERE username=\\$1").
 		WithArgs(loginNameTestingWithdraw).
 		WillReturnError(http.ErrAbortHandler)
 
@@ -205,7 +207,8 @@ func TestWithdrawsListHandlerInternalErr(t *testing.T) {
 	baseH := handler.NewBaseHandler(&pgConn, *cfg)
 
 	err = baseH.WithdrawsListHandler(ctx)
-	assert.NoError(t, err)
+	require.Error(t, err)
+	handler.HTTPErrorHandler(err, ctx)
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 
@@ -217,5 +220,5 @@ func TestWithdrawsListHandlerInternalErr(t *testing.T) {
 
 	gotBody, err := io.ReadAll(got.Body)
 	require.NoError(t, err)
-	assert.Empty(t, gotBody)
+	assert.Contains(t, string(gotBody), problem.CodeInternal)
 }
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository/cooldown"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	healthStatusOk       = "ok"
+	healthStatusFail     = "fail"
+	healthStatusDegraded = "degraded"
+
+	circuitClosed = "closed"
+	circuitOpen   = "open"
+)
+
+// DependencyHealth is a state of a dependency of the service.
+type DependencyHealth struct {
+	Status   string `json:"status"`
+	Details  string `json:"details,omitempty"`
+	Critical bool   `json:"critical"`
+}
+
+// Readiness is a response of GET `/readyz`.
+type Readiness struct {
+	Status string                      `json:"status"`
+	Checks map[string]DependencyHealth `json:"checks"`
+}
+
+// HealthzHandler handles GET `/healthz`, it responds while the process is alive.
+func (h *BaseHandler) HealthzHandler(ctx echo.Context) error {
+	if err := ctx.JSON(http.StatusOK, map[string]string{"status": healthStatusOk}); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// ReadyzHandler handles GET `/readyz`, it responds with 503
+// if the service can't serve requests because of its dependencies.
+func (h *BaseHandler) ReadyzHandler(ctx echo.Context) error {
+	result := Readiness{
+		Status: healthStatusOk,
+		Checks: map[string]DependencyHealth{
+			"db":        h.checkDB(ctx.Request().Context()),
+			"migration": h.checkMigration(ctx.Request().Context()),
+			"accrual":   checkAccrual(),
+		},
+	}
+	status := http.StatusOK
+	for _, check := range result.Checks {
+		if check.Status == healthStatusOk {
+			continue
+		}
+		if check.Critical {
+			result.Status = healthStatusFail
+			status = http.StatusServiceUnavailable
+
+			break
+		}
+		result.Status = healthStatusDegraded
+	}
+
+	if err := ctx.JSON(status, result); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+func (h *BaseHandler) checkDB(ctx context.Context) DependencyHealth {
+	if h.conn == nil {
+		return DependencyHealth{Status: healthStatusFail, Details: "no connection", Critical: true}
+	}
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.HealthTimeout)
+	defer cancel()
+	if err := sqldb.Ping(ctx, h.conn); err != nil {
+		return DependencyHealth{Status: healthStatusFail, Details: err.Error(), Critical: true}
+	}
+
+	return DependencyHealth{Status: healthStatusOk, Details: "", Critical: true}
+}
+
+func (h *BaseHandler) checkMigration(ctx context.Context) DependencyHealth {
+	if h.conn == nil {
+		return DependencyHealth{Status: healthStatusFail, Details: "no connection", Critical: true}
+	}
+	version, dirty, err := sqldb.GetSchemaVersion(ctx, h.conn)
+	switch {
+	case err != nil:
+		return DependencyHealth{Status: healthStatusFail, Details: err.Error(), Critical: true}
+	case dirty:
+		return DependencyHealth{
+			Status: healthStatusFail, Details: fmt.Sprintf("version %d is dirty", version), Critical: true,
+		}
+	case version < sqldb.SchemaVersion:
+		return DependencyHealth{
+			Status:   healthStatusFail,
+			Details:  fmt.Sprintf("version %d, want %d", version, sqldb.SchemaVersion),
+			Critical: true,
+		}
+	}
+
+	return DependencyHealth{Status: healthStatusOk, Details: fmt.Sprintf("version %d", version), Critical: true}
+}
+
+// checkAccrual reports the circuit state of 'Accrual' client,
+// the circuit is open while 'Accrual' asks to cool down.
+func checkAccrual() DependencyHealth {
+	if until, ok := cooldown.CooldownUntil(); ok {
+		return DependencyHealth{
+			Status:   healthStatusDegraded,
+			Details:  fmt.Sprintf("circuit %s until %s", circuitOpen, until.Format(time.RFC3339)),
+			Critical: false,
+		}
+	}
+
+	return DependencyHealth{Status: healthStatusOk, Details: "circuit " + circuitClosed, Critical: false}
+}
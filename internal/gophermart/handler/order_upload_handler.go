@@ -1,27 +1,47 @@
 package handler
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/metrics"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository/cooldown"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/tracing"
 	"github.com/go-resty/resty/v2"
 	"github.com/labstack/echo/v4"
-	"go.uber.org/zap"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var (
+	errAccrualNotRegistered = errors.New("order is not registered in the accrual system")
+	errAccrualStatus        = errors.New("unexpected status of the accrual system")
+	errAccrualOrder         = errors.New("unexpected order in the response of the accrual system")
+)
+
+// retryAfterError is returned while the accrual system asks to cool down,
+// the poll is not counted as an attempt of the order.
+type retryAfterError struct {
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return fmt.Sprintf("accrual system asks to retry after %s", e.delay)
+}
+
 const (
 	alreadyUploadedByOwner = http.StatusOK       // 200 — номер заказа уже был загружен этим пользователем.
 	orderAccepted          = http.StatusAccepted // 202 — новый номер заказа принят в обработку
-	// badRequest               = http.StatusBadRequest          // 400 — неверный формат запроса.
-	alreadyUploadedByAnother = http.StatusConflict            // 409 — номер заказа уже был загружен другим пользователем
-	internalError            = http.StatusInternalServerError // 500 — внутренняя ошибка сервера
 )
 
 // OrderUploadHandler handles POST `/api/user/orders`.
@@ -31,35 +51,46 @@ func (h *BaseHandler) OrderUploadHandler(ctx echo.Context) error {
 		reqBody, _ = io.ReadAll(ctx.Request().Body)
 	}
 	orderNumber := string(reqBody)
-	zap.S().Infoln("OrderUploadHandler:", "body:", orderNumber)
+	logging.FromEcho(ctx).Infoln("OrderUploadHandler:", "body:", orderNumber)
 	username := GetAuthFromCtx(ctx)
-	err := repository.AddNewOrder(h.conn, orderNumber, username)
+	err := repository.AddNewOrder(ctx.Request().Context(), h.conn, orderNumber, username)
 
-	var respStatus int
+	accCtx := logging.WithLogger(tracing.Detach(ctx.Request().Context()), logging.FromEcho(ctx))
+	h.goBackground(func() { h.sendAccRequestInBackground(accCtx, orderNumber) })
 
 	switch {
 	case err == nil:
-		respStatus = orderAccepted
+		_ = ctx.NoContent(orderAccepted)
 	case errors.Is(err, repository.ErrOrderAlreadyExistsByOwner):
-		respStatus = alreadyUploadedByOwner
+		_ = ctx.NoContent(alreadyUploadedByOwner)
 	case errors.Is(err, repository.ErrOrderAlreadyExistsByAnother):
-		respStatus = alreadyUploadedByAnother
+		return problem.New(http.StatusConflict, problem.CodeOrderUploadedByAnother,
+			"the order has already been uploaded by another user", err)
 	default:
-		respStatus = internalError
+		return problem.Internal(err)
 	}
 
-	go SendAccRequest(h.conn, orderNumber, h.cfg.Accrual, username)
-	_ = ctx.NoContent(respStatus)
-
 	return nil
 }
 
-func sleepIfCooldown() {
-	for { // we are waiting for finished cooldown
-		if cooldown.IsAccrualReady() {
-			break
-		}
-		time.Sleep(time.Second)
+// sendAccRequestInBackground waits for a free accrual worker and polls the order
+// unless it is final or leased by the poller of another instance.
+func (h *BaseHandler) sendAccRequestInBackground(ctx context.Context, number string) {
+	if err := h.accrualWorkers.Acquire(ctx, 1); err != nil {
+		logging.FromContext(ctx).Warnf("failed to wait for an accrual worker: %s", err.Error())
+
+		return
+	}
+	defer h.accrualWorkers.Release(1)
+
+	order, err := sqldb.ClaimOrder(ctx, h.conn, number, h.cfg.AccrualLease)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("failed to claim order %s: %s", number, err.Error())
+
+		return
+	}
+	if order != nil {
+		h.pollOrder(ctx, *order)
 	}
 }
 
@@ -74,49 +105,72 @@ func getRetryHeader(headers http.Header) int64 {
 	return result
 }
 
-func SendAccRequest(pgConn *sqldb.PgxIface, number string, baseURL string, username string) *accrual.OrderExt {
+func accrualOutcome(resp *resty.Response, err error) string {
+	switch {
+	case err != nil:
+		return metrics.AccrualOutcomeError
+	case resp.StatusCode() == http.StatusOK:
+		return metrics.AccrualOutcomeOk
+	case resp.StatusCode() == http.StatusNoContent:
+		return metrics.AccrualOutcomeNoContent
+	case resp.StatusCode() == http.StatusTooManyRequests:
+		return metrics.AccrualOutcomeTooManyRequests
+	default:
+		return metrics.AccrualOutcomeUnexpectedStatus
+	}
+}
+
+// SendAccRequest requests 'Accrual' for the order once and updates the order by the response,
+// it fails with *retryAfterError while the accrual system asks to cool down.
+// The bonuses are credited on top of the accrual of a processed order, nil credits only the campaign bonuses.
+func SendAccRequest(
+	ctx context.Context, pgConn *sqldb.PgxIface, httpc *resty.Client, number string, username string,
+	bonuses *sqldb.OrderBonuses,
+) (*accrual.OrderExt, error) {
+	metrics.AccrualWorkerStarted()
+	defer metrics.AccrualWorkerFinished()
+
+	ctx, span := tracing.Tracer().Start(ctx, "SendAccRequest",
+		trace.WithAttributes(attribute.String("order.number", number)))
+	defer span.End()
+
+	if until, ok := cooldown.CooldownUntil(); ok {
+		return nil, &retryAfterError{delay: time.Until(until)}
+	}
+
 	var acc accrual.OrderAccrual
-	logger := zap.S()
-	httpc := resty.New().SetBaseURL(baseURL)
-
-	sleepIfCooldown()
-
-	for {
-		req := httpc.R().
-			SetResult(&acc).
-			SetPathParam("number", number)
-
-		resp, err := req.Get("/api/orders/{number}")
-		if resp != nil && resp.Request != nil {
-			logger.Info("OrderUploadHandler:", "req.URL:", resp.Request.URL)
-			logger.Info("OrderUploadHandler:", "req.Get StatusCode:", resp.StatusCode())
-			logger.Info("OrderUploadHandler:", "req.Get resp:", resp.String())
-		}
-
-		if err != nil {
-			logger.Info("OrderUploadHandler:", "req.Get err:", err)
-		}
-
-		if resp.StatusCode() == http.StatusTooManyRequests {
-			cooldown.NeedAccrualCooldown(getRetryHeader(resp.Header()))
-			time.Sleep(1 * time.Minute)
-
-			continue
-		}
-
-		logger.Infoln("OrderUploadHandler:", "acc:", acc)
-		if acc.Order != "" {
-			order := acc.GetOrderExt(username, time.Now())
-			err = sqldb.UpdateOrder(pgConn, order)
-			if err != nil {
-				logger.Warn("err:", err.Error())
-			} else {
-				return order
-			}
-		}
-
-		break
+	logger := logging.FromContext(ctx).With("order", number)
+	resp, err := httpc.R().
+		SetContext(ctx).
+		SetResult(&acc).
+		SetPathParam("number", number).
+		Get("/api/orders/{number}")
+	metrics.ObserveAccrualRequest(accrualOutcome(resp, err))
+	if err != nil {
+		return nil, fmt.Errorf("failed to request accrual: %w", err)
+	}
+	logger.Infoln("SendAccRequest:", "status:", resp.StatusCode(), "resp:", resp.String())
+
+	switch resp.StatusCode() {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return nil, errAccrualNotRegistered
+	case http.StatusTooManyRequests:
+		retryAfter := getRetryHeader(resp.Header())
+		metrics.AddAccrualCooldown(retryAfter)
+		cooldown.NeedAccrualCooldown(retryAfter)
+
+		return nil, &retryAfterError{delay: time.Duration(retryAfter) * time.Second}
+	default:
+		return nil, fmt.Errorf("%w: %d", errAccrualStatus, resp.StatusCode())
+	}
+	if acc.Order != number {
+		return nil, fmt.Errorf("%w: %q", errAccrualOrder, acc.Order)
+	}
+	order := acc.GetOrderExt(username, time.Now())
+	if err = sqldb.UpdateOrder(ctx, pgConn, order, bonuses); err != nil {
+		return nil, fmt.Errorf("%w", err)
 	}
 
-	return nil
+	return order, nil
 }
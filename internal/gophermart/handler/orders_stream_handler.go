@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/events"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/labstack/echo/v4"
+)
+
+// MIMETextEventStream is the media type of Server-Sent Events.
+const MIMETextEventStream = "text/event-stream"
+
+// EventOrder is the name of SSE events with changes of orders.
+const EventOrder = "order"
+
+// OrdersStreamHandler handles GET `/api/user/orders/stream`.
+// It streams changes of the user's orders as Server-Sent Events until the client goes away
+// or the server shuts down, idle streams get heartbeat comments.
+func (h *BaseHandler) OrdersStreamHandler(ctx echo.Context) error {
+	username := GetAuthFromCtx(ctx)
+	logger := logging.FromEcho(ctx)
+	sub := h.bus.Subscribe(username)
+	defer h.bus.Unsubscribe(sub)
+	logger.Info("OrdersStreamHandler: subscribed")
+
+	response := ctx.Response()
+	response.Header().Set(echo.HeaderContentType, MIMETextEventStream)
+	response.Header().Set(echo.HeaderCacheControl, "no-cache")
+	response.Header().Set(echo.HeaderConnection, "keep-alive")
+	response.Header().Set("X-Accel-Buffering", "no")
+	response.WriteHeader(http.StatusOK)
+	response.Flush()
+
+	heartbeat := time.NewTicker(h.cfg.SSEHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Request().Context().Done():
+			logger.Info("OrdersStreamHandler: client is gone")
+
+			return nil
+		case event, ok := <-sub.C:
+			if !ok {
+				logger.Info("OrdersStreamHandler: stream is closed")
+
+				return nil
+			}
+			if err := writeOrderEvent(response, event); err != nil {
+				logger.Warnf("OrdersStreamHandler: %s", err.Error())
+
+				return nil
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(response, ": heartbeat\n\n"); err != nil {
+				return nil //nolint:nilerr
+			}
+		}
+		response.Flush()
+	}
+}
+
+func writeOrderEvent(response *echo.Response, event events.OrderEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	if _, err = fmt.Fprintf(response, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, EventOrder, data); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+	"go.uber.org/zap"
+)
+
+const (
+	// pointsExpireInterval is how often the lapsed points are expired.
+	pointsExpireInterval = time.Hour
+	// pointsExpireBatch limits the users whose points are expired at once.
+	pointsExpireBatch = 100
+)
+
+// RunPointsExpirer expires the lapsed points until ctx is done, it does nothing if the points do not expire.
+func (h *BaseHandler) RunPointsExpirer(ctx context.Context) {
+	if h.cfg.PointsExpireAfter <= 0 {
+		return
+	}
+	ticker := time.NewTicker(pointsExpireInterval)
+	defer ticker.Stop()
+	for {
+		if err := h.ExpirePoints(ctx); err != nil {
+			zap.S().Warnf("points expirer: %s", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExpirePoints expires the points credited more than PointsExpireAfter ago and not spent yet.
+func (h *BaseHandler) ExpirePoints(ctx context.Context) error {
+	for {
+		now := time.Now()
+		usernames, err := sqldb.FindUsersWithExpiredPoints(ctx, h.conn, h.cfg.PointsExpireAfter, now,
+			pointsExpireBatch)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+		for _, username := range usernames {
+			expired, err := sqldb.ExpirePoints(ctx, h.conn, username, h.cfg.PointsExpireAfter, now)
+			if err != nil {
+				return err //nolint:wrapcheck
+			}
+			if expired > 0 {
+				zap.S().Infof("points expirer: %v points of %s are expired", expired, username)
+			}
+		}
+		// the checked users are not found again until they have newer lapsed credits.
+		if len(usernames) < pointsExpireBatch {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// ProfileHandler handles GET `/api/user/profile`,
+// it returns the loyalty tier of the user by their lifetime accrual and the progress to the next tier.
+func (h *BaseHandler) ProfileHandler(ctx echo.Context) error {
+	username := GetAuthFromCtx(ctx)
+	logging.FromEcho(ctx).Infoln("ProfileHandler:", "username:", username)
+
+	lifetime, err := repository.GetLifetimeAccrual(ctx.Request().Context(), h.conn, username)
+	if err != nil {
+		return problem.Internal(err)
+	}
+	tier, next := h.cfg.TierFor(lifetime)
+	profile := accrual.ProfileExt{
+		Login:             username,
+		LifetimeAccrual:   lifetime,
+		Tier:              tier.Name,
+		Multiplier:        tier.Multiplier,
+		NextTier:          "",
+		NextTierThreshold: 0,
+		Progress:          1,
+	}
+	if next != nil {
+		profile.NextTier = next.Name
+		profile.NextTierThreshold = next.Threshold
+		profile.Progress = (lifetime - tier.Threshold) / (next.Threshold - tier.Threshold)
+	}
+
+	return writeJSON(ctx, profile)
+}
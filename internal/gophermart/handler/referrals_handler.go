@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// ReferralsHandler handles GET `/api/user/referrals`,
+// it returns the referral code of the user and the users registered by it.
+func (h *BaseHandler) ReferralsHandler(ctx echo.Context) error {
+	username := GetAuthFromCtx(ctx)
+	logging.FromEcho(ctx).Infoln("ReferralsHandler:", "username:", username)
+
+	referrals, err := repository.GetReferrals(ctx.Request().Context(), h.conn, username,
+		h.cfg.ReferralBonus, h.cfg.ReferralMaxRewards)
+	if err != nil {
+		return problem.Internal(err)
+	}
+
+	return writeJSON(ctx, referrals)
+}
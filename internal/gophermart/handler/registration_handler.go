@@ -5,40 +5,82 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/credential"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository"
 	"github.com/labstack/echo/v4"
 )
 
-// RegistrationHandler handles `/api/user/register`.
+// RegistrationHandler handles `/api/user/register`,
+// the optional referral code of another user makes the user a referral of that user.
 func (h *BaseHandler) RegistrationHandler(ctx echo.Context) error {
 	incomeCred := &credential.IncomeCredentials{} //nolint:exhaustruct
 	if err := ctx.Bind(incomeCred); err != nil {
-		return WrapHandlerErr(ctx, http.StatusBadRequest, "RegistrationHandler: failed to parse json: %s", err)
+		return problem.New(http.StatusBadRequest, problem.CodeBadRequest, "failed to parse json", err)
 	}
 
-	cred, err := repository.GetCredentials(h.conn, incomeCred.Login)
+	cred, err := repository.GetCredentials(ctx.Request().Context(), h.conn, incomeCred.Login)
 	if cred != nil {
-		return fmt.Errorf("%w", ctx.String(http.StatusConflict,
-			fmt.Sprintf("RegistrationHandler:  [%s] login is already taken", incomeCred.Login)))
+		return problem.New(http.StatusConflict, problem.CodeLoginTaken,
+			fmt.Sprintf("login [%s] is already taken", incomeCred.Login), nil)
 	}
 	if err != nil && !errors.Is(err, repository.ErrUserNameNotFound) {
-		return WrapHandlerErr(ctx, http.StatusInternalServerError, "RegistrationHandler: failed to find login by: %s", err)
+		return problem.Internal(err)
+	}
+
+	referrer, err := h.findReferrer(ctx, incomeCred)
+	if err != nil {
+		return err
 	}
 
 	cred = credential.NewCredentials(incomeCred.Login, "")
 	if err = cred.HashPass(incomeCred.Password); err != nil {
-		return WrapHandlerErr(ctx, http.StatusInternalServerError,
-			"RegistrationHandler: failed to hash the pass by: %s", err)
+		return problem.Internal(err)
 	}
 
-	if err = repository.AddCredentials(h.conn, *cred); err != nil {
-		return WrapHandlerErr(ctx, http.StatusInternalServerError,
-			"RegistrationHandler: failed to save the credentials by: %s", err)
+	if err = repository.AddCredentials(ctx.Request().Context(), h.conn, *cred); err != nil {
+		return problem.Internal(err)
 	}
+	h.grantRegistrationBonuses(ctx, incomeCred.Login, referrer)
 
-	AddAuthHeaders(ctx, incomeCred.Login)
+	AddAuthHeaders(ctx, incomeCred.Login, NewCookieOptions(h.cfg))
 	_ = ctx.NoContent(http.StatusOK)
 
 	return nil
 }
+
+// findReferrer returns the user of the referral code of the registration, it returns an empty string without a code.
+func (h *BaseHandler) findReferrer(ctx echo.Context, incomeCred *credential.IncomeCredentials) (string, error) {
+	if incomeCred.ReferralCode == "" {
+		return "", nil
+	}
+	referrer, err := repository.FindReferrer(ctx.Request().Context(), h.conn, incomeCred.ReferralCode, incomeCred.Login)
+	switch {
+	case errors.Is(err, repository.ErrReferralCodeNotFound), errors.Is(err, repository.ErrReferralSelf):
+		return "", problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidReferral,
+			"the referral code is not valid", err)
+	case err != nil:
+		return "", problem.Internal(err)
+	}
+
+	return referrer, nil
+}
+
+// grantRegistrationBonuses credits the welcome bonuses and records the referral of the new user.
+// The user is registered anyway, a failed bonus is to be credited by an operator.
+func (h *BaseHandler) grantRegistrationBonuses(ctx echo.Context, login string, referrer string) {
+	logger := logging.FromEcho(ctx)
+	granted, err := repository.GrantWelcomeBonuses(ctx.Request().Context(), h.conn, login)
+	if err != nil {
+		logger.Warnln("RegistrationHandler:", "failed to grant welcome bonuses:", err)
+	} else if granted > 0 {
+		logger.Infoln("RegistrationHandler:", "login:", login, "welcome bonus:", granted)
+	}
+	if referrer == "" {
+		return
+	}
+	if err = repository.AddReferral(ctx.Request().Context(), h.conn, referrer, login); err != nil {
+		logger.Warnln("RegistrationHandler:", "failed to add referral:", err)
+	}
+}
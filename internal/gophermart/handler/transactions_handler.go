@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// Sizes of a page of the transactions.
+const (
+	defaultTransactionsLimit = 50
+	maxTransactionsLimit     = 100
+)
+
+// TransactionsHandler handles GET `/api/user/transactions`,
+// the transactions go in chronological order from the oldest one and the next page is requested with `after`.
+func (h *BaseHandler) TransactionsHandler(ctx echo.Context) error {
+	limit, err := queryInt(ctx, "limit", defaultTransactionsLimit)
+	if err != nil || limit < 1 || limit > maxTransactionsLimit {
+		return problem.New(http.StatusBadRequest, problem.CodeBadRequest, "invalid limit", err)
+	}
+	after, err := queryInt(ctx, "after", 0)
+	if err != nil || after < 0 {
+		return problem.New(http.StatusBadRequest, problem.CodeBadRequest, "invalid after", err)
+	}
+	username := GetAuthFromCtx(ctx)
+	page, err := repository.GetTransactions(ctx.Request().Context(), h.conn, username, int64(after), limit)
+	if err != nil {
+		if !errors.Is(err, repository.ErrTransactionsNoItems) {
+			return problem.Internal(err)
+		}
+		logging.FromEcho(ctx).Info("TransactionsHandler: no items")
+		_ = ctx.NoContent(http.StatusNoContent)
+
+		return nil
+	}
+
+	return writeJSON(ctx, page)
+}
+
+// queryInt returns the query parameter or the fallback if it is absent.
+func queryInt(ctx echo.Context, name string, fallback int) (int, error) {
+	value := ctx.QueryParam(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	return strconv.Atoi(value) //nolint:wrapcheck
+}
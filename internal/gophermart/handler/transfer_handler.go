@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// TransferHandler handles POST `/api/user/balance/transfer`,
+// the points are moved to the recipient at once or not moved at all.
+func (h *BaseHandler) TransferHandler(ctx echo.Context) error {
+	transferInternal := accrual.TransferRequest{} //nolint:exhaustruct
+	if err := ctx.Bind(&transferInternal); err != nil {
+		return problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidTransfer,
+			"failed to parse transfer", err)
+	}
+	username := GetAuthFromCtx(ctx)
+	transfer := transferInternal.GetTransferExt(username, time.Now())
+	limits := repository.TransferLimits{MaxSum: h.cfg.TransferDailyLimit, MaxCount: h.cfg.TransferDailyCount}
+
+	err := repository.ProcessTransfer(ctx.Request().Context(), h.conn, *transfer, limits)
+	switch {
+	case errors.Is(err, repository.ErrTransferInvalid):
+		return problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidTransfer,
+			"the recipient must be another user and the amount must be positive", err)
+	case errors.Is(err, repository.ErrTransferNoRecipient):
+		return problem.New(http.StatusNotFound, problem.CodeNotFound, "recipient not found", err)
+	case errors.Is(err, repository.ErrTransferNoMoney):
+		return problem.New(http.StatusPaymentRequired, problem.CodeInsufficientFunds,
+			"there are not enough points", err)
+	case errors.Is(err, repository.ErrTransferLimit):
+		return problem.New(http.StatusForbidden, problem.CodeTransferLimit,
+			"the daily limit of transfers is exceeded", err)
+	case err != nil:
+		return problem.Internal(err)
+	}
+	logging.FromEcho(ctx).Infoln("TransferHandler:", "from:", username, "to:", transfer.Recipient,
+		"amount:", transfer.Amount)
+
+	return writeJSON(ctx, transfer)
+}
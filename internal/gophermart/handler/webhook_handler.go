@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/webhook"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository"
+	"github.com/labstack/echo/v4"
+)
+
+const maxWebhookURLLength = 2048
+
+var errInvalidWebhookURL = errors.New("webhook url must be absolute http(s) URL without credentials")
+
+// WebhookRegisterHandler handles POST `/api/user/webhooks`,
+// the secret of the webhook is returned only once, in the response.
+func (h *BaseHandler) WebhookRegisterHandler(ctx echo.Context) error {
+	income := webhook.IncomeWebhook{URL: ""}
+	if err := ctx.Bind(&income); err != nil {
+		return problem.New(http.StatusBadRequest, problem.CodeBadRequest, "failed to parse webhook", err)
+	}
+	if err := validateWebhookURL(income.URL); err != nil {
+		return problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidWebhook, err.Error(), err)
+	}
+
+	hook, err := repository.AddWebhook(ctx.Request().Context(), h.conn, GetAuthFromCtx(ctx), income.URL)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrWebhookAlreadyExists):
+			return problem.New(http.StatusConflict, problem.CodeWebhookExists,
+				"the url is already registered", err)
+		case errors.Is(err, repository.ErrWebhookLimit):
+			return problem.New(http.StatusUnprocessableEntity, problem.CodeTooManyWebhooks,
+				fmt.Sprintf("no more than %d webhooks are allowed", repository.MaxWebhooksPerUser), err)
+		default:
+			return problem.Internal(err)
+		}
+	}
+	logging.FromEcho(ctx).Infoln("WebhookRegisterHandler:", "id:", hook.ID)
+
+	return ctx.JSON(http.StatusCreated, hook) //nolint:wrapcheck
+}
+
+// WebhooksListHandler handles GET `/api/user/webhooks`.
+func (h *BaseHandler) WebhooksListHandler(ctx echo.Context) error {
+	hooks, err := repository.GetWebhooks(ctx.Request().Context(), h.conn, GetAuthFromCtx(ctx))
+	if err != nil {
+		if !errors.Is(err, repository.ErrWebhooksNoItems) {
+			return problem.Internal(err)
+		}
+		_ = ctx.NoContent(http.StatusNoContent)
+
+		return nil
+	}
+
+	return writeJSON(ctx, hooks)
+}
+
+// WebhookDeleteHandler handles DELETE `/api/user/webhooks/:id`.
+func (h *BaseHandler) WebhookDeleteHandler(ctx echo.Context) error {
+	id, err := webhookID(ctx)
+	if err != nil {
+		return err
+	}
+	if err = repository.DeleteWebhook(ctx.Request().Context(), h.conn, GetAuthFromCtx(ctx), id); err != nil {
+		if errors.Is(err, repository.ErrWebhookNotFound) {
+			return problem.New(http.StatusNotFound, problem.CodeNotFound, "webhook not found", err)
+		}
+
+		return problem.Internal(err)
+	}
+	_ = ctx.NoContent(http.StatusNoContent)
+
+	return nil
+}
+
+// WebhookDeliveriesHandler handles GET `/api/user/webhooks/:id/deliveries`,
+// it returns the latest deliveries first.
+func (h *BaseHandler) WebhookDeliveriesHandler(ctx echo.Context) error {
+	id, err := webhookID(ctx)
+	if err != nil {
+		return err
+	}
+	deliveries, err := repository.GetWebhookDeliveries(ctx.Request().Context(), h.conn, GetAuthFromCtx(ctx), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrWebhookNotFound):
+			return problem.New(http.StatusNotFound, problem.CodeNotFound, "webhook not found", err)
+		case errors.Is(err, repository.ErrDeliveriesNoItems):
+			_ = ctx.NoContent(http.StatusNoContent)
+
+			return nil
+		default:
+			return problem.Internal(err)
+		}
+	}
+
+	return writeJSON(ctx, deliveries)
+}
+
+func webhookID(ctx echo.Context) (int64, error) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, problem.New(http.StatusNotFound, problem.CodeNotFound, "webhook not found", err)
+	}
+
+	return id, nil
+}
+
+func validateWebhookURL(rawURL string) error {
+	if rawURL == "" || len(rawURL) > maxWebhookURLLength {
+		return errInvalidWebhookURL
+	}
+	hookURL, err := url.Parse(rawURL)
+	if err != nil || (hookURL.Scheme != "http" && hookURL.Scheme != "https") ||
+		hookURL.Hostname() == "" || hookURL.User != nil || hookURL.Fragment != "" {
+		return errInvalidWebhookURL
+	}
+
+	return nil
+}
+
+func writeJSON(ctx echo.Context, value interface{}) error {
+	ctx.Response().Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	ctx.Response().WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(ctx.Response()).Encode(value); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
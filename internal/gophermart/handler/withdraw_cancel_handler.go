@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// withdrawCompleteInterval is how often the pending withdrawals are completed after the grace period.
+const withdrawCompleteInterval = time.Minute
+
+// RefundRequest is a body of a refund of a withdrawal.
+type RefundRequest struct {
+	Reason string `json:"reason"`
+}
+
+// WithdrawCancelHandler handles POST `/api/user/withdrawals/:order/cancel`,
+// a pending withdrawal may be cancelled within WithdrawGracePeriod after it is made.
+func (h *BaseHandler) WithdrawCancelHandler(ctx echo.Context) error {
+	number := ctx.Param("order")
+	withdraw, err := repository.CancelWithdraw(ctx.Request().Context(), h.conn, GetAuthFromCtx(ctx), number,
+		h.cfg.WithdrawGracePeriod)
+	switch {
+	case errors.Is(err, repository.ErrWithdrawNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeNotFound, "withdrawal not found", err)
+	case errors.Is(err, repository.ErrWithdrawNotCancellable):
+		return problem.New(http.StatusConflict, problem.CodeNotCancellable,
+			"the withdrawal may not be cancelled anymore", err)
+	case err != nil:
+		return problem.Internal(err)
+	}
+	logging.FromEcho(ctx).Infoln("WithdrawCancelHandler:", "cancelled:", number)
+
+	return writeJSON(ctx, withdraw)
+}
+
+// WithdrawRefundHandler handles POST `/admin/withdrawals/:order/refund`,
+// the sum of the withdrawal is returned to the balance of its user.
+func (h *BaseHandler) WithdrawRefundHandler(ctx echo.Context) error {
+	request := RefundRequest{} //nolint:exhaustruct
+	if err := ctx.Bind(&request); err != nil {
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRefund, "failed to parse refund", err)
+	}
+	number := ctx.Param("order")
+	withdraw, err := repository.RefundWithdraw(ctx.Request().Context(), h.conn, number, request.Reason)
+	switch {
+	case errors.Is(err, repository.ErrWithdrawRefundNoReason):
+		return problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidRefund, "the reason is required", err)
+	case errors.Is(err, repository.ErrWithdrawNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeNotFound, "withdrawal not found", err)
+	case errors.Is(err, repository.ErrWithdrawNotRefundable):
+		return problem.New(http.StatusConflict, problem.CodeNotRefundable,
+			"the withdrawal is cancelled or refunded already", err)
+	case err != nil:
+		return problem.Internal(err)
+	}
+	logging.FromEcho(ctx).Infoln("WithdrawRefundHandler:", "refunded:", number, "user:", withdraw.Username,
+		"reason:", request.Reason)
+
+	return writeJSON(ctx, withdraw)
+}
+
+// RunWithdrawCompleter completes the pending withdrawals after the grace period until ctx is done.
+func (h *BaseHandler) RunWithdrawCompleter(ctx context.Context) {
+	ticker := time.NewTicker(withdrawCompleteInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := sqldb.CompleteWithdraws(ctx, h.conn, time.Now().Add(-h.cfg.WithdrawGracePeriod)); err != nil {
+				zap.S().Warnf("withdraw completer: %s", err.Error())
+			}
+		}
+	}
+}
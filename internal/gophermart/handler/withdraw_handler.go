@@ -2,48 +2,41 @@ package handler
 
 import (
 	"errors"
-	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository"
 	"github.com/labstack/echo/v4"
-	"go.uber.org/zap"
-)
-
-const (
-	withdrawAccepted      = http.StatusOK                  // 200 — успешная обработка запроса.
-	withdrawNotEnough     = http.StatusPaymentRequired     // 402 — на счету недостаточно средств
-	withdrawInternalError = http.StatusInternalServerError // 500 — внутренняя ошибка сервера
 )
 
 // WithdrawHandler handles POST `/api/user/balance/withdraw`.
 func (h *BaseHandler) WithdrawHandler(ctx echo.Context) error {
 	withdrawInternal := accrual.WithdrawAccrual{} //nolint:exhaustruct
 	if err := ctx.Bind(&withdrawInternal); err != nil {
-		return WrapHandlerErr(ctx, http.StatusUnprocessableEntity,
-			"WithdrawalHandler: failed to get withdrawInternal: %s", fmt.Errorf("%w", err))
+		return problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidWithdraw,
+			"failed to parse withdrawal", err)
 	}
-	zap.S().Infoln("WithdrawalHandler:", "withdrawInternal:", withdrawInternal)
+	logging.FromEcho(ctx).Infoln("WithdrawalHandler:", "withdrawInternal:", withdrawInternal)
 	username := GetAuthFromCtx(ctx)
 
 	withdraw := withdrawInternal.GetWithdrawExt(username, time.Now())
 
-	err := repository.ProcessWithdraw(h.conn, *withdraw)
-
-	var respStatus int
-
+	err := repository.ProcessWithdraw(ctx.Request().Context(), h.conn, *withdraw)
 	switch {
-	case err == nil:
-		respStatus = withdrawAccepted
+	case errors.Is(err, repository.ErrWithdrawInvalid):
+		return problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidWithdraw,
+			"the sum must be positive", err)
 	case errors.Is(err, repository.ErrWithdrawNoMoney):
-		respStatus = withdrawNotEnough
-	default:
-		respStatus = withdrawInternalError
+		return problem.New(http.StatusPaymentRequired, problem.CodeInsufficientFunds,
+			"there are not enough points", err)
+	case err != nil:
+		return problem.Internal(err)
 	}
 
-	_ = ctx.NoContent(respStatus)
+	_ = ctx.NoContent(http.StatusOK)
 
 	return nil
 }
@@ -6,27 +6,22 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/repository"
 	"github.com/labstack/echo/v4"
-	"go.uber.org/zap"
 )
 
 // WithdrawsListHandler handles GET `/api/user/withdrawals`.
 func (h *BaseHandler) WithdrawsListHandler(ctx echo.Context) error {
 	username := GetAuthFromCtx(ctx)
-	withdraws, err := repository.FindWithdrawsByUsername(h.conn, username)
+	withdraws, err := repository.FindWithdrawsByUsername(ctx.Request().Context(), h.conn, username)
 	if err != nil {
-		var status int
-		var logStr string
-		if errors.Is(err, repository.ErrWithdrawsNoItems) {
-			logStr = fmt.Sprintf("%s %s", "WithdrawsListHandler:", "no items:")
-			status = http.StatusNoContent
-		} else {
-			logStr = fmt.Sprintf("%s %s %s", "WithdrawsListHandler:", "internal error:", err.Error())
-			status = http.StatusInternalServerError
+		if !errors.Is(err, repository.ErrWithdrawsNoItems) {
+			return problem.Internal(err)
 		}
-		zap.S().Warn(logStr)
-		_ = ctx.NoContent(status)
+		logging.FromEcho(ctx).Info("WithdrawsListHandler: no items")
+		_ = ctx.NoContent(http.StatusNoContent)
 
 		return nil
 	}
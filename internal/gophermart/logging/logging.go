@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// EchoKey is the key of the request-scoped logger in echo.Context.
+const EchoKey = "logger"
+
+// Field names shared by request-scoped loggers.
+const (
+	FieldRequestID = "request_id"
+	FieldTraceID   = "trace_id"
+	FieldMethod    = "method"
+	FieldRoute     = "route"
+	FieldUser      = "user"
+)
+
+type ctxKey struct{}
+
+// WithLogger returns a copy of ctx which carries the logger.
+func WithLogger(ctx context.Context, logger *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx
+// or the global logger when ctx carries none.
+func FromContext(ctx context.Context) *zap.SugaredLogger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(ctxKey{}).(*zap.SugaredLogger); ok && logger != nil {
+			return logger
+		}
+	}
+
+	return zap.S()
+}
+
+// FromEcho returns the request-scoped logger of echoCtx.
+func FromEcho(echoCtx echo.Context) *zap.SugaredLogger {
+	if logger, ok := echoCtx.Get(EchoKey).(*zap.SugaredLogger); ok && logger != nil {
+		return logger
+	}
+	if echoCtx.Request() == nil {
+		return zap.S()
+	}
+
+	return FromContext(echoCtx.Request().Context())
+}
+
+// SetEcho stores the logger in echoCtx and in the context of its request,
+// so the logger reaches the code which only gets context.Context.
+func SetEcho(echoCtx echo.Context, logger *zap.SugaredLogger) {
+	echoCtx.Set(EchoKey, logger)
+	req := echoCtx.Request()
+	echoCtx.SetRequest(req.WithContext(WithLogger(req.Context(), logger)))
+}
+
+// With adds the key-value pairs to the request-scoped logger of echoCtx.
+func With(echoCtx echo.Context, args ...interface{}) *zap.SugaredLogger {
+	logger := FromEcho(echoCtx).With(args...)
+	SetEcho(echoCtx, logger)
+
+	return logger
+}
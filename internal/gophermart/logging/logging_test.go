@@ -0,0 +1,40 @@
+package logging_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestFromContext(t *testing.T) {
+	logger := zap.NewNop().Sugar()
+
+	assert.Equal(t, zap.S(), logging.FromContext(context.Background()))
+	assert.Equal(t, logger, logging.FromContext(logging.WithLogger(context.Background(), logger)))
+}
+
+func TestEcho(t *testing.T) {
+	echoCtx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	assert.Equal(t, zap.S(), logging.FromEcho(echoCtx))
+
+	logger := zap.NewNop().Sugar()
+	logging.SetEcho(echoCtx, logger)
+	assert.Equal(t, logger, logging.FromEcho(echoCtx))
+	assert.Equal(t, logger, logging.FromContext(echoCtx.Request().Context()))
+
+	withUser := logging.With(echoCtx, logging.FieldUser, "user1")
+	assert.NotSame(t, logger, withUser)
+	assert.Equal(t, withUser, logging.FromEcho(echoCtx))
+	assert.Equal(t, withUser, logging.FromContext(echoCtx.Request().Context()))
+}
+
+func TestFromEchoWithoutRequest(t *testing.T) {
+	echoCtx := echo.New().NewContext(nil, nil)
+	assert.Equal(t, zap.S(), logging.FromEcho(echoCtx))
+}
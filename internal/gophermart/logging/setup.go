@@ -0,0 +1,142 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Supported encodings of log entries.
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+const (
+	samplingTick       = time.Second
+	samplingFirst      = 100
+	samplingThereafter = 100
+)
+
+var ErrUnknownFormat = errors.New("unknown log format")
+
+// Options describes how to build the logger.
+type Options struct {
+	// Level is one of zap levels, "info" when empty.
+	Level string
+	// Format is "json" or "console", "json" when empty.
+	Format string
+	// Sampling limits repeated entries to 100 per second after the first 100.
+	Sampling bool
+	// File is a path of the log file, stderr when empty.
+	File string
+	// MaxSizeMB is a size of the log file which triggers its rotation.
+	MaxSizeMB int
+	// MaxBackups is a number of rotated files to keep.
+	MaxBackups int
+	// MaxAgeDays is a number of days to keep rotated files.
+	MaxAgeDays int
+}
+
+// Logger is the configured logger and the level which can be changed at runtime.
+type Logger struct {
+	*zap.Logger
+	Level zap.AtomicLevel
+	file  *lumberjack.Logger
+}
+
+// Setup builds the logger described by opts.
+func Setup(opts Options) (*Logger, error) {
+	level := zap.NewAtomicLevel()
+	if opts.Level != "" {
+		if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
+			return nil, fmt.Errorf("failed to parse log level: %w", err)
+		}
+	}
+
+	encoder, err := newEncoder(opts.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Logger{Logger: nil, Level: level, file: nil}
+	sink := zapcore.Lock(os.Stderr)
+	if opts.File != "" {
+		result.file = &lumberjack.Logger{ //nolint:exhaustruct
+			Filename:   opts.File,
+			MaxSize:    opts.MaxSizeMB,
+			MaxBackups: opts.MaxBackups,
+			MaxAge:     opts.MaxAgeDays,
+		}
+		sink = zapcore.AddSync(result.file)
+	}
+
+	core := zapcore.NewCore(encoder, sink, level)
+	if opts.Sampling {
+		core = zapcore.NewSamplerWithOptions(core, samplingTick, samplingFirst, samplingThereafter)
+	}
+	result.Logger = zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+
+	return result, nil
+}
+
+func newEncoder(format string) (zapcore.Encoder, error) {
+	switch strings.ToLower(format) {
+	case "", FormatJSON:
+		return zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), nil
+	case FormatConsole:
+		encoderCfg := zap.NewDevelopmentEncoderConfig()
+		encoderCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+
+		return zapcore.NewConsoleEncoder(encoderCfg), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+}
+
+// Rotate closes the current log file and opens a new one,
+// it does nothing when the logger writes to stderr.
+func (l *Logger) Rotate() error {
+	if l.file == nil {
+		return nil
+	}
+	if err := l.file.Rotate(); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	return nil
+}
+
+// Close flushes buffered entries and closes the log file.
+func (l *Logger) Close() error {
+	_ = l.Sync()
+	if l.file == nil {
+		return nil
+	}
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+
+	return nil
+}
+
+// EchoLevel maps the current level to the level of echo's logger.
+func (l *Logger) EchoLevel() log.Lvl {
+	switch l.Level.Level() { //nolint:exhaustive
+	case zapcore.DebugLevel:
+		return log.DEBUG
+	case zapcore.InfoLevel:
+		return log.INFO
+	case zapcore.WarnLevel:
+		return log.WARN
+	default:
+		return log.ERROR
+	}
+}
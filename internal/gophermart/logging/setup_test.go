@@ -0,0 +1,88 @@
+package logging_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      logging.Options
+		wantLevel zapcore.Level
+		wantErr   bool
+	}{
+		{name: "defaults", opts: logging.Options{}, wantLevel: zapcore.InfoLevel},
+		{
+			name:      "debug console",
+			opts:      logging.Options{Level: "debug", Format: logging.FormatConsole},
+			wantLevel: zapcore.DebugLevel,
+		},
+		{
+			name:      "warn json sampling",
+			opts:      logging.Options{Level: "warn", Format: "JSON", Sampling: true},
+			wantLevel: zapcore.WarnLevel,
+		},
+		{name: "unknown level", opts: logging.Options{Level: "loud"}, wantErr: true},
+		{name: "unknown format", opts: logging.Options{Format: "xml"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			logger, err := logging.Setup(test.opts)
+			if test.wantErr {
+				assert.Error(t, err)
+
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantLevel, logger.Level.Level())
+			assert.NoError(t, logger.Rotate())
+		})
+	}
+}
+
+func TestSetupFile(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "gophermart.log")
+	logger, err := logging.Setup(logging.Options{File: logFile, MaxSizeMB: 1, MaxBackups: 1, MaxAgeDays: 1})
+	require.NoError(t, err)
+
+	logger.Debug("hidden")
+	logger.Info("first")
+	require.NoError(t, logger.Rotate())
+	logger.Info("second")
+	require.NoError(t, logger.Close())
+
+	got, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(got), `"msg":"second"`)
+	assert.NotContains(t, string(got), "first")
+	assert.NotContains(t, string(got), "hidden")
+
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(logFile), "gophermart-*.log"))
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestEchoLevel(t *testing.T) {
+	logger, err := logging.Setup(logging.Options{})
+	require.NoError(t, err)
+
+	for level, want := range map[zapcore.Level]log.Lvl{
+		zapcore.DebugLevel: log.DEBUG,
+		zapcore.InfoLevel:  log.INFO,
+		zapcore.WarnLevel:  log.WARN,
+		zapcore.ErrorLevel: log.ERROR,
+		zapcore.FatalLevel: log.ERROR,
+	} {
+		logger.Level.SetLevel(level)
+		assert.Equal(t, want, logger.EchoLevel(), level.String())
+	}
+}
@@ -0,0 +1,268 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+const namespace = "gophermart"
+
+// Outcomes of requests to 'Accrual'.
+const (
+	AccrualOutcomeOk               = "ok"
+	AccrualOutcomeNoContent        = "no_content"
+	AccrualOutcomeTooManyRequests  = "too_many_requests"
+	AccrualOutcomeUnexpectedStatus = "unexpected_status"
+	AccrualOutcomeError            = "error"
+)
+
+// Outcomes of attempts to deliver webhooks.
+const (
+	WebhookOutcomeDelivered = "delivered"
+	WebhookOutcomeRetried   = "retried"
+	WebhookOutcomeDead      = "dead"
+)
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{ //nolint:exhaustruct
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Count of handled HTTP requests.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{ //nolint:exhaustruct
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of handled HTTP requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	accrualRequests = prometheus.NewCounterVec(prometheus.CounterOpts{ //nolint:exhaustruct
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "requests_total",
+		Help:      "Count of requests to the accrual system by outcome.",
+	}, []string{"outcome"})
+
+	accrualCooldown = prometheus.NewCounter(prometheus.CounterOpts{ //nolint:exhaustruct
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "cooldown_seconds_total",
+		Help:      "Total time of cooldowns requested by the accrual system with 429 responses.",
+	})
+
+	accrualWorkers = prometheus.NewGauge(prometheus.GaugeOpts{ //nolint:exhaustruct
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "workers_in_flight",
+		Help:      "Count of running background workers requesting the accrual system.",
+	})
+
+	httpRateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{ //nolint:exhaustruct
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "rate_limited_total",
+		Help:      "Count of HTTP requests rejected by the rate limiter.",
+	}, []string{"route"})
+
+	eventSubscribers = prometheus.NewGauge(prometheus.GaugeOpts{ //nolint:exhaustruct
+		Namespace: namespace,
+		Subsystem: "events",
+		Name:      "subscribers",
+		Help:      "Count of subscribers of order events, e.g. open SSE streams.",
+	})
+
+	eventsDropped = prometheus.NewCounter(prometheus.CounterOpts{ //nolint:exhaustruct
+		Namespace: namespace,
+		Subsystem: "events",
+		Name:      "dropped_total",
+		Help:      "Count of order events dropped for slow subscribers.",
+	})
+
+	accrualFlagged = prometheus.NewCounter(prometheus.CounterOpts{ //nolint:exhaustruct
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "orders_flagged_total",
+		Help:      "Count of orders flagged for manual review as they are not final after the max age.",
+	})
+
+	webhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{ //nolint:exhaustruct
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "deliveries_total",
+		Help:      "Count of attempts to deliver webhooks by outcome.",
+	}, []string{"outcome"})
+
+	outboxDispatched = prometheus.NewCounter(prometheus.CounterOpts{ //nolint:exhaustruct
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "dispatched_total",
+		Help:      "Count of outbox records passed to all their handlers.",
+	})
+
+	outboxFailures = prometheus.NewCounter(prometheus.CounterOpts{ //nolint:exhaustruct
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "failures_total",
+		Help:      "Count of failed dispatches of the outbox, the failed records are dispatched again.",
+	})
+
+	listenReconnects = prometheus.NewCounter(prometheus.CounterOpts{ //nolint:exhaustruct
+		Namespace: namespace,
+		Subsystem: "events",
+		Name:      "listen_reconnects_total",
+		Help:      "Count of reconnections of the listener of the events of other instances.",
+	})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{ //nolint:exhaustruct
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Latency of DB queries.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"query"})
+
+	ordersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "orders"),
+		"Count of orders by status.",
+		[]string{"status"}, nil,
+	)
+)
+
+// OrdersCounter returns count of orders by their statuses.
+type OrdersCounter func(ctx context.Context) (map[string]int64, error)
+
+// NewRegistry returns a registry with all the collectors of the service.
+func NewRegistry(countOrders OrdersCounter) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}), //nolint:exhaustruct
+		httpRequests, httpDuration, httpRateLimited,
+		accrualRequests, accrualCooldown, accrualWorkers, accrualFlagged,
+		eventSubscribers, eventsDropped,
+		webhookDeliveries, outboxDispatched, outboxFailures, listenReconnects,
+		dbQueryDuration,
+		&ordersCollector{countOrders: countOrders},
+	)
+
+	return registry
+}
+
+// Handler returns a handler of GET `/metrics`.
+func Handler(registry *prometheus.Registry) echo.HandlerFunc {
+	return echo.WrapHandler(promhttp.HandlerFor(registry, promhttp.HandlerOpts{})) //nolint:exhaustruct
+}
+
+// ObserveHTTPRequest registers a handled HTTP request.
+func ObserveHTTPRequest(method string, route string, status int, latency time.Duration) {
+	statusStr := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, statusStr).Inc()
+	httpDuration.WithLabelValues(method, route, statusStr).Observe(latency.Seconds())
+}
+
+// ObserveRateLimited registers a request rejected by the rate limiter.
+func ObserveRateLimited(route string) {
+	httpRateLimited.WithLabelValues(route).Inc()
+}
+
+// ObserveAccrualRequest registers a request to 'Accrual'.
+func ObserveAccrualRequest(outcome string) {
+	accrualRequests.WithLabelValues(outcome).Inc()
+}
+
+// AddAccrualCooldown registers a cooldown requested by 'Accrual'.
+func AddAccrualCooldown(seconds int64) {
+	accrualCooldown.Add(float64(seconds))
+}
+
+// ObserveOrderFlagged registers an order flagged for manual review.
+func ObserveOrderFlagged() {
+	accrualFlagged.Inc()
+}
+
+// AccrualWorkerStarted registers a started background worker.
+func AccrualWorkerStarted() {
+	accrualWorkers.Inc()
+}
+
+// AccrualWorkerFinished registers a finished background worker.
+func AccrualWorkerFinished() {
+	accrualWorkers.Dec()
+}
+
+// EventSubscribed registers a new subscriber of order events.
+func EventSubscribed() {
+	eventSubscribers.Inc()
+}
+
+// EventUnsubscribed registers a gone subscriber of order events.
+func EventUnsubscribed() {
+	eventSubscribers.Dec()
+}
+
+// ObserveEventDropped registers an order event dropped for a slow subscriber.
+func ObserveEventDropped() {
+	eventsDropped.Inc()
+}
+
+// ObserveWebhookDelivery registers an attempt to deliver a webhook.
+func ObserveWebhookDelivery(outcome string) {
+	webhookDeliveries.WithLabelValues(outcome).Inc()
+}
+
+// ObserveOutboxDispatched registers the dispatched outbox records.
+func ObserveOutboxDispatched(count int) {
+	outboxDispatched.Add(float64(count))
+}
+
+// ObserveOutboxFailed registers a failed dispatch of the outbox.
+func ObserveOutboxFailed() {
+	outboxFailures.Inc()
+}
+
+// ObserveListenReconnect registers a reconnection of the listener of the events.
+func ObserveListenReconnect() {
+	listenReconnects.Inc()
+}
+
+// ObserveDBQuery registers a latency of the DB query.
+func ObserveDBQuery(query string, latency time.Duration) {
+	dbQueryDuration.WithLabelValues(query).Observe(latency.Seconds())
+}
+
+type ordersCollector struct {
+	countOrders OrdersCounter
+}
+
+func (c *ordersCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- ordersDesc
+}
+
+func (c *ordersCollector) Collect(metrics chan<- prometheus.Metric) {
+	if c.countOrders == nil {
+		return
+	}
+	timeout := 5
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	counts, err := c.countOrders(ctx)
+	if err != nil {
+		zap.S().Warnf("metrics: failed to count orders: %s", err.Error())
+
+		return
+	}
+	for status, count := range counts {
+		metrics <- prometheus.MustNewConstMetric(ordersDesc, prometheus.GaugeValue, float64(count), status)
+	}
+}
@@ -0,0 +1,62 @@
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/metrics"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, counter metrics.OrdersCounter) string {
+	t.Helper()
+	echoFr := echo.New()
+	defer echoFr.Close()
+	echoFr.GET("/metrics", metrics.Handler(metrics.NewRegistry(counter)))
+
+	req := httptest.NewRequest(echo.GET, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	echoFr.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	return rec.Body.String()
+}
+
+func TestHandler(t *testing.T) {
+	metrics.ObserveHTTPRequest(echo.GET, "/api/user/orders", http.StatusOK, time.Millisecond)
+	metrics.ObserveAccrualRequest(metrics.AccrualOutcomeTooManyRequests)
+	metrics.AddAccrualCooldown(60)
+	metrics.AccrualWorkerStarted()
+	metrics.AccrualWorkerFinished()
+	metrics.ObserveDBQuery("AddOrder", time.Millisecond)
+
+	got := scrape(t, func(ctx context.Context) (map[string]int64, error) {
+		return map[string]int64{"NEW": 3}, nil
+	})
+
+	assert.Contains(t, got,
+		`gophermart_http_requests_total{method="GET",route="/api/user/orders",status="200"} 1`)
+	assert.Contains(t, got, `gophermart_http_request_duration_seconds_count{method="GET",route="/api/user/orders"`)
+	assert.Contains(t, got, `gophermart_accrual_requests_total{outcome="too_many_requests"} 1`)
+	assert.Contains(t, got, `gophermart_accrual_cooldown_seconds_total 60`)
+	assert.Contains(t, got, `gophermart_accrual_workers_in_flight 0`)
+	assert.Contains(t, got, `gophermart_db_query_duration_seconds_count{query="AddOrder"} 1`)
+	assert.Contains(t, got, `gophermart_orders{status="NEW"} 3`)
+	assert.Contains(t, got, `go_goroutines`)
+}
+
+func TestHandlerOrdersErr(t *testing.T) {
+	got := scrape(t, func(ctx context.Context) (map[string]int64, error) {
+		return nil, io.EOF
+	})
+	assert.NotContains(t, got, `gophermart_orders{`)
+
+	got = scrape(t, nil)
+	assert.NotContains(t, got, `gophermart_orders{`)
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/labstack/echo/v4"
+)
+
+// HeaderXAdminToken is the header which carries the token of admin endpoints.
+const HeaderXAdminToken = "X-Admin-Token"
+
+var errWrongAdminToken = errors.New("wrong admin token")
+
+// AdminValidator checks 'X-Admin-Token' header against the configured token.
+func AdminValidator(token string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(echoCtx echo.Context) error {
+			got := echoCtx.Request().Header.Get(HeaderXAdminToken)
+			if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				logging.FromEcho(echoCtx).Warn("AdminValidator: wrong admin token")
+
+				return problem.New(http.StatusUnauthorized, problem.CodeUnauthorized,
+					"failed to check an admin token", errWrongAdminToken)
+			}
+			logging.With(echoCtx, logging.FieldUser, "admin")
+
+			return next(echoCtx)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminValidator(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		header     string
+		wantStatus int
+	}{
+		{name: "ok", token: "secret", header: "secret", wantStatus: http.StatusOK},
+		{name: "wrong token", token: "secret", header: "guess", wantStatus: http.StatusUnauthorized},
+		{name: "no header", token: "secret", header: "", wantStatus: http.StatusUnauthorized},
+		{name: "not configured", token: "", header: "", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			echoFramework := echo.New()
+			echoFramework.HTTPErrorHandler = handler.HTTPErrorHandler
+			echoFramework.GET("/admin", func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			}, AdminValidator(test.token))
+
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if test.header != "" {
+				req.Header.Set(HeaderXAdminToken, test.header)
+			}
+			rec := httptest.NewRecorder()
+			echoFramework.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantStatus, rec.Code)
+			if test.wantStatus != http.StatusOK {
+				assert.Contains(t, rec.Body.String(), problem.CodeUnauthorized)
+			}
+		})
+	}
+}
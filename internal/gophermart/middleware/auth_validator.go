@@ -1,15 +1,18 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
 
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
 	"github.com/labstack/echo/v4"
-	"go.uber.org/zap"
 )
 
+// UserKey is the key of the authorized login in echo.Context.
+const UserKey = "user"
+
 // AuthValidator checks 'Authorization' header and its value.
 func AuthValidator(dbConn *sqldb.PgxIface) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -20,24 +23,22 @@ func AuthValidator(dbConn *sqldb.PgxIface) echo.MiddlewareFunc {
 }
 
 func validate(echoCtx echo.Context, dbConn *sqldb.PgxIface, next echo.HandlerFunc) error {
-	zap.S().Info("AuthValidator: ", echoCtx.Request().Method, " ", echoCtx.Request().URL)
-	zap.S().Info("AuthValidator: Headers: ", echoCtx.Request().Header)
+	logger := logging.FromEcho(echoCtx)
+	logger.Debugf("AuthValidator: %s %s", echoCtx.Request().Method, echoCtx.Request().URL)
 	isAuthorized := handler.IsAuthorized(echoCtx, dbConn)
 	authHeader := echoCtx.Request().Header.Get("Authorization")
 	if !isAuthorized {
-		zap.S().Warn("AuthValidator: failed to check an authorization for: [%s]",
+		logger.Warnf("AuthValidator: failed to check an authorization for: [%s]",
 			authHeader)
-		err := handler.WrapHandlerErr(echoCtx, http.StatusUnauthorized,
-			"AuthValidator: failed to check an authorization: %s", handler.ErrUnauthorised)
 
-		return fmt.Errorf("%w", err)
+		return problem.New(http.StatusUnauthorized, problem.CodeUnauthorized,
+			"failed to check an authorization", handler.ErrUnauthorised)
 	}
 
-	zap.S().Infof("AuthValidator: Authorization header is correct for [%s]",
-		authHeader)
-	if err := next(echoCtx); err != nil {
-		echoCtx.Error(err)
-	}
+	login := handler.GetAuthFromCtx(echoCtx)
+	echoCtx.Set(UserKey, login)
+	logging.With(echoCtx, logging.FieldUser, login).
+		Info("AuthValidator: Authorization header is correct")
 
-	return nil
+	return next(echoCtx)
 }
@@ -7,6 +7,8 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
 	"github.com/labstack/echo/v4"
 	"github.com/pashagolub/pgxmock/v2"
@@ -39,6 +41,7 @@ func TestAuthValidatorOk(t *testing.T) {
 		require.NoError(t, err)
 	}(echoFramework)
 
+	echoFramework.HTTPErrorHandler = handler.HTTPErrorHandler
 	echoFramework.Use(AuthValidator(&pgConn))
 	req := httptest.NewRequest(echo.GET, "/", nil)
 	req.Header.Add("Authorization", fmt.Sprintf("Authorization:[%s]", "login2"))
@@ -75,6 +78,7 @@ func TestAuthValidator401(t *testing.T) {
 		require.NoError(t, err)
 	}(echoFramework)
 
+	echoFramework.HTTPErrorHandler = handler.HTTPErrorHandler
 	echoFramework.Use(AuthValidator(&pgConn))
 	req := httptest.NewRequest(echo.GET, "/", nil)
 	req.Header.Add("Authorization", fmt.Sprintf("Authorization:[%s]", "login2"))
@@ -87,5 +91,5 @@ func TestAuthValidator401(t *testing.T) {
 	assert.NoError(t, err)
 
 	assert.Equal(t, http.StatusUnauthorized, rec.Code)
-	assert.Equal(t, rec.Body.String(), "AuthValidator: failed to check an authorization: unauthorized request")
+	assert.Contains(t, rec.Body.String(), problem.CodeUnauthorized)
 }
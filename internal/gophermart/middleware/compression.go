@@ -0,0 +1,286 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/labstack/echo/v4"
+)
+
+// Content codings supported in both directions,
+// 'deflate' is zlib format as HTTP defines it.
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+
+	encodingXGzip    = "x-gzip"
+	encodingIdentity = "identity"
+)
+
+var errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Compress compresses the responses with gzip or deflate chosen by 'Accept-Encoding'
+// once the body reaches minLength bytes, smaller bodies are sent as is.
+// The responses which already have 'Content-Encoding' are not touched.
+func Compress(level int, minLength int) echo.MiddlewareFunc {
+	pools := map[string]*sync.Pool{
+		EncodingGzip: {New: func() any {
+			writer, err := gzip.NewWriterLevel(io.Discard, level)
+			if err != nil {
+				writer = gzip.NewWriter(io.Discard)
+			}
+
+			return writer
+		}},
+		EncodingDeflate: {New: func() any {
+			writer, err := zlib.NewWriterLevel(io.Discard, level)
+			if err != nil {
+				writer = zlib.NewWriter(io.Discard)
+			}
+
+			return writer
+		}},
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(echoCtx echo.Context) error {
+			response := echoCtx.Response()
+			response.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+			encoding := negotiateEncoding(echoCtx.Request().Header.Get(echo.HeaderAcceptEncoding))
+			if encoding == "" || echoCtx.Request().Method == http.MethodHead {
+				return next(echoCtx)
+			}
+
+			writer := &compressWriter{
+				ResponseWriter: response.Writer,
+				encoding:       encoding,
+				pool:           pools[encoding],
+				minLength:      minLength,
+				encoder:        nil,
+				buf:            nil,
+				status:         http.StatusOK,
+				started:        false,
+			}
+			response.Writer = writer
+			defer func() { response.Writer = writer.ResponseWriter }()
+
+			// the error is rendered while the response is compressed, so it is not returned to be rendered again.
+			if err := next(echoCtx); err != nil {
+				echoCtx.Error(err)
+			}
+			if err := writer.finish(); err != nil {
+				return fmt.Errorf("failed to compress response: %w", err)
+			}
+
+			return nil
+		}
+	}
+}
+
+// negotiateEncoding picks the coding with the highest quality from 'Accept-Encoding',
+// gzip wins a tie, it returns "" when neither gzip nor deflate is acceptable.
+func negotiateEncoding(acceptEncoding string) string {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		quality := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			parsed, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if name == encodingXGzip {
+			name = EncodingGzip
+		}
+		qualities[name] = quality
+	}
+
+	best, bestQuality := "", 0.0
+	for _, name := range []string{EncodingGzip, EncodingDeflate} {
+		quality, ok := qualities[name]
+		if !ok {
+			quality, ok = qualities["*"]
+		}
+		if ok && quality > bestQuality {
+			best, bestQuality = name, quality
+		}
+	}
+
+	return best
+}
+
+// compressWriter holds the status and the body until minLength bytes
+// are written to decide whether the response is worth compressing.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding  string
+	pool      *sync.Pool
+	minLength int
+	encoder   encoder
+	buf       []byte
+	status    int
+	started   bool
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.started {
+		return w.write(data)
+	}
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.minLength {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(data), nil
+}
+
+// Flush sends the held body as is, a streaming handler wants it now.
+func (w *compressWriter) Flush() {
+	if !w.started {
+		_ = w.start(false)
+	}
+	if w.encoder != nil {
+		_ = w.encoder.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *compressWriter) write(data []byte) (int, error) {
+	if w.encoder != nil {
+		return w.encoder.Write(data) //nolint:wrapcheck
+	}
+
+	return w.ResponseWriter.Write(data) //nolint:wrapcheck
+}
+
+func (w *compressWriter) start(compress bool) error {
+	w.started = true
+	header := w.ResponseWriter.Header()
+	if compress && header.Get(echo.HeaderContentEncoding) == "" && bodyAllowed(w.status) {
+		header.Set(echo.HeaderContentEncoding, w.encoding)
+		header.Del(echo.HeaderContentLength)
+		w.encoder, _ = w.pool.Get().(encoder)
+		w.encoder.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := w.write(w.buf)
+	w.buf = nil
+
+	return err
+}
+
+func (w *compressWriter) finish() error {
+	if !w.started {
+		if err := w.start(false); err != nil {
+			return err
+		}
+	}
+	if w.encoder == nil {
+		return nil
+	}
+	err := w.encoder.Close()
+	w.encoder.Reset(io.Discard)
+	w.pool.Put(w.encoder)
+	w.encoder = nil
+
+	return err //nolint:wrapcheck
+}
+
+func bodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// Decompress decodes gzip and deflate request bodies, a decoded body larger
+// than maxSize bytes is rejected with 413, so a small decompression bomb cannot exhaust the memory.
+func Decompress(maxSize int64) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(echoCtx echo.Context) error {
+			req := echoCtx.Request()
+			encoding := strings.ToLower(strings.TrimSpace(req.Header.Get(echo.HeaderContentEncoding)))
+			if encoding == "" || encoding == encodingIdentity || req.Body == nil || req.Body == http.NoBody {
+				return next(echoCtx)
+			}
+
+			body, err := decodeBody(encoding, req.Body, maxSize)
+			switch {
+			case errors.Is(err, errUnsupportedEncoding):
+				return problem.New(http.StatusUnsupportedMediaType, problem.CodeUnsupportedEncoding,
+					fmt.Sprintf("content encoding [%s] is not supported", encoding), err)
+			case errors.Is(err, errBodyTooLarge):
+				return problem.New(http.StatusRequestEntityTooLarge, problem.CodeRequestTooLarge,
+					fmt.Sprintf("decoded body exceeds %d bytes", maxSize), err)
+			case err != nil:
+				return problem.New(http.StatusBadRequest, problem.CodeBadRequest,
+					"failed to decode request body", err)
+			}
+
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+			req.Header.Del(echo.HeaderContentEncoding)
+			req.Header.Set(echo.HeaderContentLength, strconv.Itoa(len(body)))
+
+			return next(echoCtx)
+		}
+	}
+}
+
+var errBodyTooLarge = errors.New("request body is too large")
+
+func decodeBody(encoding string, body io.Reader, maxSize int64) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+	switch encoding {
+	case EncodingGzip, encodingXGzip:
+		reader, err = gzip.NewReader(body)
+	case EncodingDeflate:
+		reader, err = zlib.NewReader(body)
+	default:
+		return nil, errUnsupportedEncoding
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	defer reader.Close()
+
+	decoded, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if int64(len(decoded)) > maxSize {
+		return nil, errBodyTooLarge
+	}
+
+	return decoded, nil
+}
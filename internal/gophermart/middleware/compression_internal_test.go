@@ -0,0 +1,257 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMinLength = 64
+
+func newCompressedEcho(body string) *echo.Echo {
+	echoFramework := echo.New()
+	echoFramework.HTTPErrorHandler = handler.HTTPErrorHandler
+	echoFramework.Use(Compress(gzip.DefaultCompression, testMinLength), Decompress(testMinLength))
+	echoFramework.GET("/body", func(c echo.Context) error {
+		return c.String(http.StatusOK, body)
+	})
+	echoFramework.GET("/empty", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+	echoFramework.GET("/precompressed", func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderContentEncoding, EncodingGzip)
+
+		return c.Blob(http.StatusOK, echo.MIMEOctetStream, []byte(body))
+	})
+	echoFramework.GET("/problem", func(c echo.Context) error {
+		return problem.New(http.StatusNotFound, problem.CodeNotFound, body, nil)
+	})
+	echoFramework.POST("/echo", func(c echo.Context) error {
+		data, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return c.Blob(http.StatusOK, echo.MIMETextPlain, data)
+	})
+
+	return echoFramework
+}
+
+func decode(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var reader io.ReadCloser
+	var err error
+	switch encoding {
+	case EncodingGzip:
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+	case EncodingDeflate:
+		reader, err = zlib.NewReader(bytes.NewReader(body))
+	default:
+		return string(body)
+	}
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(reader)
+	require.NoError(t, err)
+
+	return string(decoded)
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("12345678903,", 20)
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{name: "gzip", path: "/body", body: large, acceptEncoding: "gzip, deflate", wantEncoding: EncodingGzip},
+		{name: "deflate", path: "/body", body: large, acceptEncoding: "deflate", wantEncoding: EncodingDeflate},
+		{
+			name: "preferred deflate", path: "/body", body: large,
+			acceptEncoding: "gzip;q=0.5, deflate", wantEncoding: EncodingDeflate,
+		},
+		{name: "any", path: "/body", body: large, acceptEncoding: "*", wantEncoding: EncodingGzip},
+		{name: "refused gzip", path: "/body", body: large, acceptEncoding: "gzip;q=0, br", wantEncoding: ""},
+		{name: "not accepted", path: "/body", body: large, acceptEncoding: "", wantEncoding: ""},
+		{name: "below threshold", path: "/body", body: "small", acceptEncoding: "gzip", wantEncoding: ""},
+		{name: "no content", path: "/empty", body: large, acceptEncoding: "gzip", wantEncoding: ""},
+		{name: "error", path: "/problem", body: large, acceptEncoding: "gzip", wantEncoding: EncodingGzip},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if test.acceptEncoding != "" {
+				req.Header.Set(echo.HeaderAcceptEncoding, test.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			newCompressedEcho(test.body).ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantEncoding, rec.Header().Get(echo.HeaderContentEncoding))
+			assert.Equal(t, echo.HeaderAcceptEncoding, rec.Header().Get(echo.HeaderVary))
+			if rec.Code == http.StatusNoContent {
+				assert.Empty(t, rec.Body.Bytes())
+
+				return
+			}
+			assert.Contains(t, decode(t, test.wantEncoding, rec.Body.Bytes()), test.body)
+		})
+	}
+}
+
+func TestCompressRendersErrorOnce(t *testing.T) {
+	echoFramework := newCompressedEcho(strings.Repeat("12345678903,", 20))
+	rendered := 0
+	echoFramework.HTTPErrorHandler = func(err error, c echo.Context) {
+		rendered++
+		handler.HTTPErrorHandler(err, c)
+	}
+	var returned error
+	echoFramework.Pre(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			returned = next(c)
+
+			return returned
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/problem", nil)
+	req.Header.Set(echo.HeaderAcceptEncoding, EncodingGzip)
+	rec := httptest.NewRecorder()
+	echoFramework.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, 1, rendered)
+	assert.NoError(t, returned)
+}
+
+func TestCompressKeepsEncodedResponse(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/precompressed", nil)
+	req.Header.Set(echo.HeaderAcceptEncoding, EncodingDeflate)
+	rec := httptest.NewRecorder()
+	body := strings.Repeat("x", 2*testMinLength)
+	newCompressedEcho(body).ServeHTTP(rec, req)
+
+	assert.Equal(t, EncodingGzip, rec.Header().Get(echo.HeaderContentEncoding))
+	assert.Equal(t, body, rec.Body.String())
+}
+
+func TestCompressFlush(t *testing.T) {
+	echoFramework := echo.New()
+	echoFramework.Use(Compress(gzip.DefaultCompression, testMinLength))
+	echoFramework.GET("/stream", func(c echo.Context) error {
+		c.Response().WriteHeader(http.StatusOK)
+		_, _ = c.Response().Write([]byte("data: 1\n\n"))
+		c.Response().Flush()
+
+		return nil
+	})
+	req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+	req.Header.Set(echo.HeaderAcceptEncoding, EncodingGzip)
+	rec := httptest.NewRecorder()
+	echoFramework.ServeHTTP(rec, req)
+
+	assert.True(t, rec.Flushed)
+	assert.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+	assert.Equal(t, "data: 1\n\n", rec.Body.String())
+}
+
+func compressBody(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	if encoding == EncodingDeflate {
+		writer = zlib.NewWriter(&buf)
+	} else {
+		writer = gzip.NewWriter(&buf)
+	}
+	_, err := writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	order := []byte("12345678903")
+	tests := []struct {
+		name        string
+		encoding    string
+		body        []byte
+		wantStatus  int
+		wantBody    string
+		wantProblem string
+	}{
+		{
+			name: "gzip", encoding: EncodingGzip, body: compressBody(t, EncodingGzip, order),
+			wantStatus: http.StatusOK, wantBody: string(order),
+		},
+		{
+			name: "deflate", encoding: EncodingDeflate, body: compressBody(t, EncodingDeflate, order),
+			wantStatus: http.StatusOK, wantBody: string(order),
+		},
+		{name: "identity", encoding: "identity", body: order, wantStatus: http.StatusOK, wantBody: string(order)},
+		{name: "plain", encoding: "", body: order, wantStatus: http.StatusOK, wantBody: string(order)},
+		{
+			name: "bomb", encoding: EncodingGzip, body: compressBody(t, EncodingGzip, make([]byte, 1<<20)),
+			wantStatus: http.StatusRequestEntityTooLarge, wantProblem: problem.CodeRequestTooLarge,
+		},
+		{
+			name: "malformed", encoding: EncodingGzip, body: order,
+			wantStatus: http.StatusBadRequest, wantProblem: problem.CodeBadRequest,
+		},
+		{
+			name: "unsupported", encoding: "br", body: order,
+			wantStatus: http.StatusUnsupportedMediaType, wantProblem: problem.CodeUnsupportedEncoding,
+		},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(test.body))
+			if test.encoding != "" {
+				req.Header.Set(echo.HeaderContentEncoding, test.encoding)
+			}
+			rec := httptest.NewRecorder()
+			newCompressedEcho("").ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantStatus, rec.Code)
+			if test.wantProblem != "" {
+				assert.Contains(t, rec.Body.String(), test.wantProblem)
+
+				return
+			}
+			assert.Equal(t, test.wantBody, rec.Body.String())
+		})
+	}
+}
+
+func TestDecompressForOrderValidator(t *testing.T) {
+	echoFramework := echo.New()
+	echoFramework.HTTPErrorHandler = handler.HTTPErrorHandler
+	echoFramework.Use(Decompress(testMinLength))
+	echoFramework.POST("/api/user/orders", func(c echo.Context) error {
+		return c.NoContent(http.StatusAccepted)
+	}, OrderValidator())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/orders",
+		bytes.NewReader(compressBody(t, EncodingGzip, []byte("12345678903"))))
+	req.Header.Set(echo.HeaderContentEncoding, EncodingGzip)
+	rec := httptest.NewRecorder()
+	echoFramework.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+}
@@ -5,15 +5,14 @@ import (
 	"io"
 	"net/http"
 
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/security"
 	"github.com/labstack/echo/v4"
-	"go.uber.org/zap"
 )
 
 // OrderValidator checks luhn number for order value.
 func OrderValidator() echo.MiddlewareFunc {
-	badLuhn := echo.NewHTTPError(http.StatusUnprocessableEntity, "bad order")
-
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(echoCtx echo.Context) error {
 			// Request
@@ -23,22 +22,20 @@ func OrderValidator() echo.MiddlewareFunc {
 			}
 			echoCtx.Request().Body = io.NopCloser(bytes.NewBuffer(reqBody)) // Reset
 			if len(reqBody) == 0 {
-				zap.S().Warnf("failed to find order number")
-
-				return badLuhn
+				return problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidOrderNumber,
+					"failed to find order number", nil)
 			}
+			// The body is logged only, it is not echoed to the client.
 			isValid := security.IsValidLuhnNumber(string(reqBody))
 			if !isValid {
-				zap.S().Warnf("bad lunh number for [%s]", string(reqBody))
+				logging.FromEcho(echoCtx).Infof("luhn number is not correct for %q", reqBody)
 
-				return badLuhn
-			}
-			zap.S().Infof("lunh number is correct for [%s]", string(reqBody))
-			if err := next(echoCtx); err != nil {
-				echoCtx.Error(err)
+				return problem.New(http.StatusUnprocessableEntity, problem.CodeInvalidOrderNumber,
+					"invalid order number", nil)
 			}
+			logging.FromEcho(echoCtx).Infof("luhn number is correct for %q", reqBody)
 
-			return nil
+			return next(echoCtx)
 		}
 	}
 }
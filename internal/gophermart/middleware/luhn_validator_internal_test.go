@@ -6,12 +6,15 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestOrderValidatorMiddleware(t *testing.T) {
 	echoFramework := echo.New()
+	echoFramework.HTTPErrorHandler = handler.HTTPErrorHandler
 	echoFramework.Use(OrderValidator())
 	req := httptest.NewRequest(echo.GET, "/", nil)
 	rec := httptest.NewRecorder()
@@ -20,7 +23,7 @@ func TestOrderValidatorMiddleware(t *testing.T) {
 	echoFramework.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
-	assert.Equal(t, rec.Body.String(), "{\"message\":\"bad order\"}\n")
+	assert.Contains(t, rec.Body.String(), problem.CodeInvalidOrderNumber)
 }
 
 const (
@@ -30,6 +33,7 @@ const (
 
 func TestOrderValidatorMiddleware404(t *testing.T) {
 	echoFramework := echo.New()
+	echoFramework.HTTPErrorHandler = handler.HTTPErrorHandler
 	echoFramework.Use(OrderValidator())
 	req := httptest.NewRequest(echo.GET, "/", strings.NewReader(okOrder))
 	rec := httptest.NewRecorder()
@@ -38,11 +42,12 @@ func TestOrderValidatorMiddleware404(t *testing.T) {
 	echoFramework.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusNotFound, rec.Code)
-	assert.Equal(t, rec.Body.String(), "{\"message\":\"Not Found\"}\n")
+	assert.Contains(t, rec.Body.String(), problem.CodeNotFound)
 }
 
 func TestOrderValidatorMiddleware422(t *testing.T) {
 	echoFramework := echo.New()
+	echoFramework.HTTPErrorHandler = handler.HTTPErrorHandler
 	echoFramework.Use(OrderValidator())
 	req := httptest.NewRequest(echo.GET, "/", strings.NewReader(badOrder))
 	rec := httptest.NewRecorder()
@@ -51,5 +56,6 @@ func TestOrderValidatorMiddleware422(t *testing.T) {
 	echoFramework.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
-	assert.Equal(t, rec.Body.String(), "{\"message\":\"bad order\"}\n")
+	assert.Contains(t, rec.Body.String(), problem.CodeInvalidOrderNumber)
+	assert.NotContains(t, rec.Body.String(), badOrder, "the body is not echoed to the client")
 }
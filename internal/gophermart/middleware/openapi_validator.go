@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/openapi"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	msgRequestDoesNotMatch = "request does not match the API specification"
+	mimeTextEventStream    = "text/event-stream"
+)
+
+// OpenAPIValidator rejects requests which do not match the OpenAPI document with 400,
+// the routes which are absent in the document are passed as is.
+// Authorization is checked by AuthValidator, so it goes after it.
+// When validateResponses is set, the responses which do not match the document are logged.
+func OpenAPIValidator(doc *openapi3.T, validateResponses bool) echo.MiddlewareFunc {
+	options := &openapi3filter.Options{ //nolint:exhaustruct
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(echoCtx echo.Context) error {
+			route := openapi.FindRoute(doc, echoCtx.Request().Method, echoCtx.Path())
+			if route == nil {
+				return next(echoCtx)
+			}
+
+			input := &openapi3filter.RequestValidationInput{ //nolint:exhaustruct
+				Request:    echoCtx.Request(),
+				PathParams: pathParams(echoCtx),
+				Route:      route,
+				Options:    options,
+			}
+			if err := openapi3filter.ValidateRequest(echoCtx.Request().Context(), input); err != nil {
+				return problem.New(http.StatusBadRequest, problem.CodeBadRequest, describeValidationError(err), err)
+			}
+
+			if !validateResponses || isStream(route) {
+				return next(echoCtx)
+			}
+
+			return validateResponse(echoCtx, next, input)
+		}
+	}
+}
+
+// validateResponse runs the handler, renders its error
+// and checks the written response against the document.
+func validateResponse(echoCtx echo.Context, next echo.HandlerFunc, input *openapi3filter.RequestValidationInput) error {
+	response := echoCtx.Response()
+	recorder := &bodyRecorder{ResponseWriter: response.Writer, body: bytes.Buffer{}}
+	response.Writer = recorder
+	defer func() { response.Writer = recorder.ResponseWriter }()
+
+	err := next(echoCtx)
+	if err != nil {
+		echoCtx.Error(err)
+	}
+
+	responseInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 response.Status,
+		Header:                 response.Header(),
+		Body:                   io.NopCloser(bytes.NewReader(recorder.body.Bytes())),
+		Options: &openapi3filter.Options{ //nolint:exhaustruct
+			IncludeResponseStatus: true,
+		},
+	}
+	if validationErr := openapi3filter.ValidateResponse(echoCtx.Request().Context(), responseInput); validationErr != nil {
+		logging.FromEcho(echoCtx).Warnf("OpenAPIValidator: response does not match the API specification: %s",
+			describeValidationError(validationErr))
+	}
+
+	return err
+}
+
+// isStream reports whether the operation streams events,
+// such responses are endless, so they are not recorded.
+func isStream(route *routers.Route) bool {
+	for _, response := range route.Operation.Responses {
+		if response.Value != nil && response.Value.Content.Get(mimeTextEventStream) != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func pathParams(echoCtx echo.Context) map[string]string {
+	names := echoCtx.ParamNames()
+	values := echoCtx.ParamValues()
+	params := make(map[string]string, len(names))
+	for idx, name := range names {
+		if idx < len(values) {
+			params[name] = values[idx]
+		}
+	}
+
+	return params
+}
+
+// describeValidationError returns a short reason of the failed validation
+// without the dump of the schema.
+func describeValidationError(err error) string {
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		if field := strings.Join(schemaErr.JSONPointer(), "."); field != "" {
+			return msgRequestDoesNotMatch + ": " + field + ": " + schemaErr.Reason
+		}
+
+		return msgRequestDoesNotMatch + ": " + schemaErr.Reason
+	}
+	var requestErr *openapi3filter.RequestError
+	if errors.As(err, &requestErr) && requestErr.Reason != "" {
+		return msgRequestDoesNotMatch + ": " + requestErr.Reason
+	}
+	var responseErr *openapi3filter.ResponseError
+	if errors.As(err, &responseErr) && responseErr.Reason != "" {
+		return responseErr.Reason
+	}
+
+	return msgRequestDoesNotMatch
+}
+
+// bodyRecorder copies the written body for the validation.
+type bodyRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *bodyRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+
+	return r.ResponseWriter.Write(data) //nolint:wrapcheck
+}
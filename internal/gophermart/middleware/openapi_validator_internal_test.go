@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/openapi"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestOpenAPIValidatorRequests(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		wantStatus  int
+		wantDetail  string
+	}{
+		{
+			name: "valid credentials", method: http.MethodPost, target: "/api/user/register",
+			contentType: echo.MIMEApplicationJSON, body: `{"login":"user","password":"pass"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "missing password", method: http.MethodPost, target: "/api/user/register",
+			contentType: echo.MIMEApplicationJSON, body: `{"login":"user"}`,
+			wantStatus: http.StatusBadRequest, wantDetail: `property \"password\" is missing`,
+		},
+		{
+			name: "malformed json", method: http.MethodPost, target: "/api/user/login",
+			contentType: echo.MIMEApplicationJSON, body: `{"login":`,
+			wantStatus: http.StatusBadRequest, wantDetail: msgRequestDoesNotMatch,
+		},
+		{
+			name: "wrong content type", method: http.MethodPost, target: "/api/user/orders",
+			contentType: echo.MIMEApplicationJSON, body: `{"order":"12345678903"}`,
+			wantStatus: http.StatusBadRequest, wantDetail: msgRequestDoesNotMatch,
+		},
+		{
+			name: "sum is a string", method: http.MethodPost, target: "/api/user/balance/withdraw",
+			contentType: echo.MIMEApplicationJSON, body: `{"order":"2377225624","sum":"751"}`,
+			wantStatus: http.StatusBadRequest, wantDetail: "sum",
+		},
+		{
+			name: "negative sum", method: http.MethodPost, target: "/api/user/balance/withdraw",
+			contentType: echo.MIMEApplicationJSON, body: `{"order":"2377225624","sum":-751}`,
+			wantStatus: http.StatusBadRequest, wantDetail: "sum",
+		},
+		{
+			name: "order number", method: http.MethodPost, target: "/api/user/orders",
+			contentType: echo.MIMETextPlain, body: "12345678903",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "route out of the document", method: http.MethodPost, target: "/internal",
+			contentType: echo.MIMETextPlain, body: "anything",
+			wantStatus: http.StatusOK,
+		},
+	}
+	echoFramework := echo.New()
+	echoFramework.HTTPErrorHandler = handler.HTTPErrorHandler
+	validate := OpenAPIValidator(openapi.MustLoad(), false)
+	ok := func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}
+	for _, path := range []string{
+		"/api/user/register", "/api/user/login", "/api/user/orders",
+		"/api/user/balance/withdraw", "/internal",
+	} {
+		echoFramework.POST(path, ok, validate)
+	}
+
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			req.Header.Set(echo.HeaderContentType, test.contentType)
+			rec := httptest.NewRecorder()
+			echoFramework.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantStatus, rec.Code)
+			if test.wantStatus != http.StatusOK {
+				assert.Contains(t, rec.Body.String(), problem.CodeBadRequest)
+				assert.Contains(t, rec.Body.String(), test.wantDetail)
+				assert.NotContains(t, rec.Body.String(), "Schema:")
+			}
+		})
+	}
+}
+
+func TestOpenAPIValidatorKeepsBody(t *testing.T) {
+	echoFramework := echo.New()
+	var got string
+	echoFramework.POST("/api/user/orders", func(c echo.Context) error {
+		body, _ := io.ReadAll(c.Request().Body)
+		got = string(body)
+
+		return c.NoContent(http.StatusAccepted)
+	}, OpenAPIValidator(openapi.MustLoad(), true))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/orders", strings.NewReader("12345678903"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMETextPlain)
+	rec := httptest.NewRecorder()
+	echoFramework.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "12345678903", got)
+}
+
+func TestOpenAPIValidatorResponses(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	original := zap.L()
+	zap.ReplaceGlobals(zap.New(core))
+	t.Cleanup(func() { zap.ReplaceGlobals(original) })
+
+	echoFramework := echo.New()
+	echoFramework.HTTPErrorHandler = handler.HTTPErrorHandler
+	validate := OpenAPIValidator(openapi.MustLoad(), true)
+	echoFramework.GET("/api/user/balance", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]any{"current": "500.5"})
+	}, validate)
+	echoFramework.GET("/api/user/withdrawals", func(c echo.Context) error {
+		return problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, "no token", nil)
+	}, validate)
+
+	rec := httptest.NewRecorder()
+	echoFramework.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/user/withdrawals", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	mismatches := logs.FilterMessageSnippet("response does not match the API specification")
+	assert.Equal(t, 0, mismatches.Len(), "problem responses match the document")
+
+	rec = httptest.NewRecorder()
+	echoFramework.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/user/balance", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"current":"500.5"}`, rec.Body.String())
+	mismatches = logs.FilterMessageSnippet("response does not match the API specification")
+	assert.Equal(t, 1, mismatches.Len())
+}
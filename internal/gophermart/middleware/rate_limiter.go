@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/metrics"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/ratelimit"
+	"github.com/labstack/echo/v4"
+)
+
+// Headers of rate limited responses.
+const (
+	HeaderRetryAfter         = "Retry-After"
+	HeaderXRateLimitLimit    = "X-RateLimit-Limit"
+	HeaderXRateLimitRemains  = "X-RateLimit-Remaining"
+	rateLimitKeySeparator    = "|"
+	rateLimitSubjectUserPref = "user:"
+	rateLimitSubjectIPPref   = "ip:"
+)
+
+// RateLimiter limits requests of the route per authenticated user
+// or per IP for anonymous requests, so it goes after AuthValidator.
+// The requests are let through when the store fails.
+func RateLimiter(store ratelimit.Store, route string, limit ratelimit.Limit) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if limit.Unlimited() {
+			return next
+		}
+
+		return func(echoCtx echo.Context) error {
+			key := route + rateLimitKeySeparator + rateLimitSubject(echoCtx)
+			result, err := store.Take(echoCtx.Request().Context(), key, limit)
+			if err != nil {
+				logging.FromEcho(echoCtx).Warnf("RateLimiter: failed to take a token: %s", err.Error())
+
+				return next(echoCtx)
+			}
+
+			header := echoCtx.Response().Header()
+			header.Set(HeaderXRateLimitLimit, strconv.Itoa(limit.Burst))
+			header.Set(HeaderXRateLimitRemains, strconv.Itoa(result.Remaining))
+			if !result.Allowed {
+				header.Set(HeaderRetryAfter, strconv.FormatInt(result.RetryAfterSeconds(), 10))
+				metrics.ObserveRateLimited(route)
+
+				return problem.New(http.StatusTooManyRequests, problem.CodeTooManyRequests,
+					"too many requests, retry later", nil)
+			}
+
+			return next(echoCtx)
+		}
+	}
+}
+
+func rateLimitSubject(echoCtx echo.Context) string {
+	if user, ok := echoCtx.Get(UserKey).(string); ok && user != "" {
+		return rateLimitSubjectUserPref + user
+	}
+
+	return rateLimitSubjectIPPref + echoCtx.RealIP()
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/ratelimit"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTestStore = errors.New("store is down")
+
+type testStore struct {
+	keys   []string
+	result ratelimit.Result
+	err    error
+}
+
+func (s *testStore) Take(_ context.Context, key string, _ ratelimit.Limit) (ratelimit.Result, error) {
+	s.keys = append(s.keys, key)
+
+	return s.result, s.err
+}
+
+func newRateLimitedEcho(store ratelimit.Store, limit ratelimit.Limit, user string) *echo.Echo {
+	echoFramework := echo.New()
+	echoFramework.HTTPErrorHandler = handler.HTTPErrorHandler
+	setUser := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if user != "" {
+				c.Set(UserKey, user)
+			}
+
+			return next(c)
+		}
+	}
+	echoFramework.GET("/api/user/balance", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, setUser, RateLimiter(store, "GET /api/user/balance", limit))
+
+	return echoFramework
+}
+
+func TestRateLimiterKeys(t *testing.T) {
+	store := &testStore{result: ratelimit.Result{Allowed: true, Remaining: 4, RetryAfter: 0}, err: nil}
+	limit := ratelimit.Limit{Rate: 1, Burst: 5}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/balance", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	rec := httptest.NewRecorder()
+	newRateLimitedEcho(store, limit, "user1").ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "5", rec.Header().Get(HeaderXRateLimitLimit))
+	assert.Equal(t, "4", rec.Header().Get(HeaderXRateLimitRemains))
+
+	rec = httptest.NewRecorder()
+	newRateLimitedEcho(store, limit, "").ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, []string{"GET /api/user/balance|user:user1", "GET /api/user/balance|ip:192.0.2.1"}, store.keys)
+}
+
+func TestRateLimiterTooManyRequests(t *testing.T) {
+	store := &testStore{result: ratelimit.Result{Allowed: false, Remaining: 0, RetryAfter: 1500 * time.Millisecond}}
+	rec := httptest.NewRecorder()
+	newRateLimitedEcho(store, ratelimit.Limit{Rate: 1, Burst: 5}, "user1").
+		ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/user/balance", nil))
+
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get(HeaderRetryAfter))
+	assert.Equal(t, "0", rec.Header().Get(HeaderXRateLimitRemains))
+	assert.Contains(t, rec.Body.String(), problem.CodeTooManyRequests)
+}
+
+func TestRateLimiterFailsOpen(t *testing.T) {
+	store := &testStore{result: ratelimit.Result{Allowed: true, Remaining: 0, RetryAfter: 0}, err: errTestStore}
+	rec := httptest.NewRecorder()
+	newRateLimitedEcho(store, ratelimit.Limit{Rate: 1, Burst: 5}, "user1").
+		ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/user/balance", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get(HeaderXRateLimitLimit))
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	store := &testStore{} //nolint:exhaustruct
+	rec := httptest.NewRecorder()
+	newRateLimitedEcho(store, ratelimit.Limit{Rate: 0, Burst: 0}, "user1").
+		ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/user/balance", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, store.keys)
+}
+
+func TestRateLimiterMemoryStore(t *testing.T) {
+	echoFramework := newRateLimitedEcho(ratelimit.NewMemoryStore(), ratelimit.Limit{Rate: 0.001, Burst: 2}, "user1")
+	codes := make([]int, 0, 3)
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		echoFramework.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/user/balance", nil))
+		codes = append(codes, rec.Code)
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	maxRequestIDLen = 128
+	requestIDBytes  = 16
+)
+
+// RequestID takes 'X-Request-ID' of a request or generates a new one,
+// returns it back in the response and sets up the request-scoped logger.
+func RequestID() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(echoCtx echo.Context) error {
+			req := echoCtx.Request()
+			requestID := req.Header.Get(echo.HeaderXRequestID)
+			if !isValidRequestID(requestID) {
+				requestID = newRequestID()
+				req.Header.Set(echo.HeaderXRequestID, requestID)
+			}
+			echoCtx.Response().Header().Set(echo.HeaderXRequestID, requestID)
+
+			logger := zap.S().With(
+				logging.FieldRequestID, requestID,
+				logging.FieldMethod, req.Method,
+				logging.FieldRoute, echoCtx.Path(),
+			)
+			if spanCtx := trace.SpanContextFromContext(req.Context()); spanCtx.HasTraceID() {
+				logger = logger.With(logging.FieldTraceID, spanCtx.TraceID().String())
+			}
+			logging.SetEcho(echoCtx, logger)
+
+			return next(echoCtx)
+		}
+	}
+}
+
+// isValidRequestID accepts printable ASCII IDs of a reasonable length only,
+// anything else is not worth propagating into logs and responses.
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLen {
+		return false
+	}
+	for _, r := range requestID {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	buf := make([]byte, requestIDBytes)
+	if _, err := rand.Read(buf); err != nil {
+		zap.S().Warnf("RequestID: failed to generate an id: %s", err.Error())
+	}
+
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		wantSame bool
+	}{
+		{name: "propagated", incoming: "abc-123", wantSame: true},
+		{name: "generated", incoming: "", wantSame: false},
+		{name: "too long", incoming: strings.Repeat("a", maxRequestIDLen+1), wantSame: false},
+		{name: "not printable", incoming: "abc\x01", wantSame: false},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			restore := zap.ReplaceGlobals(zap.New(core))
+			defer restore()
+
+			echoFramework := echo.New()
+			echoFramework.Use(RequestID())
+			echoFramework.GET("/api/user/balance", func(c echo.Context) error {
+				logging.With(c, logging.FieldUser, "user1")
+				logging.FromContext(c.Request().Context()).Info("from repository")
+
+				return c.NoContent(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/api/user/balance", nil)
+			if test.incoming != "" {
+				req.Header.Set(echo.HeaderXRequestID, test.incoming)
+			}
+			rec := httptest.NewRecorder()
+			echoFramework.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(echo.HeaderXRequestID)
+			require.NotEmpty(t, got)
+			if test.wantSame {
+				assert.Equal(t, test.incoming, got)
+			} else {
+				assert.NotEqual(t, test.incoming, got)
+				assert.Len(t, got, 2*requestIDBytes)
+			}
+
+			entries := logs.FilterMessage("from repository").All()
+			require.Len(t, entries, 1)
+			fields := entries[0].ContextMap()
+			assert.Equal(t, got, fields[logging.FieldRequestID])
+			assert.Equal(t, "/api/user/balance", fields[logging.FieldRoute])
+			assert.Equal(t, http.MethodGet, fields[logging.FieldMethod])
+			assert.Equal(t, "user1", fields[logging.FieldUser])
+		})
+	}
+}
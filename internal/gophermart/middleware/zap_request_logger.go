@@ -1,22 +1,26 @@
 package middleware
 
 import (
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/metrics"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"go.uber.org/zap"
 )
 
 var logValuesFunc = func(c echo.Context, loggerValues middleware.RequestLoggerValues) error {
-	zap.S().Infow("request",
+	logger := logging.FromEcho(c)
+	logger.Infow("request",
 		zap.String("Method", loggerValues.Method),
 		zap.String("URI", loggerValues.URI),
 		zap.Duration("latency", loggerValues.Latency),
 	)
-	zap.S().Infow("response",
+	logger.Infow("response",
 		zap.Int("status", loggerValues.Status),
 		zap.String("length", loggerValues.ContentLength),
 		zap.Int64("size", loggerValues.ResponseSize),
 	)
+	metrics.ObserveHTTPRequest(loggerValues.Method, c.Path(), loggerValues.Status, loggerValues.Latency)
 
 	return nil
 }
@@ -30,6 +34,7 @@ func GetRequestLoggerConfig() middleware.RequestLoggerConfig {
 		LogContentLength: true,
 		LogResponseSize:  true,
 		LogMethod:        true,
+		HandleError:      true,
 		LogValuesFunc:    logValuesFunc,
 		LogHeaders:       []string{},
 	}
@@ -40,6 +45,6 @@ func GetRequestLoggerConfig() middleware.RequestLoggerConfig {
 // GetBodyLoggerHandler returns middleware.BodyDumpHandler.
 func GetBodyLoggerHandler() middleware.BodyDumpHandler {
 	return func(c echo.Context, reqBody, resBody []byte) {
-		zap.S().Debugf("body:[%s]", string(reqBody))
+		logging.FromEcho(c).Debugf("body:[%s]", string(reqBody))
 	}
 }
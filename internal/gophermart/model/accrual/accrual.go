@@ -7,11 +7,72 @@ const (
 	OrderStatusProcessing = "PROCESSING"
 	OrderStatusInvalid    = "INVALID"
 	OrderStatusProcessed  = "PROCESSED"
+	// OrderStatusRegistered is a status of the accrual system only,
+	// the order is registered there but not processed yet.
+	OrderStatusRegistered = "REGISTERED"
+)
+
+// Statuses of a withdrawal: it may be cancelled by the user while it is pending
+// and refunded by an operator until it is cancelled.
+const (
+	WithdrawStatusPending   = "PENDING"
+	WithdrawStatusCompleted = "COMPLETED"
+	WithdrawStatusCancelled = "CANCELLED"
+	WithdrawStatusRefunded  = "REFUNDED"
+)
+
+// Accounts of the ledger: every movement is moved from one account to another,
+// the balance of a user is a sum of the entries of their user account.
+const (
+	LedgerAccountUser        = "USER"
+	LedgerAccountAccrual     = "ACCRUAL"
+	LedgerAccountWithdrawals = "WITHDRAWALS"
+	LedgerAccountAdjustments = "ADJUSTMENTS"
+	LedgerAccountExpired     = "EXPIRED"
+	LedgerAccountBonuses     = "BONUSES"
+	LedgerAccountCampaigns   = "CAMPAIGNS"
+	LedgerAccountReferrals   = "REFERRALS"
+)
+
+// Kinds of the ledger transactions.
+const (
+	LedgerKindAccrual        = "ACCRUAL"
+	LedgerKindWithdrawal     = "WITHDRAWAL"
+	LedgerKindWithdrawCancel = "WITHDRAWAL_CANCEL"
+	LedgerKindWithdrawRefund = "WITHDRAWAL_REFUND"
+	LedgerKindAdjustment     = "ADJUSTMENT"
+	LedgerKindTransfer       = "TRANSFER"
+	LedgerKindExpiry         = "EXPIRY"
+	LedgerKindTierBonus      = "TIER_BONUS"
+	LedgerKindCampaignBonus  = "CAMPAIGN_BONUS"
+	LedgerKindReferralBonus  = "REFERRAL_BONUS"
 )
 
 type BalanceExt struct {
 	Current   float32 `json:"current"`
 	Withdrawn float32 `json:"withdrawn"`
+	// ExpiringSoon is absent when the points do not expire.
+	ExpiringSoon []ExpiringPoints `json:"expiring_soon,omitempty"` //nolint:tagliatelle
+}
+
+// ProfileExt is the loyalty tier of a user, the next tier is absent for the highest tier.
+//
+//nolint:tagliatelle
+type ProfileExt struct {
+	Login             string  `json:"login"`
+	LifetimeAccrual   float32 `json:"lifetime_accrual"`
+	Tier              string  `json:"tier"`
+	Multiplier        float32 `json:"multiplier"`
+	NextTier          string  `json:"next_tier,omitempty"`
+	NextTierThreshold float32 `json:"next_tier_threshold,omitempty"`
+	// Progress is the part of the way from the current tier to the next one from 0 to 1.
+	Progress float32 `json:"progress"`
+}
+
+// ExpiringPoints is the points which expire in the day of Date.
+type ExpiringPoints struct {
+	Amount float32   `json:"amount"`
+	Date   time.Time `json:"date"`
 }
 
 type OrderAccrual struct {
@@ -26,12 +87,53 @@ type WithdrawAccrual struct {
 }
 
 type WithdrawExt struct {
+	ID          int64     `json:"-"`
 	Order       string    `json:"order"`
 	Sum         float32   `json:"sum"`
 	ProcessedAt time.Time `json:"processed_at"` //nolint:tagliatelle
+	Status      string    `json:"status"`
+	Username    string    `json:"-"`
+}
+
+type TransferRequest struct {
+	Recipient string  `json:"recipient"`
+	Amount    float32 `json:"amount"`
+}
+
+// TransferExt is a transfer of points from the balance of Username to the balance of Recipient.
+type TransferExt struct {
+	Recipient   string    `json:"recipient"`
+	Amount      float32   `json:"amount"`
+	ProcessedAt time.Time `json:"processed_at"` //nolint:tagliatelle
 	Username    string    `json:"-"`
 }
 
+// TransactionExt is an entry of the history of a balance,
+// Balance is the balance after the transaction.
+type TransactionExt struct {
+	ID           int64     `json:"id"`
+	Type         string    `json:"type"`
+	Amount       float32   `json:"amount"`
+	Balance      float32   `json:"balance"`
+	Order        string    `json:"order,omitempty"`
+	Counterparty string    `json:"counterparty,omitempty"`
+	Description  string    `json:"description,omitempty"`
+	CreatedAt    time.Time `json:"created_at"` //nolint:tagliatelle
+}
+
+// TransactionsPage is a page of the history, the next page is of the transactions after NextAfter.
+type TransactionsPage struct {
+	Transactions []TransactionExt `json:"transactions"`
+	NextAfter    int64            `json:"next_after,omitempty"` //nolint:tagliatelle
+}
+
+// PendingOrder is an order claimed for polling the accrual system,
+// Attempts is a count of the previous polls which have not finished it.
+type PendingOrder struct {
+	OrderExt
+	Attempts int
+}
+
 type OrderExt struct {
 	Number     string    `json:"number"`
 	Status     string    `json:"status"`
@@ -55,14 +157,21 @@ func (orInternal *OrderAccrual) GetOrderExt(username string, time time.Time) *Or
 		return nil
 	}
 
-	return NewOrderExt(orInternal.Order, orInternal.Status, orInternal.Accrual, time, username)
+	status := orInternal.Status
+	if status == OrderStatusRegistered {
+		status = OrderStatusProcessing
+	}
+
+	return NewOrderExt(orInternal.Order, status, orInternal.Accrual, time, username)
 }
 
 func NewWithdrawExt(number string, sum float32, processedAt time.Time, username string) *WithdrawExt {
 	return &WithdrawExt{
+		ID:          0,
 		Order:       number,
 		Sum:         sum,
 		ProcessedAt: processedAt,
+		Status:      WithdrawStatusPending,
 		Username:    username,
 	}
 }
@@ -74,3 +183,16 @@ func (wInternal *WithdrawAccrual) GetWithdrawExt(username string, time time.Time
 
 	return NewWithdrawExt(wInternal.Order, wInternal.Sum, time, username)
 }
+
+func (tInternal *TransferRequest) GetTransferExt(username string, time time.Time) *TransferExt {
+	if tInternal == nil {
+		return nil
+	}
+
+	return &TransferExt{
+		Recipient:   tInternal.Recipient,
+		Amount:      tInternal.Amount,
+		ProcessedAt: time,
+		Username:    username,
+	}
+}
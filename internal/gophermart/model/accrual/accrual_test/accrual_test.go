@@ -43,6 +43,10 @@ func TestGetOrderExt(t *testing.T) {
 	assert.NotNil(t, got)
 	assert.Equal(t, want, got)
 
+	orderAcc.Status = accrual2.OrderStatusRegistered
+	want.Status = accrual2.OrderStatusProcessing
+	assert.Equal(t, want, orderAcc.GetOrderExt(username, uploadedAt))
+
 	orderAcc = nil
 	got = orderAcc.GetOrderExt(username, uploadedAt)
 	assert.Nil(t, got)
@@ -54,7 +58,7 @@ func TestNewWithdrawExt(t *testing.T) {
 	var sum float32 = 94.3
 	username := userAccrualTesting
 	want := &accrual2.WithdrawExt{
-		Username: username, Order: number, Sum: sum, ProcessedAt: now,
+		Username: username, Order: number, Sum: sum, ProcessedAt: now, Status: accrual2.WithdrawStatusPending,
 	}
 
 	got := accrual2.NewWithdrawExt(number, sum, now, username)
@@ -68,7 +72,7 @@ func TestGetWithdrawExt(t *testing.T) {
 	var sum float32 = 94.3
 	username := userAccrualTesting
 	want := &accrual2.WithdrawExt{
-		Username: username, Order: number, Sum: sum, ProcessedAt: now,
+		Username: username, Order: number, Sum: sum, ProcessedAt: now, Status: accrual2.WithdrawStatusPending,
 	}
 
 	withdrawAcc := &accrual2.WithdrawAccrual{Order: number, Sum: sum}
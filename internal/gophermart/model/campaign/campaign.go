@@ -0,0 +1,49 @@
+package campaign
+
+import "time"
+
+// Kinds of the campaigns: a welcome bonus is credited on registration, an order bonus multiplies
+// the accrual of the orders uploaded while the campaign runs and a promo bonus is credited by its code.
+const (
+	KindWelcome = "WELCOME"
+	KindOrder   = "ORDER"
+	KindPromo   = "PROMO"
+)
+
+// Campaign is a bonus campaign which runs from StartsAt until EndsAt, it runs forever without EndsAt.
+// Amount is the bonus of the welcome and promo campaigns, Multiplier is the one of the order campaigns.
+type Campaign struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Kind       string     `json:"kind"`
+	Code       string     `json:"code,omitempty"`
+	Amount     float32    `json:"amount,omitempty"`
+	Multiplier float32    `json:"multiplier,omitempty"`
+	StartsAt   time.Time  `json:"starts_at"`         //nolint:tagliatelle
+	EndsAt     *time.Time `json:"ends_at,omitempty"` //nolint:tagliatelle
+	CreatedAt  time.Time  `json:"created_at"`        //nolint:tagliatelle
+}
+
+// IncomeCampaign is a request to define a campaign, it starts at once without StartsAt.
+type IncomeCampaign struct {
+	Name       string     `json:"name"`
+	Kind       string     `json:"kind"`
+	Code       string     `json:"code"`
+	Amount     float32    `json:"amount"`
+	Multiplier float32    `json:"multiplier"`
+	StartsAt   *time.Time `json:"starts_at"` //nolint:tagliatelle
+	EndsAt     *time.Time `json:"ends_at"`   //nolint:tagliatelle
+}
+
+// PromoRequest is a request to redeem a promo code.
+type PromoRequest struct {
+	Code string `json:"code"`
+}
+
+// Redemption is a promo code redeemed by a user.
+type Redemption struct {
+	Code       string    `json:"code"`
+	Campaign   string    `json:"campaign"`
+	Amount     float32   `json:"amount"`
+	RedeemedAt time.Time `json:"redeemed_at"` //nolint:tagliatelle
+}
@@ -9,6 +9,8 @@ import (
 type IncomeCredentials struct {
 	Login    string `json:"login"`
 	Password string `json:"password"`
+	// ReferralCode is an optional code of the referrer on registration.
+	ReferralCode string `json:"referral_code,omitempty"` //nolint:tagliatelle
 }
 
 type Credentials struct {
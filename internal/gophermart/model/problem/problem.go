@@ -0,0 +1,119 @@
+package problem
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// MIMEProblemJSON is the media type of RFC 7807 responses.
+const MIMEProblemJSON = "application/problem+json"
+
+// Machine-readable codes of the API errors.
+const (
+	CodeBadRequest             = "bad_request"
+	CodeUnauthorized           = "unauthorized"
+	CodeInvalidCredentials     = "invalid_credentials"
+	CodeLoginTaken             = "login_taken"
+	CodeInvalidOrderNumber     = "invalid_order_number"
+	CodeOrderUploadedByAnother = "order_uploaded_by_another"
+	CodeInvalidWithdraw        = "invalid_withdraw"
+	CodeInsufficientFunds      = "insufficient_funds"
+	CodeNotCancellable         = "withdrawal_not_cancellable"
+	CodeNotRefundable          = "withdrawal_not_refundable"
+	CodeInvalidRefund          = "invalid_refund"
+	CodeInvalidAdjustment      = "invalid_adjustment"
+	CodeInvalidTransfer        = "invalid_transfer"
+	CodeTransferLimit          = "transfer_limit_exceeded"
+	CodeInvalidCampaign        = "invalid_campaign"
+	CodeCampaignExists         = "campaign_exists"
+	CodeInvalidPromo           = "invalid_promo"
+	CodePromoRedeemed          = "promo_already_redeemed"
+	CodeInvalidReferral        = "invalid_referral_code"
+	CodeInvalidWebhook         = "invalid_webhook"
+	CodeWebhookExists          = "webhook_exists"
+	CodeTooManyWebhooks        = "too_many_webhooks"
+	CodeTooManyRequests        = "too_many_requests"
+	CodeRequestTooLarge        = "request_too_large"
+	CodeUnsupportedEncoding    = "unsupported_encoding"
+	CodeNotFound               = "not_found"
+	CodeMethodNotAllowed       = "method_not_allowed"
+	CodeInternal               = "internal_error"
+)
+
+const typePrefix = "urn:gophermart:problem:"
+
+// Details represents a 'problem details' object of RFC 7807.
+type Details struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"` //nolint:tagliatelle
+}
+
+// Error is an API error which is rendered as Details.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Err     error
+}
+
+// New creates an instance of Error.
+func New(status int, code string, message string, err error) *Error {
+	return &Error{Status: status, Code: code, Message: message, Err: err}
+}
+
+// Internal creates an Error which hides the reason from a client.
+func Internal(err error) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, "internal server error", err)
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+	}
+
+	return fmt.Sprintf("%d %s: %s: %s", e.Status, e.Code, e.Message, e.Err.Error())
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Details returns Details of the Error.
+func (e *Error) Details(instance string, requestID string) Details {
+	return Details{
+		Type:      typePrefix + e.Code,
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Detail:    e.Message,
+		Instance:  instance,
+		Code:      e.Code,
+		RequestID: requestID,
+	}
+}
+
+// CodeByStatus returns a generic code for the HTTP status.
+func CodeByStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusTooManyRequests:
+		return CodeTooManyRequests
+	case http.StatusRequestEntityTooLarge:
+		return CodeRequestTooLarge
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusInternalServerError:
+		return CodeInternal
+	default:
+		return fmt.Sprintf("http_%d", status)
+	}
+}
@@ -0,0 +1,50 @@
+package problem_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/problem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	err := problem.New(http.StatusConflict, problem.CodeLoginTaken, "taken", io.EOF)
+	assert.Equal(t, "409 login_taken: taken: EOF", err.Error())
+	assert.ErrorIs(t, err, io.EOF)
+
+	err = problem.New(http.StatusConflict, problem.CodeLoginTaken, "taken", nil)
+	assert.Equal(t, "409 login_taken: taken", err.Error())
+}
+
+func TestInternal(t *testing.T) {
+	err := problem.Internal(io.EOF)
+	want := problem.Details{
+		Type:      "urn:gophermart:problem:internal_error",
+		Title:     "Internal Server Error",
+		Status:    http.StatusInternalServerError,
+		Detail:    "internal server error",
+		Instance:  "/api",
+		Code:      problem.CodeInternal,
+		RequestID: "id",
+	}
+	assert.Equal(t, want, err.Details("/api", "id"))
+}
+
+func TestCodeByStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{status: http.StatusBadRequest, want: problem.CodeBadRequest},
+		{status: http.StatusUnauthorized, want: problem.CodeUnauthorized},
+		{status: http.StatusNotFound, want: problem.CodeNotFound},
+		{status: http.StatusMethodNotAllowed, want: problem.CodeMethodNotAllowed},
+		{status: http.StatusInternalServerError, want: problem.CodeInternal},
+		{status: http.StatusTeapot, want: "http_418"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, problem.CodeByStatus(test.status))
+	}
+}
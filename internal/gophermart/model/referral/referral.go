@@ -0,0 +1,28 @@
+package referral
+
+import "time"
+
+// Statuses of a referral: it is pending until the first order of the referred user is processed,
+// then the referrer is rewarded unless the referrer has reached the limit of the rewards.
+const (
+	StatusPending  = "PENDING"
+	StatusRewarded = "REWARDED"
+	StatusCapped   = "CAPPED"
+)
+
+// Referral is a user registered by the referral code of another user.
+type Referral struct {
+	Login        string     `json:"login"`
+	Status       string     `json:"status"`
+	RegisteredAt time.Time  `json:"registered_at"`          //nolint:tagliatelle
+	ProcessedAt  *time.Time `json:"processed_at,omitempty"` //nolint:tagliatelle
+}
+
+// Referrals is the referral code of a user and the users registered by it from the latest one.
+type Referrals struct {
+	Code       string     `json:"code"`
+	Bonus      float32    `json:"bonus"`
+	MaxRewards int        `json:"max_rewards,omitempty"` //nolint:tagliatelle
+	Rewarded   int        `json:"rewarded"`
+	Referrals  []Referral `json:"referrals"`
+}
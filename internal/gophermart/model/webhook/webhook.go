@@ -0,0 +1,41 @@
+package webhook
+
+import "time"
+
+// Statuses of a delivery.
+const (
+	DeliveryStatusPending   = "PENDING"
+	DeliveryStatusDelivered = "DELIVERED"
+	DeliveryStatusDead      = "DEAD"
+)
+
+// Webhook is a URL of the user which receives the events.
+type Webhook struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"` //nolint:tagliatelle
+	Username  string    `json:"-"`
+}
+
+// IncomeWebhook is a request to register a webhook.
+type IncomeWebhook struct {
+	URL string `json:"url"`
+}
+
+// Delivery is an event sent or to be sent to a webhook.
+type Delivery struct {
+	ID             int64      `json:"id"`
+	WebhookID      int64      `json:"webhook_id"` //nolint:tagliatelle
+	Event          string     `json:"event"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	LastStatusCode int        `json:"last_status_code,omitempty"` //nolint:tagliatelle
+	LastError      string     `json:"last_error,omitempty"`       //nolint:tagliatelle
+	CreatedAt      time.Time  `json:"created_at"`                 //nolint:tagliatelle
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`  //nolint:tagliatelle
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`     //nolint:tagliatelle
+	Payload        string     `json:"-"`
+	URL            string     `json:"-"`
+	Secret         string     `json:"-"`
+}
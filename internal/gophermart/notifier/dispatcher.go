@@ -0,0 +1,157 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/events"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/metrics"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/webhook"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.uber.org/zap"
+)
+
+const (
+	// BatchSize is a count of deliveries claimed at once.
+	BatchSize = 32
+
+	mimeApplicationJSON = "application/json"
+	maxErrorLength      = 512
+	drainLimit          = 64 << 10
+)
+
+var errUnexpectedStatus = errors.New("unexpected status")
+
+// Options are tunables of Dispatcher.
+type Options struct {
+	// Timeout limits an attempt, the claimed deliveries are leased for the batch of attempts.
+	Timeout      time.Duration
+	PollInterval time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	// AllowPrivate allows deliveries to loopback and private networks.
+	AllowPrivate bool
+}
+
+// Dispatcher sends the due deliveries to the webhooks.
+type Dispatcher struct {
+	conn   *sqldb.PgxIface
+	opts   Options
+	client *http.Client
+}
+
+// NewDispatcher creates an instance of Dispatcher.
+func NewDispatcher(conn *sqldb.PgxIface, opts Options) *Dispatcher {
+	client := &http.Client{ //nolint:exhaustruct
+		Transport: otelhttp.NewTransport(newTransport(opts.AllowPrivate)),
+		Timeout:   opts.Timeout,
+		// A redirect is a failed attempt: the receiver has to register the final URL.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return &Dispatcher{conn: conn, opts: opts, client: client}
+}
+
+// Run delivers the due deliveries each PollInterval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.DeliverDue(ctx); err != nil {
+				zap.S().Warnf("notifier: %s", err.Error())
+			}
+		}
+	}
+}
+
+// DeliverDue sends a batch of the due deliveries and returns count of the sent ones.
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	lease := time.Duration(BatchSize+1) * d.opts.Timeout
+	deliveries, err := sqldb.ClaimWebhookDeliveries(ctx, d.conn, BatchSize, lease)
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+	for _, delivery := range deliveries {
+		if err = d.deliver(ctx, delivery); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+// HandleRecord enqueues the deliveries of the event from the outbox.
+func (d *Dispatcher) HandleRecord(ctx context.Context, record events.Record) error {
+	return Enqueue(ctx, d.conn, record)
+}
+
+// deliver makes an attempt and records its result.
+func (d *Dispatcher) deliver(ctx context.Context, delivery webhook.Delivery) error {
+	statusCode, sendErr := d.send(ctx, delivery)
+	if sendErr == nil {
+		metrics.ObserveWebhookDelivery(metrics.WebhookOutcomeDelivered)
+
+		return sqldb.MarkWebhookDelivered(ctx, d.conn, delivery.ID, statusCode) //nolint:wrapcheck
+	}
+
+	lastError := sendErr.Error()
+	if len(lastError) > maxErrorLength {
+		lastError = lastError[:maxErrorLength]
+	}
+	attempt := delivery.Attempts + 1
+	if attempt >= d.opts.MaxAttempts {
+		metrics.ObserveWebhookDelivery(metrics.WebhookOutcomeDead)
+		zap.S().Warnf("notifier: delivery %d is dead after %d attempts: %s", delivery.ID, attempt, lastError)
+
+		//nolint:wrapcheck
+		return sqldb.DeadLetterWebhookDelivery(ctx, d.conn, delivery.ID, statusCode, lastError)
+	}
+	metrics.ObserveWebhookDelivery(metrics.WebhookOutcomeRetried)
+	nextAttemptAt := time.Now().Add(Backoff(d.opts.BaseBackoff, d.opts.MaxBackoff, attempt))
+
+	//nolint:wrapcheck
+	return sqldb.RetryWebhookDelivery(ctx, d.conn, delivery.ID, statusCode, lastError, nextAttemptAt)
+}
+
+// send posts the payload, it returns the status code of the response
+// and an error unless the status is 2xx.
+func (d *Dispatcher) send(ctx context.Context, delivery webhook.Delivery) (int, error) {
+	body := []byte(delivery.Payload)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	timestamp := time.Now().Unix()
+	request.Header.Set("Content-Type", mimeApplicationJSON)
+	request.Header.Set("User-Agent", "gophermart-webhook")
+	request.Header.Set(HeaderEvent, delivery.Event)
+	request.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	request.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, body))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send: %w", err)
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, drainLimit))
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return response.StatusCode, fmt.Errorf("%w: %d", errUnexpectedStatus, response.StatusCode)
+	}
+
+	return response.StatusCode, nil
+}
@@ -0,0 +1,156 @@
+package notifier_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/events"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/notifier"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSecret  = "secret"
+	testPayload = `{"id":42,"event":"order.updated","created_at":"2023-11-14T22:13:20Z",` +
+		`"data":{"number":"79927398713","status":"PROCESSED","accrual":500}}`
+)
+
+func testOptions(allowPrivate bool) notifier.Options {
+	return notifier.Options{
+		Timeout:      time.Second,
+		PollInterval: time.Second,
+		MaxAttempts:  3,
+		BaseBackoff:  time.Minute,
+		MaxBackoff:   time.Hour,
+		AllowPrivate: allowPrivate,
+	}
+}
+
+// newReceiver returns a receiver which checks the signature and answers with the status.
+func newReceiver(t *testing.T, status int, calls *int32) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(calls, 1)
+		body, err := io.ReadAll(request.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, testPayload, string(body))
+		assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+		assert.Equal(t, notifier.EventOrderUpdated, request.Header.Get(notifier.HeaderEvent))
+		assert.Equal(t, "7", request.Header.Get(notifier.HeaderDelivery))
+		timestamp, err := strconv.ParseInt(request.Header.Get(notifier.HeaderTimestamp), 10, 64)
+		assert.NoError(t, err)
+		assert.True(t, notifier.Verify(testSecret, timestamp, body, request.Header.Get(notifier.HeaderSignature)))
+		writer.WriteHeader(status)
+	}))
+}
+
+func expectClaim(mock pgxmock.PgxConnIface, url string, attempts int) {
+	mock.ExpectQuery("WITH due AS").
+		WithArgs(notifier.BatchSize, pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{
+			"id", "webhook_id", "event", "payload", "attempts", "created_at", "url", "secret",
+		}).AddRow(int64(7), int64(3), notifier.EventOrderUpdated, testPayload, attempts, time.Now(), url, testSecret))
+}
+
+func TestDispatcherDeliverDue(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		attempts int
+		expect   func(mock pgxmock.PgxConnIface)
+	}{
+		{
+			name: "delivered", status: http.StatusNoContent, attempts: 0,
+			expect: func(mock pgxmock.PgxConnIface) {
+				mock.ExpectExec("UPDATE webhook_deliveries SET status = 'DELIVERED'").
+					WithArgs(int64(7), http.StatusNoContent).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			},
+		},
+		{
+			name: "retried", status: http.StatusInternalServerError, attempts: 1,
+			expect: func(mock pgxmock.PgxConnIface) {
+				mock.ExpectExec("UPDATE webhook_deliveries SET attempts = attempts \\+ 1").
+					WithArgs(int64(7), http.StatusInternalServerError, "unexpected status: 500", pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			},
+		},
+		{
+			name: "dead", status: http.StatusGone, attempts: 2,
+			expect: func(mock pgxmock.PgxConnIface) {
+				mock.ExpectExec("INSERT INTO webhook_dead_letters").
+					WithArgs(int64(7), http.StatusGone, "unexpected status: 410").
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			},
+		},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			var calls int32
+			receiver := newReceiver(t, test.status, &calls)
+			defer receiver.Close()
+
+			mock, err := pgxmock.NewConn()
+			require.NoError(t, err)
+			var pgConn sqldb.PgxIface = mock
+			expectClaim(mock, receiver.URL+"/hook", test.attempts)
+			test.expect(mock)
+
+			sent, err := notifier.NewDispatcher(&pgConn, testOptions(true)).DeliverDue(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, 1, sent)
+			assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDispatcherRefusesPrivateAddress(t *testing.T) {
+	var calls int32
+	receiver := newReceiver(t, http.StatusOK, &calls)
+	defer receiver.Close()
+
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn sqldb.PgxIface = mock
+	expectClaim(mock, receiver.URL, 0)
+	mock.ExpectExec("UPDATE webhook_deliveries SET attempts = attempts \\+ 1").
+		WithArgs(int64(7), 0, pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	_, err = notifier.NewDispatcher(&pgConn, testOptions(false)).DeliverDue(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, atomic.LoadInt32(&calls))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDispatcherHandleRecord(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn sqldb.PgxIface = mock
+	mock.ExpectExec("INSERT INTO webhook_deliveries").
+		WithArgs("user1", notifier.EventOrderUpdated, testPayload).
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+
+	record := events.Record{
+		ID:        42,
+		Type:      events.TypeOrderUpdated,
+		Username:  "user1",
+		Payload:   []byte(`{"number":"79927398713","status":"PROCESSED","accrual":500}`),
+		CreatedAt: time.Unix(1700000000, 0),
+	}
+	err = notifier.NewDispatcher(&pgConn, testOptions(true)).HandleRecord(context.Background(), record)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,108 @@
+// Package notifier delivers events of the users to their webhooks.
+//
+// The events are kept in the DB as pending deliveries, a Dispatcher sends them
+// as JSON payloads signed by HMAC-SHA256 with the secret of the webhook
+// and retries the failed ones with exponential backoff until they go to the dead letters.
+package notifier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/events"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+)
+
+// Types of the events.
+const (
+	EventOrderUpdated        = events.TypeOrderUpdated
+	EventWithdrawalCreated   = events.TypeWithdrawalCreated
+	EventWithdrawalCancelled = events.TypeWithdrawalCancelled
+	EventWithdrawalRefunded  = events.TypeWithdrawalRefunded
+)
+
+// Headers of the deliveries.
+const (
+	HeaderEvent     = "X-Gophermart-Event"
+	HeaderDelivery  = "X-Gophermart-Delivery"
+	HeaderTimestamp = "X-Gophermart-Timestamp"
+	HeaderSignature = "X-Gophermart-Signature"
+)
+
+const (
+	signaturePrefix = "sha256="
+	secretSize      = 32
+)
+
+// Payload is a body of a delivery,
+// ID is the same for all deliveries of the event, so a receiver may skip the repeated ones.
+type Payload struct {
+	ID        int64           `json:"id"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"` //nolint:tagliatelle
+	Data      json.RawMessage `json:"data"`
+}
+
+// Enqueue adds a delivery of the event to each webhook of its user.
+func Enqueue(ctx context.Context, pgConn *sqldb.PgxIface, record events.Record) error {
+	payload, err := json.Marshal(Payload{
+		ID: record.ID, Event: record.Type, CreatedAt: record.CreatedAt.UTC(), Data: record.Payload,
+	})
+	if err != nil {
+		return fmt.Errorf("notifier: failed to marshal %s payload: %w", record.Type, err)
+	}
+	if _, err = sqldb.EnqueueWebhookDeliveries(ctx, pgConn, record.Username, record.Type, string(payload)); err != nil {
+		return fmt.Errorf("notifier: failed to enqueue %s: %w", record.Type, err)
+	}
+
+	return nil
+}
+
+// NewSecret returns a random secret of a webhook.
+func NewSecret() (string, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("notifier: failed to generate secret: %w", err)
+	}
+
+	return hex.EncodeToString(secret), nil
+}
+
+// Sign returns a value of HeaderSignature: HMAC-SHA256 of "<timestamp>.<body>",
+// the timestamp is the value of HeaderTimestamp, so a receiver may reject replayed deliveries.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the signature matches the body and the timestamp.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Backoff returns a delay before the next attempt after the failed attempt number attempt,
+// it is base doubled for each previous attempt up to maxBackoff.
+func Backoff(base time.Duration, maxBackoff time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt; i++ {
+		if delay >= maxBackoff/2 {
+			return maxBackoff
+		}
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		return maxBackoff
+	}
+
+	return delay
+}
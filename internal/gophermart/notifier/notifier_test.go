@@ -0,0 +1,47 @@
+package notifier_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/notifier"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"order.updated"}`)
+	signature := notifier.Sign("secret", 1700000000, body)
+	assert.Equal(t, "sha256=fa987fa63bc7f0cca7b164d6f3935914e039ca48a32337b44dac6c2cf4639463", signature)
+
+	assert.True(t, notifier.Verify("secret", 1700000000, body, signature))
+	assert.False(t, notifier.Verify("other", 1700000000, body, signature))
+	assert.False(t, notifier.Verify("secret", 1700000001, body, signature))
+	assert.False(t, notifier.Verify("secret", 1700000000, []byte(`{}`), signature))
+}
+
+func TestNewSecret(t *testing.T) {
+	first, err := notifier.NewSecret()
+	require.NoError(t, err)
+	second, err := notifier.NewSecret()
+	require.NoError(t, err)
+	assert.Len(t, first, 64)
+	assert.NotEqual(t, first, second)
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: 10 * time.Second},
+		{attempt: 2, want: 20 * time.Second},
+		{attempt: 4, want: 80 * time.Second},
+		{attempt: 9, want: 42*time.Minute + 40*time.Second},
+		{attempt: 10, want: time.Hour},
+		{attempt: 100, want: time.Hour},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, notifier.Backoff(10*time.Second, time.Hour, test.attempt), test.attempt)
+	}
+}
@@ -0,0 +1,90 @@
+// Package openapi keeps the OpenAPI 3 document of the API.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/labstack/echo/v4"
+)
+
+// Path is the route which serves the document.
+const Path = "/api/openapi.json"
+
+//go:embed openapi.json
+var document []byte
+
+// Document returns the raw JSON document.
+func Document() []byte {
+	return document
+}
+
+// Load parses and validates the document.
+func Load() (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load openapi document: %w", err)
+	}
+	if err = doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("openapi document is invalid: %w", err)
+	}
+
+	return doc, nil
+}
+
+// MustLoad is like Load but panics if the document is broken,
+// the document is embedded, so it is checked by the tests.
+func MustLoad() *openapi3.T {
+	doc, err := Load()
+	if err != nil {
+		panic(err)
+	}
+
+	return doc
+}
+
+// Handler serves the document.
+func Handler(ctx echo.Context) error {
+	return ctx.Blob(http.StatusOK, echo.MIMEApplicationJSON, document) //nolint:wrapcheck
+}
+
+// FindRoute returns the operation of the echo route,
+// the route has echo's ':param' placeholders.
+// It returns nil if the document does not describe the route.
+func FindRoute(doc *openapi3.T, method string, echoPath string) *routers.Route {
+	path := ToOpenAPIPath(echoPath)
+	pathItem := doc.Paths.Find(path)
+	if pathItem == nil {
+		return nil
+	}
+	operation := pathItem.GetOperation(method)
+	if operation == nil {
+		return nil
+	}
+
+	return &routers.Route{
+		Spec:      doc,
+		Server:    nil,
+		Path:      path,
+		PathItem:  pathItem,
+		Method:    method,
+		Operation: operation,
+	}
+}
+
+// ToOpenAPIPath converts echo's ':param' placeholders to '{param}'.
+func ToOpenAPIPath(echoPath string) string {
+	segments := strings.Split(echoPath, "/")
+	for idx, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[idx] = "{" + segment[1:] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,51 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/openapi"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	doc, err := openapi.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	assert.NotPanics(t, func() { openapi.MustLoad() })
+}
+
+func TestFindRoute(t *testing.T) {
+	doc := openapi.MustLoad()
+
+	route := openapi.FindRoute(doc, http.MethodPost, "/api/user/balance/withdraw")
+	require.NotNil(t, route)
+	assert.Equal(t, "withdraw", route.Operation.OperationID)
+
+	assert.Nil(t, openapi.FindRoute(doc, http.MethodDelete, "/api/user/orders"))
+	assert.Nil(t, openapi.FindRoute(doc, http.MethodGet, "/healthz"))
+}
+
+func TestToOpenAPIPath(t *testing.T) {
+	assert.Equal(t, "/api/user/orders", openapi.ToOpenAPIPath("/api/user/orders"))
+	assert.Equal(t, "/api/orders/{number}/items/{id}", openapi.ToOpenAPIPath("/api/orders/:number/items/:id"))
+}
+
+func TestHandler(t *testing.T) {
+	echoFramework := echo.New()
+	echoFramework.GET(openapi.Path, openapi.Handler)
+
+	rec := httptest.NewRecorder()
+	echoFramework.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, openapi.Path, nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON)
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, "3.0.3", got["openapi"])
+	assert.Equal(t, openapi.Document(), rec.Body.Bytes())
+}
@@ -0,0 +1,113 @@
+// Package outbox dispatches the domain events written to the outbox table
+// in the transactions of the changes, so an event is never lost when the process dies
+// between the change and its publishing.
+//
+// The delivery is at-least-once: a record is marked as delivered after all its handlers succeed,
+// so a handler may receive a record again, e.g. after a failure of another handler or a crash.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/events"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/metrics"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+	"go.uber.org/zap"
+)
+
+// BatchSize is a count of records dispatched in a transaction.
+const BatchSize = 100
+
+const sweepInterval = time.Hour
+
+// Handler receives the records of the type it is registered for.
+type Handler func(ctx context.Context, record events.Record) error
+
+// Dispatcher passes the pending records of the outbox to the handlers.
+type Dispatcher struct {
+	conn      *sqldb.PgxIface
+	interval  time.Duration
+	retention time.Duration
+
+	mu        sync.RWMutex
+	handlers  map[string][]Handler
+	lastSweep time.Time
+}
+
+// NewDispatcher creates an instance of Dispatcher, it polls the outbox each interval
+// and keeps the delivered records for retention.
+func NewDispatcher(conn *sqldb.PgxIface, interval time.Duration, retention time.Duration) *Dispatcher {
+	return &Dispatcher{
+		conn: conn, interval: interval, retention: retention,
+		mu: sync.RWMutex{}, handlers: make(map[string][]Handler), lastSweep: time.Time{},
+	}
+}
+
+// Handle registers the handler of the records of the type.
+func (d *Dispatcher) Handle(eventType string, handler Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers[eventType] = append(d.handlers[eventType], handler)
+}
+
+// Run dispatches the pending records each interval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.DispatchPending(ctx); err != nil {
+				zap.S().Warnf("outbox: %s", err.Error())
+			}
+			d.sweep(ctx)
+		}
+	}
+}
+
+// DispatchPending dispatches the pending records until there are none or a handler fails.
+func (d *Dispatcher) DispatchPending(ctx context.Context) error {
+	for {
+		delivered, err := sqldb.DispatchOutbox(ctx, d.conn, BatchSize, func(record events.Record) error {
+			return d.dispatch(ctx, record)
+		})
+		metrics.ObserveOutboxDispatched(delivered)
+		if err != nil {
+			metrics.ObserveOutboxFailed()
+
+			return fmt.Errorf("%w", err)
+		}
+		if delivered < BatchSize {
+			return nil
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, record events.Record) error {
+	d.mu.RLock()
+	handlers := d.handlers[record.Type]
+	d.mu.RUnlock()
+	for _, handler := range handlers {
+		if err := handler(ctx, record); err != nil {
+			return fmt.Errorf("failed to dispatch %s record %d: %w", record.Type, record.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// sweep deletes the records delivered before retention once per sweepInterval.
+func (d *Dispatcher) sweep(ctx context.Context) {
+	if time.Since(d.lastSweep) < sweepInterval {
+		return
+	}
+	d.lastSweep = time.Now()
+	if _, err := sqldb.DeleteDeliveredOutbox(ctx, d.conn, time.Now().Add(-d.retention)); err != nil {
+		zap.S().Warnf("outbox: %s", err.Error())
+	}
+}
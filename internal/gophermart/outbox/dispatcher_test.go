@@ -0,0 +1,101 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/events"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/outbox"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func expectRecords(mock pgxmock.PgxConnIface, ids ...int64) {
+	rows := pgxmock.NewRows([]string{"id", "event", "username", "payload", "created_at"})
+	for _, id := range ids {
+		eventType := events.TypeOrderUpdated
+		if id%2 == 0 {
+			eventType = events.TypeWithdrawalCreated
+		}
+		rows.AddRow(id, eventType, "user1", "{}", time.Now())
+	}
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox").WithArgs(outbox.BatchSize).WillReturnRows(rows)
+}
+
+func TestDispatchPending(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn sqldb.PgxIface = mock
+
+	expectRecords(mock, 1, 2, 3)
+	mock.ExpectExec("UPDATE outbox SET delivered_at").
+		WithArgs([]int64{1, 2, 3}).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 3))
+	mock.ExpectCommit()
+
+	var orders, withdrawals, other []int64
+	dispatcher := outbox.NewDispatcher(&pgConn, time.Second, time.Hour)
+	dispatcher.Handle(events.TypeOrderUpdated, func(_ context.Context, record events.Record) error {
+		orders = append(orders, record.ID)
+
+		return nil
+	})
+	dispatcher.Handle(events.TypeOrderUpdated, func(_ context.Context, record events.Record) error {
+		other = append(other, record.ID)
+
+		return nil
+	})
+	dispatcher.Handle(events.TypeWithdrawalCreated, func(_ context.Context, record events.Record) error {
+		withdrawals = append(withdrawals, record.ID)
+
+		return nil
+	})
+
+	require.NoError(t, dispatcher.DispatchPending(context.Background()))
+	assert.Equal(t, []int64{1, 3}, orders)
+	assert.Equal(t, []int64{1, 3}, other)
+	assert.Equal(t, []int64{2}, withdrawals)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDispatchPendingRedeliversFailed(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn sqldb.PgxIface = mock
+
+	expectRecords(mock, 1, 2)
+	mock.ExpectExec("UPDATE outbox SET delivered_at").
+		WithArgs([]int64{1}).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectCommit()
+	expectRecords(mock, 2)
+	mock.ExpectExec("UPDATE outbox SET delivered_at").
+		WithArgs([]int64{2}).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectCommit()
+
+	errUnavailable := errors.New("unavailable")
+	failures := 1
+	received := make([]int64, 0)
+	dispatcher := outbox.NewDispatcher(&pgConn, time.Second, time.Hour)
+	dispatcher.Handle(events.TypeWithdrawalCreated, func(_ context.Context, record events.Record) error {
+		if failures > 0 {
+			failures--
+
+			return errUnavailable
+		}
+		received = append(received, record.ID)
+
+		return nil
+	})
+
+	assert.ErrorIs(t, dispatcher.DispatchPending(context.Background()), errUnavailable)
+	require.NoError(t, dispatcher.DispatchPending(context.Background()))
+	assert.Equal(t, []int64{2}, received)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
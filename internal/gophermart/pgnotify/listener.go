@@ -0,0 +1,133 @@
+package pgnotify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/events"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/metrics"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/notifier"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+// Conn is a dedicated connection of a Listener, *pgx.Conn implements it.
+type Conn interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	WaitForNotification(ctx context.Context) (*pgconn.Notification, error)
+	Close(ctx context.Context) error
+}
+
+// Dialer opens a dedicated connection.
+type Dialer func(ctx context.Context) (Conn, error)
+
+// Dial returns a Dialer of the connection string.
+func Dial(connString string) Dialer {
+	return func(ctx context.Context) (Conn, error) {
+		conn, err := pgx.Connect(ctx, connString)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect: %w", err)
+		}
+
+		return conn, nil
+	}
+}
+
+// Handler receives the records of the type it is registered for.
+type Handler func(ctx context.Context, record events.Record) error
+
+// Listener receives the notifications of Channel and passes them to the handlers.
+type Listener struct {
+	dial       Dialer
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+// NewListener creates an instance of Listener, it reconnects after backoff
+// doubled for each next failed attempt up to maxBackoff.
+func NewListener(dial Dialer, backoff time.Duration, maxBackoff time.Duration) *Listener {
+	return &Listener{
+		dial: dial, backoff: backoff, maxBackoff: maxBackoff,
+		mu: sync.RWMutex{}, handlers: make(map[string][]Handler),
+	}
+}
+
+// Handle registers the handler of the records of the type.
+func (l *Listener) Handle(eventType string, handler Handler) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handlers[eventType] = append(l.handlers[eventType], handler)
+}
+
+// Run listens until ctx is done, reconnecting when the connection fails.
+func (l *Listener) Run(ctx context.Context) {
+	attempt := 0
+	for {
+		err := l.listen(ctx, func() { attempt = 0 })
+		if ctx.Err() != nil {
+			return
+		}
+		attempt++
+		delay := notifier.Backoff(l.backoff, l.maxBackoff, attempt)
+		metrics.ObserveListenReconnect()
+		zap.S().Warnf("pgnotify: %s, reconnecting in %s", err.Error(), delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// listen receives the notifications until the connection fails, connected is called after LISTEN.
+func (l *Listener) listen(ctx context.Context, connected func()) error {
+	conn, err := l.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// ctx may be done already.
+		if closeErr := conn.Close(context.Background()); closeErr != nil {
+			zap.S().Warnf("pgnotify: failed to close connection: %s", closeErr.Error())
+		}
+	}()
+	if _, err = conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	connected()
+	zap.S().Infof("pgnotify: listening to %s", Channel)
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to wait for notification: %w", err)
+		}
+		l.receive(ctx, notification.Payload)
+	}
+}
+
+func (l *Listener) receive(ctx context.Context, payload string) {
+	record, err := decode(payload)
+	if err != nil {
+		zap.S().Warnf("%s", err.Error())
+
+		return
+	}
+	l.mu.RLock()
+	handlers := l.handlers[record.Type]
+	l.mu.RUnlock()
+	for _, handler := range handlers {
+		if err = handler(ctx, record); err != nil {
+			zap.S().Warnf("pgnotify: failed to handle %s record %d: %s", record.Type, record.ID, err.Error())
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Package pgnotify shares the domain events between the instances using the DB.
+//
+// The outbox dispatcher of one instance publishes each event by NOTIFY,
+// a Listener of every instance receives it by LISTEN on a dedicated connection
+// and passes it to the handlers of the process, e.g. to the event bus of the order streams.
+// The notifications sent while a Listener reconnects are not received by it.
+package pgnotify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/events"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+	"go.uber.org/zap"
+)
+
+// Channel is the name of the channel of the notifications.
+const Channel = "gophermart_events"
+
+// maxPayload is a limit of a payload of NOTIFY.
+const maxPayload = 8000
+
+// message is a payload of a notification.
+type message struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	Username  string          `json:"username"`
+	CreatedAt time.Time       `json:"created_at"` //nolint:tagliatelle
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// Publish notifies the listeners of all instances about the record,
+// a record too large for a notification is skipped.
+func Publish(ctx context.Context, pgConn *sqldb.PgxIface, record events.Record) error {
+	payload, err := encode(record)
+	if err != nil {
+		return err
+	}
+	if len(payload) >= maxPayload {
+		zap.S().Warnf("pgnotify: %s record %d is too large to notify: %d bytes", record.Type, record.ID, len(payload))
+
+		return nil
+	}
+	if err = sqldb.Notify(ctx, pgConn, Channel, payload); err != nil {
+		return fmt.Errorf("pgnotify: %w", err)
+	}
+
+	return nil
+}
+
+func encode(record events.Record) (string, error) {
+	payload, err := json.Marshal(message{
+		ID: record.ID, Type: record.Type, Username: record.Username,
+		CreatedAt: record.CreatedAt.UTC(), Payload: record.Payload,
+	})
+	if err != nil {
+		return "", fmt.Errorf("pgnotify: failed to marshal %s record: %w", record.Type, err)
+	}
+
+	return string(payload), nil
+}
+
+func decode(payload string) (events.Record, error) {
+	var msg message
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		return events.Record{}, fmt.Errorf("pgnotify: failed to unmarshal notification: %w", err)
+	}
+
+	return events.Record{
+		ID: msg.ID, Type: msg.Type, Username: msg.Username, Payload: msg.Payload, CreatedAt: msg.CreatedAt,
+	}, nil
+}
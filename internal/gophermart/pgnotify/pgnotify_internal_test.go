@@ -0,0 +1,136 @@
+package pgnotify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/events"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTestConnLost = errors.New("conn lost")
+
+func testRecord() events.Record {
+	return events.Record{
+		ID:        42,
+		Type:      events.TypeOrderUpdated,
+		Username:  "user1",
+		Payload:   []byte(`{"number":"79927398713","status":"PROCESSED","accrual":500}`),
+		CreatedAt: time.Unix(1700000000, 0).UTC(),
+	}
+}
+
+func TestPublish(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn sqldb.PgxIface = mock
+	want := `{"id":42,"type":"order.updated","username":"user1","created_at":"2023-11-14T22:13:20Z",` +
+		`"payload":{"number":"79927398713","status":"PROCESSED","accrual":500}}`
+	mock.ExpectExec("SELECT pg_notify").WithArgs(Channel, want).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	require.NoError(t, Publish(context.Background(), &pgConn, testRecord()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPublishSkipsLargeRecord(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn sqldb.PgxIface = mock
+	record := testRecord()
+	record.Payload = []byte(`"` + strings.Repeat("a", maxPayload) + `"`)
+
+	require.NoError(t, Publish(context.Background(), &pgConn, record))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDecode(t *testing.T) {
+	payload, err := encode(testRecord())
+	require.NoError(t, err)
+	got, err := decode(payload)
+	require.NoError(t, err)
+	assert.Equal(t, testRecord(), got)
+
+	_, err = decode("{")
+	assert.Error(t, err)
+}
+
+// fakeConn passes the payloads as notifications and fails when they are over.
+type fakeConn struct {
+	payloads []string
+	listened []string
+	closed   bool
+}
+
+func (c *fakeConn) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	c.listened = append(c.listened, sql)
+
+	return pgconn.NewCommandTag("LISTEN"), nil
+}
+
+func (c *fakeConn) WaitForNotification(_ context.Context) (*pgconn.Notification, error) {
+	if len(c.payloads) == 0 {
+		return nil, errTestConnLost
+	}
+	payload := c.payloads[0]
+	c.payloads = c.payloads[1:]
+
+	return &pgconn.Notification{PID: 1, Channel: Channel, Payload: payload}, nil
+}
+
+func (c *fakeConn) Close(_ context.Context) error {
+	c.closed = true
+
+	return nil
+}
+
+func TestListenerReconnects(t *testing.T) {
+	payload, err := encode(testRecord())
+	require.NoError(t, err)
+	conns := []*fakeConn{
+		{payloads: []string{"{", payload}, listened: nil, closed: false},
+		{payloads: []string{payload}, listened: nil, closed: false},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dials := 0
+	dial := func(context.Context) (Conn, error) {
+		dials++
+		switch {
+		case dials == 2:
+			return nil, errTestConnLost
+		case dials > 3:
+			cancel()
+
+			return nil, context.Canceled
+		case dials == 1:
+			return conns[0], nil
+		default:
+			return conns[1], nil
+		}
+	}
+
+	var received []events.Record
+	listener := NewListener(dial, time.Millisecond, time.Millisecond)
+	listener.Handle(events.TypeOrderUpdated, func(_ context.Context, record events.Record) error {
+		received = append(received, record)
+
+		return nil
+	})
+	listener.Run(ctx)
+
+	assert.Equal(t, 4, dials)
+	assert.Equal(t, []events.Record{testRecord(), testRecord()}, received)
+	for _, conn := range conns {
+		assert.Equal(t, []string{"LISTEN " + Channel}, conn.listened)
+		assert.True(t, conn.closed)
+	}
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+// MemoryStore keeps the buckets in the memory of the instance.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore creates an instance of MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{mu: sync.Mutex{}, buckets: make(map[string]*bucket), lastSweep: time.Now(), now: time.Now}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	burst := float64(limit.Burst)
+	item, ok := s.buckets[key]
+	if !ok {
+		item = &bucket{tokens: burst, updated: now, full: now}
+		s.buckets[key] = item
+	}
+	item.tokens = math.Min(burst, item.tokens+now.Sub(item.updated).Seconds()*limit.Rate)
+	item.updated = now
+
+	allowed := item.tokens >= 1
+	if allowed {
+		item.tokens--
+	}
+	item.full = now.Add(time.Duration((burst - item.tokens) / limit.Rate * float64(time.Second)))
+
+	return newResult(allowed, item.tokens, limit), nil
+}
+
+// sweep drops the buckets which have been refilled,
+// they are the same as the new ones.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, item := range s.buckets {
+		if !now.Before(item.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
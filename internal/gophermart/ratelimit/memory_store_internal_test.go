@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	limit := Limit{Rate: 2, Burst: 3}
+	ctx := context.Background()
+
+	for want := 2; want >= 0; want-- {
+		result, err := store.Take(ctx, "user1", limit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, want, result.Remaining)
+	}
+
+	result, err := store.Take(ctx, "user1", limit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	assert.Equal(t, int64(1), result.RetryAfterSeconds())
+
+	other, err := store.Take(ctx, "user2", limit)
+	require.NoError(t, err)
+	assert.True(t, other.Allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	result, err = store.Take(ctx, "user1", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	store := NewMemoryStore()
+	now := store.lastSweep
+	store.now = func() time.Time { return now }
+	limit := Limit{Rate: 1, Burst: 10}
+	ctx := context.Background()
+
+	_, err := store.Take(ctx, "user1", limit)
+	require.NoError(t, err)
+	assert.Len(t, store.buckets, 1)
+
+	now = now.Add(sweepInterval)
+	_, err = store.Take(ctx, "user2", limit)
+	require.NoError(t, err)
+	assert.Len(t, store.buckets, 1)
+	assert.Contains(t, store.buckets, "user2")
+}
+
+func TestResultRetryAfterSeconds(t *testing.T) {
+	assert.Equal(t, int64(1), Result{Allowed: false, Remaining: 0, RetryAfter: 0}.RetryAfterSeconds())
+	result := Result{Allowed: false, Remaining: 0, RetryAfter: 2100 * time.Millisecond}
+	assert.Equal(t, int64(3), result.RetryAfterSeconds())
+}
@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+)
+
+// PostgresStore keeps the buckets in the DB, so the instances share them.
+type PostgresStore struct {
+	conn *sqldb.PgxIface
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewPostgresStore creates an instance of PostgresStore.
+func NewPostgresStore(conn *sqldb.PgxIface) *PostgresStore {
+	return &PostgresStore{conn: conn, mu: sync.Mutex{}, lastSweep: time.Now()}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.sweep(ctx)
+
+	allowed, tokens, err := sqldb.TakeRateLimitToken(ctx, s.conn, key, limit.Rate, limit.Burst)
+	if err != nil {
+		return Result{Allowed: true, Remaining: 0, RetryAfter: 0}, fmt.Errorf("%w", err)
+	}
+
+	return newResult(allowed, tokens, limit), nil
+}
+
+// sweep deletes the refilled buckets once per sweepInterval.
+func (s *PostgresStore) sweep(ctx context.Context) {
+	s.mu.Lock()
+	if time.Since(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+
+		return
+	}
+	s.lastSweep = time.Now()
+	s.mu.Unlock()
+
+	if _, err := sqldb.DeleteRefilledRateLimits(ctx, s.conn); err != nil {
+		logging.FromContext(ctx).Warnf("ratelimit: %s", err.Error())
+	}
+}
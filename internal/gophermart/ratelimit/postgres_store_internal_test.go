@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTestDB = errors.New("db is down")
+
+func TestPostgresStoreTake(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn sqldb.PgxIface = mock
+	store := NewPostgresStore(&pgConn)
+	store.lastSweep = time.Now().Add(-sweepInterval)
+	limit := Limit{Rate: 2, Burst: 3}
+
+	mock.ExpectExec("DELETE FROM rate_limits").
+		WillReturnResult(pgconn.NewCommandTag("DELETE 1"))
+	mock.ExpectQuery("INSERT INTO rate_limits").
+		WithArgs("user1", 2.0, 3).
+		WillReturnRows(pgxmock.NewRows([]string{"tokens", "allowed"}).AddRow(1.5, true))
+	mock.ExpectQuery("INSERT INTO rate_limits").
+		WithArgs("user1", 2.0, 3).
+		WillReturnRows(pgxmock.NewRows([]string{"tokens", "allowed"}).AddRow(0.5, false))
+	mock.ExpectQuery("INSERT INTO rate_limits").
+		WithArgs("user1", 2.0, 3).
+		WillReturnError(errTestDB)
+
+	result, err := store.Take(context.Background(), "user1", limit)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Allowed: true, Remaining: 1, RetryAfter: 0}, result)
+
+	result, err = store.Take(context.Background(), "user1", limit)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Allowed: false, Remaining: 0, RetryAfter: 250 * time.Millisecond}, result)
+
+	result, err = store.Take(context.Background(), "user1", limit)
+	assert.ErrorIs(t, err, errTestDB)
+	assert.True(t, result.Allowed)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit is a token bucket: Rate tokens are added per second up to Burst tokens,
+// each request takes a token.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Unlimited reports whether the limit lets all the requests through.
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0
+}
+
+// Result is a decision on a request.
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Store keeps the buckets and takes tokens from them.
+type Store interface {
+	// Take takes a token from the bucket of key.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// newResult builds Result by the tokens left in a bucket after the request.
+func newResult(allowed bool, tokens float64, limit Limit) Result {
+	result := Result{Allowed: allowed, Remaining: int(math.Max(0, math.Floor(tokens))), RetryAfter: 0}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - tokens) / limit.Rate * float64(time.Second))
+	}
+
+	return result
+}
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds for 'Retry-After' header.
+func (r Result) RetryAfterSeconds() int64 {
+	seconds := int64(math.Ceil(r.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return seconds
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/campaign"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+)
+
+// maxCampaignNameLength and maxPromoCodeLength are the lengths of the columns of the campaigns.
+const (
+	maxCampaignNameLength = 64
+	maxPromoCodeLength    = 32
+)
+
+var (
+	ErrCampaignInvalid = fmt.Errorf("failed to add campaign")
+	ErrCampaignExists  = fmt.Errorf("failed to add campaign: the name or the code is already used")
+	ErrPromoInvalid    = fmt.Errorf("failed to redeem promo code: no code")
+	ErrPromoNotFound   = fmt.Errorf("failed to redeem promo code: no running campaign of the code")
+	ErrPromoRedeemed   = fmt.Errorf("failed to redeem promo code: already redeemed")
+)
+
+// AddCampaign defines the campaign, it starts at now unless its start is set.
+func AddCampaign(
+	ctx context.Context, pgConn *sqldb.PgxIface, income campaign.IncomeCampaign, now time.Time,
+) (*campaign.Campaign, error) {
+	item := &campaign.Campaign{
+		ID:         0,
+		Name:       income.Name,
+		Kind:       income.Kind,
+		Code:       income.Code,
+		Amount:     income.Amount,
+		Multiplier: income.Multiplier,
+		StartsAt:   now,
+		EndsAt:     income.EndsAt,
+		CreatedAt:  now,
+	}
+	if income.StartsAt != nil {
+		item.StartsAt = *income.StartsAt
+	}
+	if err := validateCampaign(item); err != nil {
+		return nil, err
+	}
+	if item.Kind == campaign.KindOrder {
+		item.Amount = 0
+	} else {
+		item.Multiplier = 1
+	}
+	added, err := sqldb.AddCampaign(ctx, pgConn, item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add campaign by: %w", err)
+	}
+	if !added {
+		return nil, ErrCampaignExists
+	}
+
+	return item, nil
+}
+
+func validateCampaign(item *campaign.Campaign) error {
+	switch {
+	case item.Name == "" || len(item.Name) > maxCampaignNameLength:
+		return fmt.Errorf("%w: the name is required up to %d characters", ErrCampaignInvalid, maxCampaignNameLength)
+	case item.Kind != campaign.KindWelcome && item.Kind != campaign.KindOrder && item.Kind != campaign.KindPromo:
+		return fmt.Errorf("%w: unknown kind [%s]", ErrCampaignInvalid, item.Kind)
+	case item.Kind == campaign.KindPromo && (item.Code == "" || len(item.Code) > maxPromoCodeLength):
+		return fmt.Errorf("%w: the code is required up to %d characters", ErrCampaignInvalid, maxPromoCodeLength)
+	case item.Kind != campaign.KindPromo && item.Code != "":
+		return fmt.Errorf("%w: only a promo campaign has a code", ErrCampaignInvalid)
+	case item.Kind == campaign.KindOrder && item.Multiplier <= 1:
+		return fmt.Errorf("%w: the multiplier must be greater than 1", ErrCampaignInvalid)
+	case item.Kind != campaign.KindOrder && item.Amount <= 0:
+		return fmt.Errorf("%w: the amount must be positive", ErrCampaignInvalid)
+	case item.EndsAt != nil && !item.EndsAt.After(item.StartsAt):
+		return fmt.Errorf("%w: the end must be after the start", ErrCampaignInvalid)
+	}
+
+	return nil
+}
+
+// GetCampaigns returns all the campaigns from the oldest one.
+func GetCampaigns(ctx context.Context, pgConn *sqldb.PgxIface) ([]campaign.Campaign, error) {
+	items, err := sqldb.FindCampaigns(ctx, pgConn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaigns by: %w", err)
+	}
+
+	return items, nil
+}
+
+// GrantWelcomeBonuses credits the bonuses of the running welcome campaigns to the new user.
+func GrantWelcomeBonuses(ctx context.Context, pgConn *sqldb.PgxIface, username string) (float32, error) {
+	granted, err := sqldb.GrantWelcomeBonuses(ctx, pgConn, username, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to grant welcome bonuses by: %w", err)
+	}
+
+	return granted, nil
+}
+
+// RedeemPromo credits the bonus of the promo code to the user, every user redeems a code once.
+func RedeemPromo(
+	ctx context.Context, pgConn *sqldb.PgxIface, username string, code string, now time.Time,
+) (campaign.Redemption, error) {
+	redemption := campaign.Redemption{Code: code, Campaign: "", Amount: 0, RedeemedAt: now}
+	if code == "" {
+		return redemption, ErrPromoInvalid
+	}
+	item, redeemed, err := sqldb.RedeemPromoCode(ctx, pgConn, username, code, now)
+	switch {
+	case err != nil:
+		return redemption, fmt.Errorf("failed to redeem promo code by: %w", err)
+	case item == nil:
+		return redemption, ErrPromoNotFound
+	case !redeemed:
+		return redemption, ErrPromoRedeemed
+	}
+	redemption.Campaign = item.Name
+	redemption.Amount = item.Amount
+
+	return redemption, nil
+}
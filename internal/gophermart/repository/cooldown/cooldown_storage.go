@@ -35,3 +35,15 @@ func IsAccrualReady() bool {
 
 	return false
 }
+
+// CooldownUntil returns the time when the cooldown of 'Accrual' ends
+// and false if there is no cooldown, it doesn't change the state unlike IsAccrualReady.
+func CooldownUntil() (time.Time, bool) {
+	cooldownSync.Lock()
+	defer cooldownSync.Unlock()
+	if failTimeUnixSec == 0 || failTimeUnixSec+cooldownPeriod < time.Now().Unix() {
+		return time.Time{}, false
+	}
+
+	return time.Unix(failTimeUnixSec+cooldownPeriod, 0), true
+}
@@ -48,3 +48,20 @@ func TestIsAccrualReady(t *testing.T) {
 		})
 	}
 }
+
+func TestCooldownUntil(t *testing.T) {
+	NeedAccrualCooldown(-2) // resets a cooldown of previous tests
+	_, got := CooldownUntil()
+	assert.False(t, got)
+
+	NeedAccrualCooldown(100)
+	until, got := CooldownUntil()
+	assert.True(t, got)
+	assert.True(t, until.After(time.Now()))
+	assert.False(t, IsAccrualReady())
+
+	NeedAccrualCooldown(-2)
+	_, got = CooldownUntil()
+	assert.False(t, got)
+	assert.True(t, IsAccrualReady())
+}
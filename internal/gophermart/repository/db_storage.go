@@ -1,19 +1,20 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
 
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/credential"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
 	"github.com/jackc/pgx/v5"
-	"go.uber.org/zap"
 )
 
-func AddCredentials(pgConn *sqldb.PgxIface, cred credential.Credentials) error {
-	err := sqldb.AddCredentials(pgConn, &cred)
+func AddCredentials(ctx context.Context, pgConn *sqldb.PgxIface, cred credential.Credentials) error {
+	err := sqldb.AddCredentials(ctx, pgConn, &cred)
 	if err == nil {
 		return nil
 	}
@@ -23,8 +24,8 @@ func AddCredentials(pgConn *sqldb.PgxIface, cred credential.Credentials) error {
 
 var ErrUserNameNotFound = fmt.Errorf("username not found")
 
-func GetCredentials(pgConn *sqldb.PgxIface, username string) (*credential.Credentials, error) {
-	cred, err := sqldb.FindUserByUsername(pgConn, username)
+func GetCredentials(ctx context.Context, pgConn *sqldb.PgxIface, username string) (*credential.Credentials, error) {
+	cred, err := sqldb.FindUserByUsername(ctx, pgConn, username)
 	if cred == nil && err == nil {
 		err = ErrUserNameNotFound
 	}
@@ -34,8 +35,10 @@ func GetCredentials(pgConn *sqldb.PgxIface, username string) (*credential.Creden
 
 var ErrWithdrawsNoItems = fmt.Errorf("there are not withdrawals")
 
-func FindWithdrawsByUsername(pgConn *sqldb.PgxIface, username string) (*[]accrual.WithdrawExt, error) {
-	withdraws, err := sqldb.FindWithdrawsByUsername(pgConn, username)
+func FindWithdrawsByUsername(
+	ctx context.Context, pgConn *sqldb.PgxIface, username string,
+) (*[]accrual.WithdrawExt, error) {
+	withdraws, err := sqldb.FindWithdrawsByUsername(ctx, pgConn, username)
 	if err != nil {
 		err = fmt.Errorf("failed to get withdraws by: %w", err)
 
@@ -53,11 +56,15 @@ var (
 	ErrOrderAlreadyExistsByOwner   = fmt.Errorf("failed to add order: order already exists by the owner")
 	ErrOrderAlreadyExistsByAnother = fmt.Errorf("failed to add order: order already exists by another user")
 
+	ErrWithdrawInvalid = fmt.Errorf("failed to process withdrawal: the sum must be positive")
 	ErrWithdrawNoMoney = fmt.Errorf("failed to process withdrawal: no money")
+
+	ErrAdjustmentInvalid = fmt.Errorf("failed to adjust balance: no amount or no reason")
+	ErrAdjustmentNoMoney = fmt.Errorf("failed to adjust balance: no money")
 )
 
-func AddNewOrder(pgConn *sqldb.PgxIface, sNumber string, username string) error {
-	order, err := sqldb.FindOrderByNumber(pgConn, sNumber)
+func AddNewOrder(ctx context.Context, pgConn *sqldb.PgxIface, sNumber string, username string) error {
+	order, err := sqldb.FindOrderByNumber(ctx, pgConn, sNumber)
 
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		return ErrCantAddOrder
@@ -73,15 +80,15 @@ func AddNewOrder(pgConn *sqldb.PgxIface, sNumber string, username string) error
 
 	order = accrual.NewOrderExt(sNumber, accrual.OrderStatusNew, 0, time.Now(), username)
 
-	if err = sqldb.AddOrder(pgConn, order); err != nil {
+	if err = sqldb.AddOrder(ctx, pgConn, order); err != nil {
 		return fmt.Errorf("failed to add order by:%w", err)
 	}
 
 	return nil
 }
 
-func GetOrdersByUser(pgConn *sqldb.PgxIface, username string) (*[]accrual.OrderExt, error) {
-	orders, err := sqldb.FindOrdersByUsername(pgConn, username)
+func GetOrdersByUser(ctx context.Context, pgConn *sqldb.PgxIface, username string) (*[]accrual.OrderExt, error) {
+	orders, err := sqldb.FindOrdersByUsername(ctx, pgConn, username)
 	if err != nil {
 		return orders, fmt.Errorf("%w", err)
 	}
@@ -89,34 +96,97 @@ func GetOrdersByUser(pgConn *sqldb.PgxIface, username string) (*[]accrual.OrderE
 	return orders, nil
 }
 
-func GetBalance(pgConn *sqldb.PgxIface, username string) (accrual.BalanceExt, error) {
-	result := accrual.BalanceExt{Current: 0, Withdrawn: 0}
-	debit, err := sqldb.GetDebitByUsername(pgConn, username)
-	zap.S().Debugln("debit:", debit, "err:", err)
+func GetBalance(ctx context.Context, pgConn *sqldb.PgxIface, username string) (accrual.BalanceExt, error) {
+	result := accrual.BalanceExt{Current: 0, Withdrawn: 0, ExpiringSoon: nil}
+	current, withdrawn, err := sqldb.GetBalanceByUsername(ctx, pgConn, username)
+	logging.FromContext(ctx).Debugln("current:", current, "withdrawn:", withdrawn, "err:", err)
 	if err != nil {
 		return result, fmt.Errorf("%w", err)
 	}
-	credit, err := sqldb.GetCreditByUsername(pgConn, username)
-	zap.S().Debugln("credit:", credit, "err:", err)
+	result.Current = current
+	result.Withdrawn = withdrawn
+
+	return result, nil
+}
+
+var ErrTransactionsNoItems = fmt.Errorf("there are not transactions")
+
+// GetTransactions returns a page of up to limit transactions of the user after the transaction after
+// from the oldest one, zero after returns the first page.
+func GetTransactions(
+	ctx context.Context, pgConn *sqldb.PgxIface, username string, after int64, limit int,
+) (accrual.TransactionsPage, error) {
+	page := accrual.TransactionsPage{Transactions: nil, NextAfter: 0}
+	transactions, err := sqldb.FindTransactionsByUsername(ctx, pgConn, username, after, limit+1)
 	if err != nil {
-		return result, fmt.Errorf("%w", err)
+		return page, fmt.Errorf("failed to get transactions by: %w", err)
 	}
-	result.Current = debit - credit
-	result.Withdrawn = credit
+	if len(transactions) == 0 {
+		return page, ErrTransactionsNoItems
+	}
+	if len(transactions) > limit {
+		transactions = transactions[:limit]
+		page.NextAfter = transactions[limit-1].ID
+	}
+	page.Transactions = transactions
 
-	return result, nil
+	return page, nil
 }
 
-func ProcessWithdraw(pgConn *sqldb.PgxIface, withdraw accrual.WithdrawExt) error {
-	balance, err := GetBalance(pgConn, withdraw.Username)
+// GetExpiringPoints returns the points of the user which expire until the time,
+// the points expire after expireAfter since they are credited.
+func GetExpiringPoints(
+	ctx context.Context, pgConn *sqldb.PgxIface, username string, expireAfter time.Duration, until time.Time,
+) ([]accrual.ExpiringPoints, error) {
+	points, err := sqldb.FindExpiringPoints(ctx, pgConn, username, expireAfter, until)
 	if err != nil {
-		return fmt.Errorf("withdraw: failed to get balance order by:%w", err)
+		return nil, fmt.Errorf("failed to get expiring points by: %w", err)
 	}
-	if balance.Current <= 0 || withdraw.Sum > balance.Current {
-		return ErrWithdrawNoMoney
+
+	return points, nil
+}
+
+// GetLifetimeAccrual returns the accrual of all the processed orders of the user.
+func GetLifetimeAccrual(ctx context.Context, pgConn *sqldb.PgxIface, username string) (float32, error) {
+	lifetime, err := sqldb.GetLifetimeAccrual(ctx, pgConn, username)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get lifetime accrual by: %w", err)
 	}
 
-	if err = sqldb.AddWithdraw(pgConn, withdraw); err != nil {
+	return lifetime, nil
+}
+
+// AdjustBalance adds the amount to the balance of the user by an operator and returns the new balance.
+func AdjustBalance(
+	ctx context.Context, pgConn *sqldb.PgxIface, username string, amount float32, reason string,
+) (accrual.BalanceExt, error) {
+	var balance accrual.BalanceExt
+	if amount == 0 || reason == "" {
+		return balance, ErrAdjustmentInvalid
+	}
+	if _, err := GetCredentials(ctx, pgConn, username); err != nil {
+		return balance, fmt.Errorf("failed to find user by: %w", err)
+	}
+	if err := sqldb.AddAdjustment(ctx, pgConn, username, amount, reason); err != nil {
+		if errors.Is(err, sqldb.ErrNoMoney) {
+			return balance, ErrAdjustmentNoMoney
+		}
+
+		return balance, fmt.Errorf("failed to adjust balance by: %w", err)
+	}
+
+	return GetBalance(ctx, pgConn, username)
+}
+
+func ProcessWithdraw(ctx context.Context, pgConn *sqldb.PgxIface, withdraw accrual.WithdrawExt) error {
+	if withdraw.Sum <= 0 {
+		return ErrWithdrawInvalid
+	}
+	if err := sqldb.AddWithdraw(ctx, pgConn, withdraw); err != nil {
+		if errors.Is(err, sqldb.ErrNoMoney) {
+			return ErrWithdrawNoMoney
+		}
+
 		return fmt.Errorf("withdraw: failed to add withdraw by:%w", err)
 	}
 
@@ -34,7 +34,7 @@ func TestGetCredentialsReturnsErrUserNameNotFound(t *testing.T) {
 
 	var pgConn sqldb.PgxIface = mock
 
-	cred, err := GetCredentials(&pgConn, "user2")
+	cred, err := GetCredentials(context.Background(), &pgConn, "user2")
 
 	assert.ErrorIs(t, err, ErrUserNameNotFound)
 	assert.Nil(t, cred)
@@ -59,7 +59,7 @@ func TestAddCredentials(t *testing.T) {
 		WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
 	var pgConn sqldb.PgxIface = mock
-	err = AddCredentials(&pgConn, *credential.NewCredentials("user1", "pass1"))
+	err = AddCredentials(context.Background(), &pgConn, *credential.NewCredentials("user1", "pass1"))
 	assert.NoError(t, err)
 
 	err = mock.ExpectationsWereMet()
@@ -85,36 +85,42 @@ func TestAddNewOrderErr(t *testing.T) {
 		WillReturnRows(rows)
 
 	var pgConn sqldb.PgxIface = mock
-	err = AddNewOrder(&pgConn, "79927398713", "user1")
+	err = AddNewOrder(context.Background(), &pgConn, "79927398713", "user1")
 	assert.Error(t, err)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
+func TestWithdrawInvalid(t *testing.T) {
+	for _, sum := range []float32{0, -10} {
+		withdraw := accrual.NewWithdrawExt("2377225624", sum, time.Now(), "login2")
+		err := ProcessWithdraw(context.Background(), nil, *withdraw)
+		assert.ErrorIs(t, err, ErrWithdrawInvalid)
+	}
+}
+
 func TestWithdrawErr(t *testing.T) {
 	mock, err := pgxmock.NewConn()
 	require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
 
-	var want float32 = 42.0
-	rows := mock.NewRows([]string{"accrual"}).
-		AddRow(want)
-
 	defer func(mock pgxmock.PgxConnIface, ctx context.Context) {
 		mock.ExpectClose()
 		err = mock.Close(ctx)
 		require.NoError(t, err)
 	}(mock, context.Background())
 
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(accrual\\),0\\)").
-		WithArgs("login2").WillReturnRows(rows)
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(sum\\),0\\)").
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT 1 FROM mart_users WHERE name = \\$1 FOR UPDATE").WithArgs("login2").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\)").
 		WithArgs("login2").WillReturnError(io.EOF)
+	mock.ExpectRollback()
 
 	var pgConn sqldb.PgxIface = mock
-	withdrawInternal := accrual.WithdrawAccrual{} //nolint:exhaustruct
+	withdrawInternal := accrual.WithdrawAccrual{Order: "2377225624", Sum: 10}
 	withdraw := withdrawInternal.GetWithdrawExt("login2", time.Now())
-	err = ProcessWithdraw(&pgConn, *withdraw)
+	err = ProcessWithdraw(context.Background(), &pgConn, *withdraw)
 	assert.Error(t, err)
 
 	err = mock.ExpectationsWereMet()
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/referral"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+)
+
+// referralCodeSize is a count of the random bytes of a referral code, it is 8 characters in base32.
+const referralCodeSize = 5
+
+var (
+	ErrReferralCodeNotFound = fmt.Errorf("failed to refer: referral code not found")
+	ErrReferralSelf         = fmt.Errorf("failed to refer: a user may not refer themselves")
+)
+
+func newReferralCode() (string, error) {
+	code := make([]byte, referralCodeSize)
+	if _, err := rand.Read(code); err != nil {
+		return "", fmt.Errorf("failed to generate referral code: %w", err)
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(code), nil
+}
+
+// FindReferrer returns the user of the referral code given by the referee on registration.
+func FindReferrer(ctx context.Context, pgConn *sqldb.PgxIface, code string, referee string) (string, error) {
+	referrer, err := sqldb.FindReferrerByCode(ctx, pgConn, code)
+	switch {
+	case err != nil:
+		return "", fmt.Errorf("failed to find referrer by: %w", err)
+	case referrer == "":
+		return "", ErrReferralCodeNotFound
+	case referrer == referee:
+		return "", ErrReferralSelf
+	}
+
+	return referrer, nil
+}
+
+// AddReferral records that the new user is registered by the code of the referrer.
+func AddReferral(ctx context.Context, pgConn *sqldb.PgxIface, referrer string, referee string) error {
+	if referrer == referee {
+		return ErrReferralSelf
+	}
+	if _, err := sqldb.AddReferral(ctx, pgConn, referrer, referee); err != nil {
+		return fmt.Errorf("failed to add referral by: %w", err)
+	}
+
+	return nil
+}
+
+// GetReferrals returns the referral code of the user and the users registered by it,
+// a user gets a code on the first request.
+func GetReferrals(
+	ctx context.Context, pgConn *sqldb.PgxIface, username string, bonus float32, maxRewards int,
+) (referral.Referrals, error) {
+	result := referral.Referrals{Code: "", Bonus: bonus, MaxRewards: maxRewards, Rewarded: 0, Referrals: nil}
+	newCode, err := newReferralCode()
+	if err != nil {
+		return result, err
+	}
+	if result.Code, err = sqldb.GetReferralCode(ctx, pgConn, username, newCode); err != nil {
+		return result, fmt.Errorf("failed to get referral code by: %w", err)
+	}
+	if result.Referrals, err = sqldb.FindReferralsByReferrer(ctx, pgConn, username); err != nil {
+		return result, fmt.Errorf("failed to get referrals by: %w", err)
+	}
+	for _, item := range result.Referrals {
+		if item.Status == referral.StatusRewarded {
+			result.Rewarded++
+		}
+	}
+
+	return result, nil
+}
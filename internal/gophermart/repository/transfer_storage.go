@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+)
+
+// transferLimitWindow is a window of the daily limits of the transfers of a user.
+const transferLimitWindow = 24 * time.Hour
+
+var (
+	ErrTransferInvalid     = fmt.Errorf("failed to transfer: no recipient, the recipient is the sender or no amount")
+	ErrTransferNoRecipient = fmt.Errorf("failed to transfer: recipient not found")
+	ErrTransferNoMoney     = fmt.Errorf("failed to transfer: no money")
+	ErrTransferLimit       = fmt.Errorf("failed to transfer: daily limit is exceeded")
+)
+
+// TransferLimits are limits of the transfers of a user for the last 24 hours, zero disables a limit.
+type TransferLimits struct {
+	MaxSum   float32
+	MaxCount int
+}
+
+// ProcessTransfer moves the amount of the transfer to the recipient if the sender has enough points
+// and the transfer does not exceed the limits.
+func ProcessTransfer(
+	ctx context.Context, pgConn *sqldb.PgxIface, transfer accrual.TransferExt, limits TransferLimits,
+) error {
+	if transfer.Recipient == "" || transfer.Recipient == transfer.Username || transfer.Amount <= 0 {
+		return ErrTransferInvalid
+	}
+	check := func(state sqldb.TransferState) error {
+		switch {
+		case !state.RecipientExists:
+			return ErrTransferNoRecipient
+		case state.Balance <= 0 || transfer.Amount > state.Balance:
+			return ErrTransferNoMoney
+		case limits.MaxCount > 0 && state.SentCount >= limits.MaxCount:
+			return fmt.Errorf("%w: %d transfers", ErrTransferLimit, state.SentCount)
+		case limits.MaxSum > 0 && state.SentSum+transfer.Amount > limits.MaxSum:
+			return fmt.Errorf("%w: %v points transferred", ErrTransferLimit, state.SentSum)
+		}
+
+		return nil
+	}
+	if err := sqldb.AddTransfer(ctx, pgConn, transfer, transfer.ProcessedAt.Add(-transferLimitWindow), check); err != nil {
+		return fmt.Errorf("transfer: failed to add transfer by: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/webhook"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/notifier"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+)
+
+// MaxWebhooksPerUser limits webhooks of a user.
+const MaxWebhooksPerUser = 10
+
+// DeliveriesLogLimit is a count of the latest deliveries returned in the log of a webhook.
+const DeliveriesLogLimit = 100
+
+var (
+	ErrWebhookAlreadyExists = fmt.Errorf("failed to add webhook: the url is already registered")
+	ErrWebhookLimit         = fmt.Errorf("failed to add webhook: too many webhooks")
+	ErrWebhookNotFound      = fmt.Errorf("webhook not found")
+	ErrWebhooksNoItems      = fmt.Errorf("there are not webhooks")
+	ErrDeliveriesNoItems    = fmt.Errorf("there are not deliveries")
+)
+
+// AddWebhook registers the URL for the user and returns the webhook with its secret.
+func AddWebhook(ctx context.Context, pgConn *sqldb.PgxIface, username string, url string) (*webhook.Webhook, error) {
+	count, err := sqldb.CountWebhooksByUsername(ctx, pgConn, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add webhook by: %w", err)
+	}
+	if count >= MaxWebhooksPerUser {
+		return nil, ErrWebhookLimit
+	}
+
+	secret, err := notifier.NewSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to add webhook by: %w", err)
+	}
+	hook := &webhook.Webhook{ID: 0, URL: url, Secret: secret, Username: username} //nolint:exhaustruct
+	added, err := sqldb.AddWebhook(ctx, pgConn, hook)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add webhook by: %w", err)
+	}
+	if !added {
+		return nil, ErrWebhookAlreadyExists
+	}
+
+	return hook, nil
+}
+
+// GetWebhooks returns the webhooks of the user without their secrets.
+func GetWebhooks(ctx context.Context, pgConn *sqldb.PgxIface, username string) ([]webhook.Webhook, error) {
+	hooks, err := sqldb.FindWebhooksByUsername(ctx, pgConn, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks by: %w", err)
+	}
+	if len(hooks) == 0 {
+		return nil, ErrWebhooksNoItems
+	}
+
+	return hooks, nil
+}
+
+// DeleteWebhook deletes the webhook of the user.
+func DeleteWebhook(ctx context.Context, pgConn *sqldb.PgxIface, username string, id int64) error {
+	deleted, err := sqldb.DeleteWebhook(ctx, pgConn, username, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook by: %w", err)
+	}
+	if !deleted {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// GetWebhookDeliveries returns the latest deliveries of the webhook of the user.
+func GetWebhookDeliveries(
+	ctx context.Context, pgConn *sqldb.PgxIface, username string, id int64,
+) ([]webhook.Delivery, error) {
+	exists, err := sqldb.WebhookExists(ctx, pgConn, username, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deliveries by: %w", err)
+	}
+	if !exists {
+		return nil, ErrWebhookNotFound
+	}
+	deliveries, err := sqldb.FindWebhookDeliveries(ctx, pgConn, username, id, DeliveriesLogLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deliveries by: %w", err)
+	}
+	if len(deliveries) == 0 {
+		return nil, ErrDeliveriesNoItems
+	}
+
+	return deliveries, nil
+}
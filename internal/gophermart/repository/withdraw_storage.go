@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+)
+
+var (
+	ErrWithdrawNotFound       = fmt.Errorf("withdrawal not found")
+	ErrWithdrawNotCancellable = fmt.Errorf("failed to cancel withdrawal: it is not pending or the grace period is over")
+	ErrWithdrawNotRefundable  = fmt.Errorf("failed to refund withdrawal: it is cancelled or refunded already")
+	ErrWithdrawRefundNoReason = fmt.Errorf("failed to refund withdrawal: no reason")
+)
+
+// CancelWithdraw cancels the pending withdrawal of the user for the order within the grace period after it is made.
+func CancelWithdraw(
+	ctx context.Context, pgConn *sqldb.PgxIface, username string, number string, grace time.Duration,
+) (*accrual.WithdrawExt, error) {
+	cancelled, err := sqldb.CancelWithdraw(ctx, pgConn, username, number, time.Now().Add(-grace))
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel withdrawal by: %w", err)
+	}
+	if cancelled != nil {
+		return cancelled, nil
+	}
+
+	return nil, explainWithdraw(ctx, pgConn, username, number, ErrWithdrawNotCancellable)
+}
+
+// RefundWithdraw refunds the withdrawal for the order, it is an operation of an operator.
+func RefundWithdraw(
+	ctx context.Context, pgConn *sqldb.PgxIface, number string, reason string,
+) (*accrual.WithdrawExt, error) {
+	if reason == "" {
+		return nil, ErrWithdrawRefundNoReason
+	}
+	refunded, err := sqldb.RefundWithdraw(ctx, pgConn, number, reason)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refund withdrawal by: %w", err)
+	}
+	if refunded != nil {
+		return refunded, nil
+	}
+
+	return nil, explainWithdraw(ctx, pgConn, "", number, ErrWithdrawNotRefundable)
+}
+
+// explainWithdraw returns ErrWithdrawNotFound if there is no withdrawal for the order and errState otherwise.
+func explainWithdraw(
+	ctx context.Context, pgConn *sqldb.PgxIface, username string, number string, errState error,
+) error {
+	withdraw, err := sqldb.FindLastWithdraw(ctx, pgConn, username, number)
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to find withdrawal by: %w", err)
+	case withdraw == nil:
+		return ErrWithdrawNotFound
+	default:
+		return fmt.Errorf("%w: %s", errState, withdraw.Status)
+	}
+}
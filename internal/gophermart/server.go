@@ -8,27 +8,32 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/config"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/events"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/metrics"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/middleware"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/notifier"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/openapi"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/outbox"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/pgnotify"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/ratelimit"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/sqldb"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/tlscert"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/tracing"
 	"github.com/labstack/echo/v4"
 	middleware2 "github.com/labstack/echo/v4/middleware"
 	"github.com/labstack/gommon/log"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 	"go.uber.org/zap"
 )
 
 func Run() {
 	var err error
-	loggerZap := zap.Must(zap.NewDevelopment())
-
-	defer func(logger *zap.Logger) {
-		_ = logger.Sync()
-	}(loggerZap)
-
-	zap.ReplaceGlobals(loggerZap)
+	bootstrapLogger := zap.Must(zap.NewProduction())
+	zap.ReplaceGlobals(bootstrapLogger)
 
 	cfg := config.NewConfig()
 	if err = setupConfig(cfg, config.ProcessEnvServer); err != nil {
@@ -37,9 +42,33 @@ func Run() {
 
 		return
 	}
+
+	logger, err := logging.Setup(logOptions(*cfg))
+	if err != nil {
+		zap.S().Errorf("failed to set up logging by %s", err.Error())
+
+		return
+	}
+	defer func() {
+		_ = logger.Close()
+	}()
+	zap.ReplaceGlobals(logger.Logger)
+
 	echoFramework := echo.New()
 	zap.S().Info("cfg:" + cfg.String())
 
+	shutdownTracing, err := tracing.Setup(cfg.TraceExporter, cfg.TraceEndpoint, cfg.TraceFile)
+	if err != nil {
+		zap.S().Errorf("failed to set up tracing by %s", err.Error())
+
+		return
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			zap.S().Warnf("failed to shut down tracing by %s", err.Error())
+		}
+	}()
+
 	var conn *sqldb.PgxIface
 	if conn, err = sqldb.ConnectDB(cfg); err == nil {
 		defer (*conn).Close(context.Background())
@@ -48,71 +77,275 @@ func Run() {
 
 		return
 	}
-	startServer(echoFramework, conn, *cfg)
+	startServer(echoFramework, conn, *cfg, logger)
 }
 
-var (
-	errNoAddress = fmt.Errorf("server address is empty")
-	errNoPathDB  = fmt.Errorf("db uri is empty")
-)
+func logOptions(cfg config.Config) logging.Options {
+	return logging.Options{
+		Level:      cfg.LogLevel,
+		Format:     cfg.LogFormat,
+		Sampling:   cfg.LogSampling,
+		File:       cfg.LogFile,
+		MaxSizeMB:  cfg.LogMaxSizeMB,
+		MaxBackups: cfg.LogMaxBackups,
+		MaxAgeDays: cfg.LogMaxAgeDays,
+	}
+}
+
+var errNoConnectionDB = fmt.Errorf("no db connection")
 
 func setupConfig(cfg *config.Config, processing config.ProcessEnv) error {
 	if err := config.LoadConfig(cfg, processing); err != nil {
 		return fmt.Errorf("couldn't create a config %w", err)
 	}
-	if cfg.Address == "" {
-		return errNoAddress
-	}
-	if cfg.ConnectionDB == "" {
-		return errNoPathDB
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("%w", err)
 	}
 
 	return nil
 }
 
-func startServer(echoFramework *echo.Echo, conn *sqldb.PgxIface, cfg config.Config) {
+func startServer(echoFramework *echo.Echo, conn *sqldb.PgxIface, cfg config.Config, logger *logging.Logger) {
+	// Setup
+	echoFramework.Logger.SetLevel(logger.EchoLevel())
+	echoFramework.HTTPErrorHandler = handler.HTTPErrorHandler
+	echoFramework.IPExtractor = echo.ExtractIPFromXFFHeader()
+	echoFramework.Use(otelecho.Middleware(tracing.ServiceName), middleware.RequestID(),
+		middleware.Compress(cfg.CompressLevel, cfg.CompressMinLength), middleware.Decompress(cfg.DecompressMaxSize))
+	useHSTS(echoFramework, cfg)
+	baseHandler := registerRoutes(echoFramework, conn, cfg)
+	registerAdminRoutes(echoFramework, baseHandler, cfg, logger)
+
+	server := echoFramework.Server
+	var reloader *tlscert.Reloader
+	if cfg.TLSEnabled() {
+		var err error
+		if reloader, err = tlscert.NewReloader(cfg.TLSCertFile, cfg.TLSKeyFile); err != nil {
+			zap.S().Errorf("failed to load TLS certificate by %s", err.Error())
+
+			return
+		}
+		watchCtx, stopWatch := context.WithCancel(context.Background())
+		defer stopWatch()
+		go reloader.Watch(watchCtx, cfg.TLSReloadInterval)
+		server = echoFramework.TLSServer
+		server.TLSConfig = newTLSConfig(reloader)
+	}
+	server.Addr = cfg.Address
+	applyTimeouts(server, cfg)
+	server.RegisterOnShutdown(events.Default().Close)
+	if conn != nil {
+		dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+		defer stopDispatch()
+		startDispatchers(dispatchCtx, conn, cfg)
+		go baseHandler.RunAccrualPoller(dispatchCtx)
+		go baseHandler.RunWithdrawCompleter(dispatchCtx)
+		go baseHandler.RunPointsExpirer(dispatchCtx)
+	}
+
+	// Start server
+	go func() {
+		zap.S().Infof("start server, tls: %v", cfg.TLSEnabled())
+		if err := echoFramework.StartServer(server); err != nil && errors.Is(err, http.ErrServerClosed) {
+			echoFramework.Logger.Warn("shutting down the server")
+		}
+	}()
+
+	var redirectServer *http.Server
+	if cfg.HTTPRedirectAddress != "" {
+		redirectServer = newRedirectServer(cfg)
+		go func() {
+			zap.S().Infof("start redirect to https on %s", cfg.HTTPRedirectAddress)
+			if err := redirectServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				zap.S().Errorf("failed to start redirect server by %s", err.Error())
+			}
+		}()
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	go handleHangup(hangup, echoFramework, logger, reloader)
+
+	<-quit
+	zap.S().Info("quit...")
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if redirectServer != nil {
+		if err := redirectServer.Shutdown(ctx); err != nil {
+			zap.S().Warnf("failed to shut down redirect server by %s", err.Error())
+		}
+	}
+	if err := echoFramework.Shutdown(ctx); err != nil {
+		zap.S().Fatal(err)
+	}
+}
+
+func registerRoutes(echoFramework *echo.Echo, conn *sqldb.PgxIface, cfg config.Config) *handler.BaseHandler {
 	loggerConfig := middleware.GetRequestLoggerConfig()
 	log2 := middleware2.RequestLoggerWithConfig(loggerConfig)
 	log3 := middleware2.BodyDump(middleware.GetBodyLoggerHandler())
 
-	// Setup
 	baseHandler := handler.NewBaseHandler(conn, cfg)
-	echoFramework.Logger.SetLevel(log.INFO)
+	store := newRateLimitStore(conn, cfg)
+	limit := func(method string, path string) echo.MiddlewareFunc {
+		routeLimit := cfg.RateLimitFor(method, path)
+
+		return middleware.RateLimiter(store, method+" "+path,
+			ratelimit.Limit{Rate: routeLimit.Rate, Burst: routeLimit.Burst})
+	}
+
+	validate := middleware.OpenAPIValidator(openapi.MustLoad(), cfg.OpenAPIValidateResponses)
+	echoFramework.GET(openapi.Path, openapi.Handler)
+
 	echoFramework.POST("/api/user/register", baseHandler.RegistrationHandler,
-		log2, log3)
+		log2, log3, limit(http.MethodPost, "/api/user/register"), validate)
 	echoFramework.POST("/api/user/login", baseHandler.LoginHandler,
-		log2, log3)
+		log2, log3, limit(http.MethodPost, "/api/user/login"), validate)
 
 	authM := middleware.AuthValidator(conn)
 
 	echoFramework.GET("/api/user/orders", baseHandler.OrdersListHandler,
-		log2, log3, authM)
+		log2, log3, authM, limit(http.MethodGet, "/api/user/orders"), validate)
+	echoFramework.GET("/api/user/orders/stream", baseHandler.OrdersStreamHandler,
+		log2, authM, limit(http.MethodGet, "/api/user/orders/stream"), validate)
 	echoFramework.POST("/api/user/orders", baseHandler.OrderUploadHandler,
-		log2, log3, authM, middleware.OrderValidator())
+		log2, log3, authM, limit(http.MethodPost, "/api/user/orders"), validate, middleware.OrderValidator())
 	echoFramework.POST("/api/user/balance/withdraw", baseHandler.WithdrawHandler,
-		log2, log3, authM)
+		log2, log3, authM, limit(http.MethodPost, "/api/user/balance/withdraw"), validate)
+	echoFramework.POST("/api/user/balance/transfer", baseHandler.TransferHandler,
+		log2, log3, authM, limit(http.MethodPost, "/api/user/balance/transfer"), validate)
 	echoFramework.GET("/api/user/balance", baseHandler.BalanceHandler,
-		log2, log3, authM)
+		log2, log3, authM, limit(http.MethodGet, "/api/user/balance"), validate)
+	echoFramework.GET("/api/user/transactions", baseHandler.TransactionsHandler,
+		log2, log3, authM, limit(http.MethodGet, "/api/user/transactions"), validate)
+	echoFramework.POST("/api/user/promo", baseHandler.PromoHandler,
+		log2, log3, authM, limit(http.MethodPost, "/api/user/promo"), validate)
+	echoFramework.GET("/api/user/referrals", baseHandler.ReferralsHandler,
+		log2, log3, authM, limit(http.MethodGet, "/api/user/referrals"), validate)
+	echoFramework.GET("/api/user/profile", baseHandler.ProfileHandler,
+		log2, log3, authM, limit(http.MethodGet, "/api/user/profile"), validate)
 	echoFramework.GET("/api/user/withdrawals", baseHandler.WithdrawsListHandler,
-		log2, log3, authM)
+		log2, log3, authM, limit(http.MethodGet, "/api/user/withdrawals"), validate)
+	echoFramework.POST("/api/user/withdrawals/:order/cancel", baseHandler.WithdrawCancelHandler,
+		log2, log3, authM, limit(http.MethodPost, "/api/user/withdrawals/:order/cancel"), validate)
 
-	// Start server
-	go func(cfg config.Config) {
-		zap.S().Info("start server")
-		if err := echoFramework.Start(cfg.Address); err != nil && errors.Is(err, http.ErrServerClosed) {
-			echoFramework.Logger.Warn("shutting down the server")
+	echoFramework.POST("/api/user/webhooks", baseHandler.WebhookRegisterHandler,
+		log2, log3, authM, limit(http.MethodPost, "/api/user/webhooks"), validate)
+	echoFramework.GET("/api/user/webhooks", baseHandler.WebhooksListHandler,
+		log2, log3, authM, limit(http.MethodGet, "/api/user/webhooks"), validate)
+	echoFramework.DELETE("/api/user/webhooks/:id", baseHandler.WebhookDeleteHandler,
+		log2, log3, authM, limit(http.MethodDelete, "/api/user/webhooks/:id"), validate)
+	echoFramework.GET("/api/user/webhooks/:id/deliveries", baseHandler.WebhookDeliveriesHandler,
+		log2, log3, authM, limit(http.MethodGet, "/api/user/webhooks/:id/deliveries"), validate)
+
+	echoFramework.GET("/healthz", baseHandler.HealthzHandler)
+	echoFramework.GET("/readyz", baseHandler.ReadyzHandler)
+
+	registry := metrics.NewRegistry(func(ctx context.Context) (map[string]int64, error) {
+		if conn == nil {
+			return nil, errNoConnectionDB
 		}
-	}(cfg)
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		return sqldb.CountOrdersByStatus(ctx, conn) //nolint:wrapcheck
+	})
+	echoFramework.GET("/metrics", metrics.Handler(registry))
 
-	<-quit
-	zap.S().Info("quit...")
-	timeoutDelay := 10
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutDelay)*time.Second)
-	defer cancel()
-	if err := echoFramework.Shutdown(ctx); err != nil {
-		zap.S().Fatal(err)
+	return baseHandler
+}
+
+// startDispatchers starts delivering the events of the outbox to the webhooks
+// and over NOTIFY to the subscriptions of all instances.
+func startDispatchers(ctx context.Context, conn *sqldb.PgxIface, cfg config.Config) {
+	webhooks := notifier.NewDispatcher(conn, notifierOptions(cfg))
+	go webhooks.Run(ctx)
+
+	listener := pgnotify.NewListener(pgnotify.Dial(cfg.ConnectionDB), cfg.ListenBackoff, cfg.ListenMaxBackoff)
+	listener.Handle(events.TypeOrderUpdated, events.Default().HandleRecord)
+	go listener.Run(ctx)
+
+	publish := func(ctx context.Context, record events.Record) error {
+		return pgnotify.Publish(ctx, conn, record)
+	}
+	dispatcher := outbox.NewDispatcher(conn, cfg.OutboxPollInterval, cfg.OutboxRetention)
+	dispatcher.Handle(events.TypeOrderUpdated, publish)
+	dispatcher.Handle(events.TypeWithdrawalCreated, publish)
+	dispatcher.Handle(events.TypeWithdrawalCancelled, publish)
+	dispatcher.Handle(events.TypeWithdrawalRefunded, publish)
+	dispatcher.Handle(events.TypeOrderUpdated, webhooks.HandleRecord)
+	dispatcher.Handle(events.TypeWithdrawalCreated, webhooks.HandleRecord)
+	dispatcher.Handle(events.TypeWithdrawalCancelled, webhooks.HandleRecord)
+	dispatcher.Handle(events.TypeWithdrawalRefunded, webhooks.HandleRecord)
+	go dispatcher.Run(ctx)
+}
+
+func notifierOptions(cfg config.Config) notifier.Options {
+	return notifier.Options{
+		Timeout:      cfg.WebhookTimeout,
+		PollInterval: cfg.WebhookPollInterval,
+		MaxAttempts:  cfg.WebhookMaxAttempts,
+		BaseBackoff:  cfg.WebhookBackoff,
+		MaxBackoff:   cfg.WebhookMaxBackoff,
+		AllowPrivate: cfg.WebhookAllowPrivate,
+	}
+}
+
+// newRateLimitStore creates the store of the rate limits,
+// the buckets are kept in memory when the DB is not available.
+func newRateLimitStore(conn *sqldb.PgxIface, cfg config.Config) ratelimit.Store {
+	if cfg.RateLimitStore == "postgres" {
+		if conn != nil {
+			return ratelimit.NewPostgresStore(conn)
+		}
+		zap.S().Warn("rate limits are kept in memory: no db connection")
+	}
+
+	return ratelimit.NewMemoryStore()
+}
+
+// registerAdminRoutes registers endpoints for operators,
+// they are available only when the admin token is configured.
+func registerAdminRoutes(
+	echoFramework *echo.Echo, baseHandler *handler.BaseHandler, cfg config.Config, logger *logging.Logger,
+) {
+	if cfg.AdminToken == "" {
+		return
+	}
+	adminM := middleware.AdminValidator(cfg.AdminToken)
+	levelHandler := func(ctx echo.Context) error {
+		logger.Level.ServeHTTP(ctx.Response(), ctx.Request())
+		echoFramework.Logger.SetLevel(logger.EchoLevel())
+
+		return nil
+	}
+	echoFramework.GET("/admin/log/level", levelHandler, adminM)
+	echoFramework.PUT("/admin/log/level", levelHandler, adminM)
+	echoFramework.POST("/admin/withdrawals/:order/refund", baseHandler.WithdrawRefundHandler, adminM)
+	echoFramework.POST("/admin/users/:login/adjustments", baseHandler.BalanceAdjustHandler, adminM)
+	echoFramework.POST("/admin/campaigns", baseHandler.CampaignAddHandler, adminM)
+	echoFramework.GET("/admin/campaigns", baseHandler.CampaignsListHandler, adminM)
+}
+
+// handleHangup restores the configured log level, reopens the log file
+// and reloads TLS certificate on SIGHUP.
+func handleHangup(
+	hangup <-chan os.Signal, echoFramework *echo.Echo, logger *logging.Logger, reloader *tlscert.Reloader,
+) {
+	configured := logger.Level.Level()
+	for range hangup {
+		if reloader != nil {
+			if err := reloader.Reload(); err != nil {
+				zap.S().Warnf("SIGHUP: %s", err.Error())
+			}
+		}
+		logger.Level.SetLevel(configured)
+		echoFramework.Logger.SetLevel(logger.EchoLevel())
+		if err := logger.Rotate(); err != nil {
+			zap.S().Warnf("SIGHUP: %s", err.Error())
+		}
+		zap.S().Infof("SIGHUP: log level is %s", configured)
 	}
 }
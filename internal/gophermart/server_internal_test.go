@@ -2,11 +2,21 @@ package gophermart
 
 import (
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/config"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/handler"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/logging"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/middleware"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/openapi"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/util"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
 	flag2 "github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -15,18 +25,19 @@ import (
 	"go.uber.org/zap/zaptest"
 )
 
+// testDatabaseURI is a valid URI of a DB which does not listen.
+const testDatabaseURI = "postgres://127.0.0.1:1/gophermart?connect_timeout=1"
+
 func TestSetupConfigOk(t *testing.T) {
-	want := &config.Config{
-		Address:      ":8080",
-		ConnectionDB: "ej",
-		Accrual:      "",
-	}
+	want := config.NewConfig()
+	want.Address = ":8080"
+	want.ConnectionDB = testDatabaseURI
 
 	origValueAddress := os.Getenv(config.EnvKeyAddress)
 	err := os.Setenv(config.EnvKeyAddress, ":8080") //nolint:tenv
 	require.NoError(t, err)
 	origValuePathDB := os.Getenv(config.EnvKeyDatabaseURI)
-	err = os.Setenv(config.EnvKeyDatabaseURI, "ej") //nolint:tenv
+	err = os.Setenv(config.EnvKeyDatabaseURI, testDatabaseURI) //nolint:tenv
 	require.NoError(t, err)
 	t.Cleanup(func() {
 		err = os.Setenv(config.EnvKeyAddress, origValueAddress)
@@ -115,7 +126,7 @@ func TestRunErrConnectionDB(t *testing.T) {
 	err := os.Setenv(config.EnvKeyAddress, ":8080") //nolint:tenv
 	require.NoError(t, err)
 	origValuePathDB := os.Getenv(config.EnvKeyDatabaseURI)
-	err = os.Setenv(config.EnvKeyDatabaseURI, "ej") //nolint:tenv
+	err = os.Setenv(config.EnvKeyDatabaseURI, testDatabaseURI) //nolint:tenv
 	require.NoError(t, err)
 	t.Cleanup(func() {
 		zap.ReplaceGlobals(original)
@@ -128,3 +139,187 @@ func TestRunErrConnectionDB(t *testing.T) {
 
 	Run()
 }
+
+func TestRegisterRoutesMetrics(t *testing.T) {
+	echoFramework := echo.New()
+	defer echoFramework.Close()
+	registerRoutes(echoFramework, nil, *config.NewConfig())
+
+	req := httptest.NewRequest(echo.GET, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	echoFramework.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "gophermart_accrual_workers_in_flight")
+}
+
+// TestRegisterRoutesOpenAPIContract checks that the OpenAPI document
+// and the registered routes describe the same API.
+func TestRegisterRoutesOpenAPIContract(t *testing.T) {
+	echoFramework := echo.New()
+	defer echoFramework.Close()
+	registerRoutes(echoFramework, nil, *config.NewConfig())
+	doc := openapi.MustLoad()
+
+	registered := make(map[string]bool)
+	for _, route := range echoFramework.Routes() {
+		if !strings.HasPrefix(route.Path, "/api/user/") {
+			continue
+		}
+		key := route.Method + " " + openapi.ToOpenAPIPath(route.Path)
+		registered[key] = true
+		assert.NotNil(t, openapi.FindRoute(doc, route.Method, route.Path), "%s is absent in the document", key)
+	}
+
+	for path, pathItem := range doc.Paths {
+		for method := range pathItem.Operations() {
+			key := method + " " + path
+			assert.True(t, registered[key], "%s is not registered", key)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	echoFramework.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, openapi.Path, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRegisterAdminRoutes(t *testing.T) {
+	logger, err := logging.Setup(logging.Options{})
+	require.NoError(t, err)
+
+	echoFramework := echo.New()
+	defer echoFramework.Close()
+	echoFramework.HTTPErrorHandler = handler.HTTPErrorHandler
+	cfg := config.Config{AdminToken: "secret"} //nolint:exhaustruct
+	registerAdminRoutes(echoFramework, handler.NewBaseHandler(nil, cfg), cfg, logger)
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/log/level", strings.NewReader(`{"level":"debug"}`))
+	rec := httptest.NewRecorder()
+	echoFramework.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, zapcore.InfoLevel, logger.Level.Level())
+
+	req = httptest.NewRequest(http.MethodPut, "/admin/log/level", strings.NewReader(`{"level":"debug"}`))
+	req.Header.Set(middleware.HeaderXAdminToken, "secret")
+	rec = httptest.NewRecorder()
+	echoFramework.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, zapcore.DebugLevel, logger.Level.Level())
+	assert.Equal(t, log.DEBUG, echoFramework.Logger.Level())
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/log/level", nil)
+	req.Header.Set(middleware.HeaderXAdminToken, "secret")
+	rec = httptest.NewRecorder()
+	echoFramework.ServeHTTP(rec, req)
+	assert.JSONEq(t, `{"level":"debug"}`, rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/withdrawals/79927398713/refund",
+		strings.NewReader(`{"reason":"fraud"}`))
+	rec = httptest.NewRecorder()
+	echoFramework.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/users/login3/adjustments",
+		strings.NewReader(`{"amount":10,"reason":"compensation"}`))
+	rec = httptest.NewRecorder()
+	echoFramework.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/campaigns", nil)
+	rec = httptest.NewRecorder()
+	echoFramework.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRegisterAdminRoutesDisabled(t *testing.T) {
+	logger, err := logging.Setup(logging.Options{})
+	require.NoError(t, err)
+
+	echoFramework := echo.New()
+	defer echoFramework.Close()
+	registerAdminRoutes(echoFramework, handler.NewBaseHandler(nil, *config.NewConfig()), *config.NewConfig(), logger)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/log/level", nil)
+	rec := httptest.NewRecorder()
+	echoFramework.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		httpsAddress string
+		target       string
+		want         string
+	}{
+		{
+			name: "custom port", httpsAddress: ":8443",
+			target: "http://example.com:8080/api/user/orders?a=1", want: "https://example.com:8443/api/user/orders?a=1",
+		},
+		{
+			name: "default port", httpsAddress: "0.0.0.0:443",
+			target: "http://example.com/api/user/balance", want: "https://example.com/api/user/balance",
+		},
+		{
+			name: "ipv6", httpsAddress: ":443",
+			target: "http://[::1]:8080/healthz", want: "https://[::1]/healthz",
+		},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, test.target, nil)
+			rec := httptest.NewRecorder()
+			httpsRedirectHandler(test.httpsAddress).ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+			assert.Equal(t, test.want, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func TestUseHSTS(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.TLSCertFile = "cert.pem"
+	cfg.TLSKeyFile = "key.pem"
+	cfg.HSTSMaxAge = time.Hour
+
+	echoFramework := echo.New()
+	defer echoFramework.Close()
+	useHSTS(echoFramework, *cfg)
+	echoFramework.GET("/healthz", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	echoFramework.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://localhost/healthz", nil))
+	assert.Equal(t, "max-age=3600; includeSubdomains", rec.Header().Get(echo.HeaderStrictTransportSecurity))
+
+	rec = httptest.NewRecorder()
+	echoFramework.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://localhost/healthz", nil))
+	assert.Empty(t, rec.Header().Get(echo.HeaderStrictTransportSecurity))
+
+	plain := echo.New()
+	defer plain.Close()
+	useHSTS(plain, *config.NewConfig())
+	plain.GET("/healthz", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	rec = httptest.NewRecorder()
+	plain.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://localhost/healthz", nil))
+	assert.Empty(t, rec.Header().Get(echo.HeaderStrictTransportSecurity))
+}
+
+func TestNewRedirectServer(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Address = ":8443"
+	cfg.HTTPRedirectAddress = ":8080"
+
+	server := newRedirectServer(*cfg)
+	assert.Equal(t, ":8080", server.Addr)
+	assert.Equal(t, cfg.ReadHeaderTimeout, server.ReadHeaderTimeout)
+
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://localhost:8080/", nil))
+	assert.Equal(t, "https://localhost:8443/", rec.Header().Get("Location"))
+}
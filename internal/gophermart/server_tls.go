@@ -0,0 +1,73 @@
+package gophermart
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/config"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/tlscert"
+	"github.com/labstack/echo/v4"
+	middleware2 "github.com/labstack/echo/v4/middleware"
+)
+
+const defaultHTTPSPort = "443"
+
+// newTLSConfig returns tls.Config which always takes the latest certificate of the reloader.
+func newTLSConfig(reloader *tlscert.Reloader) *tls.Config {
+	return &tls.Config{ //nolint:exhaustruct
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+}
+
+// applyTimeouts sets the configured timeouts to the server.
+func applyTimeouts(server *http.Server, cfg config.Config) {
+	server.ReadHeaderTimeout = cfg.ReadHeaderTimeout
+	server.ReadTimeout = cfg.ReadTimeout
+	server.WriteTimeout = cfg.WriteTimeout
+	server.IdleTimeout = cfg.IdleTimeout
+}
+
+// useHSTS adds 'Strict-Transport-Security' header to the responses served over HTTPS.
+func useHSTS(echoFramework *echo.Echo, cfg config.Config) {
+	if !cfg.TLSEnabled() || cfg.HSTSMaxAge <= 0 {
+		return
+	}
+	secureConfig := middleware2.DefaultSecureConfig
+	secureConfig.HSTSMaxAge = int(cfg.HSTSMaxAge.Seconds())
+	echoFramework.Use(middleware2.SecureWithConfig(secureConfig))
+}
+
+// newRedirectServer returns a plain HTTP server which redirects
+// all the requests to the same host and path served over HTTPS on httpsAddress.
+func newRedirectServer(cfg config.Config) *http.Server {
+	return &http.Server{ //nolint:exhaustruct
+		Addr:              cfg.HTTPRedirectAddress,
+		Handler:           httpsRedirectHandler(cfg.Address),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+}
+
+func httpsRedirectHandler(httpsAddress string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddress)
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		host := req.Host
+		if hostOnly, _, err := net.SplitHostPort(host); err == nil {
+			host = hostOnly
+		}
+		host = strings.Trim(host, "[]")
+		if port != "" && port != defaultHTTPSPort {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		target := url.URL{Scheme: "https", Host: host, Path: req.URL.Path, RawQuery: req.URL.RawQuery} //nolint:exhaustruct
+		http.Redirect(writer, req, target.String(), http.StatusPermanentRedirect)
+	})
+}
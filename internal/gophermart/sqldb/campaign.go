@@ -0,0 +1,259 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/campaign"
+	"github.com/jackc/pgx/v5"
+)
+
+const ledgerSourceCampaign = "campaign"
+
+const campaignColumns = "id, name, kind, COALESCE(code, ''), amount, multiplier, starts_at, ends_at, created_at"
+
+// findActiveCampaignsSQL returns the campaigns of the kind $1 which run at $2.
+const findActiveCampaignsSQL = `
+SELECT id, name, amount FROM campaigns
+WHERE kind = $1 AND starts_at <= $2 AND (ends_at IS NULL OR ends_at > $2)
+ORDER BY id`
+
+// findPromoCampaignSQL returns the promo campaign of the code $1 if it runs at $2.
+const findPromoCampaignSQL = `
+SELECT id, name, amount FROM campaigns
+WHERE kind = 'PROMO' AND code = $1 AND starts_at <= $2 AND (ends_at IS NULL OR ends_at > $2)`
+
+// orderCampaignsSQL returns the order campaigns which run when the order $1 is uploaded
+// with the bonus credited for the order by each of them before.
+const orderCampaignsSQL = `
+SELECT c.name, c.multiplier,
+       COALESCE((SELECT SUM(l.amount) FROM ledger_entries l
+                 WHERE l.account = 'USER' AND l.kind = 'CAMPAIGN_BONUS' AND l.source = 'order'
+                   AND l.source_id = o.number AND l.description = c.name), 0)
+FROM orders o
+JOIN campaigns c ON c.kind = 'ORDER' AND c.starts_at <= o.uploaded_at
+                AND (c.ends_at IS NULL OR c.ends_at > o.uploaded_at)
+WHERE o.number = $1
+ORDER BY c.id`
+
+// AddCampaign inserts the campaign and sets its ID and creation time,
+// it returns false if there is a campaign with the same name or code.
+func AddCampaign(ctx context.Context, pgConn *PgxIface, item *campaign.Campaign) (bool, error) {
+	ctx, done := observe(ctx, "AddCampaign")
+	defer done()
+
+	row := (*pgConn).QueryRow(ctx,
+		"INSERT INTO campaigns (name, kind, code, amount, multiplier, starts_at, ends_at)"+
+			" VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7) ON CONFLICT DO NOTHING RETURNING id, created_at",
+		item.Name, item.Kind, item.Code, item.Amount, item.Multiplier, item.StartsAt, item.EndsAt)
+	if err := row.Scan(&item.ID, &item.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+
+		return false, failed(ctx, fmt.Errorf("failed to insert into campaigns: %w", err))
+	}
+
+	return true, nil
+}
+
+// FindCampaigns returns all the campaigns from the oldest one.
+func FindCampaigns(ctx context.Context, pgConn *PgxIface) ([]campaign.Campaign, error) {
+	ctx, done := observe(ctx, "FindCampaigns")
+	defer done()
+
+	result := make([]campaign.Campaign, 0)
+	rows, err := (*pgConn).Query(ctx, "SELECT "+campaignColumns+" FROM campaigns ORDER BY id")
+	if err != nil {
+		return result, failed(ctx, fmt.Errorf("failed to query: %w", err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item campaign.Campaign
+		err = rows.Scan(&item.ID, &item.Name, &item.Kind, &item.Code, &item.Amount, &item.Multiplier,
+			&item.StartsAt, &item.EndsAt, &item.CreatedAt)
+		if err != nil {
+			return result, failed(ctx, fmt.Errorf("failed to scan a row: %w", err))
+		}
+		result = append(result, item)
+	}
+	if err = rows.Err(); err != nil {
+		return result, failed(ctx, fmt.Errorf("failed to read rows: %w", err))
+	}
+
+	return result, nil
+}
+
+// grantCampaign credits the bonus of the campaign to the user unless the user has got it before.
+func grantCampaign(ctx context.Context, tx pgx.Tx, item campaign.Campaign, username string) (bool, error) {
+	tag, err := tx.Exec(ctx,
+		"INSERT INTO campaign_redemptions (campaign_id, username) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		item.ID, username)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert into campaign_redemptions: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	err = insertLedger(ctx, tx, ledgerTransaction{
+		From:        ledgerAccount{Account: accrual.LedgerAccountCampaigns, Username: username},
+		To:          userAccount(username),
+		Amount:      item.Amount,
+		Kind:        accrual.LedgerKindCampaignBonus,
+		Source:      ledgerSourceCampaign,
+		SourceID:    strconv.FormatInt(item.ID, 10),
+		Description: item.Name,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// findActiveCampaigns returns the campaigns of the kind running at now.
+func findActiveCampaigns(ctx context.Context, tx pgx.Tx, kind string, now time.Time) ([]campaign.Campaign, error) {
+	result := make([]campaign.Campaign, 0)
+	rows, err := tx.Query(ctx, findActiveCampaignsSQL, kind, now)
+	if err != nil {
+		return result, fmt.Errorf("failed to query campaigns: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		item := campaign.Campaign{Kind: kind} //nolint:exhaustruct
+		if err = rows.Scan(&item.ID, &item.Name, &item.Amount); err != nil {
+			return result, fmt.Errorf("failed to scan a row: %w", err)
+		}
+		result = append(result, item)
+	}
+	if err = rows.Err(); err != nil {
+		return result, fmt.Errorf("failed to read rows: %w", err)
+	}
+
+	return result, nil
+}
+
+// GrantWelcomeBonuses credits the bonuses of the welcome campaigns running at now to the user once
+// and returns the credited sum.
+func GrantWelcomeBonuses(ctx context.Context, pgConn *PgxIface, username string, now time.Time) (float32, error) {
+	ctx, done := observe(ctx, "GrantWelcomeBonuses")
+	defer done()
+
+	var granted float32
+	err := inTx(ctx, pgConn, func(tx pgx.Tx) error {
+		items, err := findActiveCampaigns(ctx, tx, campaign.KindWelcome, now)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			ok, err := grantCampaign(ctx, tx, item, username)
+			if err != nil {
+				return err
+			}
+			if ok {
+				granted += item.Amount
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, failed(ctx, err)
+	}
+
+	return granted, nil
+}
+
+// RedeemPromoCode credits the bonus of the promo campaign of the code running at now to the user.
+// It returns nil if there is no such campaign and false if the user has redeemed the code before.
+func RedeemPromoCode(
+	ctx context.Context, pgConn *PgxIface, username string, code string, now time.Time,
+) (*campaign.Campaign, bool, error) {
+	ctx, done := observe(ctx, "RedeemPromoCode")
+	defer done()
+
+	var found *campaign.Campaign
+	var redeemed bool
+	err := inTx(ctx, pgConn, func(tx pgx.Tx) error {
+		item := campaign.Campaign{Kind: campaign.KindPromo, Code: code} //nolint:exhaustruct
+		err := tx.QueryRow(ctx, findPromoCampaignSQL, code, now).Scan(&item.ID, &item.Name, &item.Amount)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+
+			return fmt.Errorf("failed to find promo campaign: %w", err)
+		}
+		found = &item
+		redeemed, err = grantCampaign(ctx, tx, item, username)
+
+		return err
+	})
+	if err != nil {
+		return nil, false, failed(ctx, err)
+	}
+
+	return found, redeemed, nil
+}
+
+// orderBonus is a bonus of an order campaign for an order and the sum credited by it before.
+type orderBonus struct {
+	name       string
+	multiplier float32
+	credited   float32
+}
+
+func findOrderBonuses(ctx context.Context, tx pgx.Tx, number string) ([]orderBonus, error) {
+	result := make([]orderBonus, 0)
+	rows, err := tx.Query(ctx, orderCampaignsSQL, number)
+	if err != nil {
+		return result, fmt.Errorf("failed to query order campaigns: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bonus orderBonus
+		if err = rows.Scan(&bonus.name, &bonus.multiplier, &bonus.credited); err != nil {
+			return result, fmt.Errorf("failed to scan a row: %w", err)
+		}
+		result = append(result, bonus)
+	}
+	if err = rows.Err(); err != nil {
+		return result, fmt.Errorf("failed to read rows: %w", err)
+	}
+
+	return result, nil
+}
+
+// creditCampaignBonuses credits the bonuses of the order campaigns which run when the order is uploaded:
+// the accrual of the order multiplied by the multiplier of the campaign less the accrual itself.
+// Like the accrual, the difference with the bonus credited for the order before is credited.
+func creditCampaignBonuses(ctx context.Context, tx pgx.Tx, order *accrual.OrderExt) error {
+	bonuses, err := findOrderBonuses(ctx, tx, order.Number)
+	if err != nil {
+		return err
+	}
+	for _, bonus := range bonuses {
+		amount := roundPoints(order.Accrual*(bonus.multiplier-1)) - bonus.credited
+		if amount == 0 {
+			continue
+		}
+		err = insertLedger(ctx, tx, ledgerTransaction{
+			From:        ledgerAccount{Account: accrual.LedgerAccountCampaigns, Username: order.Username},
+			To:          userAccount(order.Username),
+			Amount:      amount,
+			Kind:        accrual.LedgerKindCampaignBonus,
+			Source:      ledgerSourceOrder,
+			SourceID:    order.Number,
+			Description: bonus.name,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
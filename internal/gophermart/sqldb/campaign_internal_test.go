@@ -0,0 +1,90 @@
+package sqldb
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateOrderCampaignBonuses(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE orders").WithArgs("PROCESSED", float32(100), "79927398713").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\) FROM ledger_entries").WithArgs("79927398713").
+		WillReturnRows(pgxmock.NewRows([]string{"amount"}).AddRow(float32(100)))
+	mock.ExpectQuery("SELECT c.name, c.multiplier").WithArgs("79927398713").
+		WillReturnRows(pgxmock.NewRows([]string{"name", "multiplier", "credited"}).
+			AddRow("Double points", float32(2), float32(0)).
+			AddRow("Black Friday", float32(1.5), float32(50)))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("CAMPAIGNS", "user1", "USER", "user1", float32(100), "CAMPAIGN_BONUS", "order", "79927398713",
+			"Double points").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs("order.updated", "user1", pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	order := accrual.NewOrderExt("79927398713", "PROCESSED", float32(100), time.Now(), "user1")
+	require.NoError(t, UpdateOrder(context.Background(), &pgConn, order, nil))
+	assert.NoError(t, mock.ExpectationsWereMet(), "the bonus credited before is not credited again")
+}
+
+func TestGrantWelcomeBonuses(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, name, amount FROM campaigns").WithArgs("WELCOME", now).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "name", "amount"}).
+			AddRow(int64(1), "Welcome", float32(100)).
+			AddRow(int64(2), "Autumn welcome", float32(20)))
+	mock.ExpectExec("INSERT INTO campaign_redemptions").WithArgs(int64(1), "user1").
+		WillReturnResult(pgxmock.NewResult("INSERT", 0))
+	mock.ExpectExec("INSERT INTO campaign_redemptions").WithArgs(int64(2), "user1").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("CAMPAIGNS", "user1", "USER", "user1", float32(20), "CAMPAIGN_BONUS", "campaign", "2",
+			"Autumn welcome").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, name, amount FROM campaigns").WithArgs("WELCOME", now).
+		WillReturnError(io.EOF)
+	mock.ExpectRollback()
+
+	granted, err := GrantWelcomeBonuses(context.Background(), &pgConn, "user1", now)
+	require.NoError(t, err)
+	assert.Equal(t, float32(20), granted, "a campaign is granted once")
+
+	_, err = GrantWelcomeBonuses(context.Background(), &pgConn, "user1", now)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindCampaignsErr(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	mock.ExpectQuery("SELECT id, name, kind").WillReturnError(io.EOF)
+	mock.ExpectQuery("SELECT id, name, kind").WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int64(1)))
+
+	_, err = FindCampaigns(context.Background(), &pgConn)
+	assert.ErrorIs(t, err, io.EOF)
+	items, err := FindCampaigns(context.Background(), &pgConn)
+	assert.Error(t, err)
+	assert.Empty(t, items)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -8,11 +8,17 @@ import (
 	"time"
 
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/config"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/events"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/metrics"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/credential"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/tracing"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/pashagolub/pgxmock/v2"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -26,9 +32,40 @@ type PgxIface interface {
 	Close(context.Context) error
 }
 
+// SchemaVersion is a version of the DB schema which is expected by the service,
+// it matches the latest migration in 'db/migrations'.
+const SchemaVersion = 11
+
 var errNoInfoConnectionDB = errors.New("no DB connection info")
 
-// ConnectDB opens a connection to the database.
+// ErrNoMoney is returned by AddWithdraw and AddAdjustment if the balance of the user is less than
+// the points taken from it.
+var ErrNoMoney = errors.New("no money")
+
+// observe starts measuring and tracing of the query,
+// call the returned func when the query is done.
+func observe(ctx context.Context, query string) (context.Context, func()) {
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "sqldb."+query,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String(query)))
+
+	return ctx, func() {
+		span.End()
+		metrics.ObserveDBQuery(query, time.Since(start))
+	}
+}
+
+// failed marks the query traced by ctx as failed.
+func failed(ctx context.Context, err error) error {
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	return err
+}
+
+// ConnectDB opens a pool of connections to the database.
 func ConnectDB(cfg *config.Config) (*PgxIface, error) {
 	inTestRunning := os.Getenv("GO_ENV1") == "testing"
 	var conn PgxIface
@@ -42,23 +79,24 @@ func ConnectDB(cfg *config.Config) (*PgxIface, error) {
 		if cfg == nil || cfg.ConnectionDB == "" {
 			return nil, errNoInfoConnectionDB
 		}
-		conn, err = pgx.Connect(context.Background(), cfg.ConnectionDB)
+		conn, err = connectPool(cfg)
 	}
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to get a DB connection: %w", err)
 	}
-	timeout := 10
 
-	if err = createTables(&conn, timeout); err != nil {
+	if err = createTables(&conn, cfg.DBConnectTimeout); err != nil {
+		_ = conn.Close(context.Background())
+
 		return nil, err
 	}
-	zap.S().Infoln("successfully connected to db:", conn)
+	zap.S().Infoln("successfully connected to db")
 
 	return &conn, nil
 }
 
-func createTables(pgConn *PgxIface, timeout int) error {
+func createTables(pgConn *PgxIface, timeout time.Duration) error {
 	sqlString := `
 BEGIN TRANSACTION;
 
@@ -109,9 +147,259 @@ CREATE INDEX IF NOT EXISTS idx_withdraws_username
     ON withdraws USING hash (username);
 CREATE INDEX IF NOT EXISTS idx_withdraws_status_username
     ON withdraws (username, sum);
+
+CREATE TABLE IF NOT EXISTS rate_limits
+(
+    key        VARCHAR(256)     PRIMARY KEY,
+    tokens     DOUBLE PRECISION NOT NULL,
+    allowed    BOOLEAN          NOT NULL,
+    full_at    TIMESTAMPTZ      NOT NULL,
+    updated_at TIMESTAMPTZ      NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limits_full_at
+    ON rate_limits (full_at);
+
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id         BIGSERIAL PRIMARY KEY,
+    username   VARCHAR(72)   NOT NULL,
+    url        VARCHAR(2048) NOT NULL,
+    secret     VARCHAR(64)   NOT NULL,
+    created_at TIMESTAMPTZ   NOT NULL DEFAULT now(),
+    UNIQUE (username, url)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id               BIGSERIAL PRIMARY KEY,
+    webhook_id       BIGINT      NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event            VARCHAR(64) NOT NULL,
+    payload          TEXT        NOT NULL,
+    status           VARCHAR(16) NOT NULL,
+    attempts         INTEGER     NOT NULL DEFAULT 0,
+    next_attempt_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_status_code INTEGER     NOT NULL DEFAULT 0,
+    last_error       TEXT        NOT NULL DEFAULT '',
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at     TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due
+    ON webhook_deliveries (next_attempt_at) WHERE status = 'PENDING';
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id
+    ON webhook_deliveries (webhook_id, id);
+
+CREATE TABLE IF NOT EXISTS webhook_dead_letters
+(
+    id               BIGSERIAL PRIMARY KEY,
+    delivery_id      BIGINT        NOT NULL,
+    webhook_id       BIGINT        NOT NULL,
+    url              VARCHAR(2048) NOT NULL,
+    event            VARCHAR(64)   NOT NULL,
+    payload          TEXT          NOT NULL,
+    attempts         INTEGER       NOT NULL,
+    last_status_code INTEGER       NOT NULL,
+    last_error       TEXT          NOT NULL,
+    failed_at        TIMESTAMPTZ   NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id           BIGSERIAL PRIMARY KEY,
+    event        VARCHAR(64) NOT NULL,
+    username     VARCHAR(72) NOT NULL,
+    payload      TEXT        NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (id) WHERE delivered_at IS NULL;
+
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS next_check_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS attempts      INTEGER     NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_error    TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS needs_review  BOOLEAN     NOT NULL DEFAULT false;
+
+DROP INDEX IF EXISTS idx_orders_pending;
+
+ALTER TABLE orders DROP COLUMN IF EXISTS poll_lease_until;
+
+CREATE INDEX IF NOT EXISTS idx_orders_next_check ON orders (next_check_at)
+    WHERE status IN ('NEW', 'PROCESSING') AND NOT needs_review;
+
+ALTER TABLE withdraws
+    ADD COLUMN IF NOT EXISTS status     VARCHAR(10) NOT NULL DEFAULT 'COMPLETED',
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+
+ALTER TABLE withdraws ALTER COLUMN status SET DEFAULT 'PENDING';
+
+CREATE INDEX IF NOT EXISTS idx_withdraws_pending ON withdraws (processed_at) WHERE status = 'PENDING';
+
+CREATE TABLE IF NOT EXISTS withdraw_refunds
+(
+    id          BIGSERIAL PRIMARY KEY,
+    withdraw_id INTEGER          NOT NULL REFERENCES withdraws (id),
+    username    VARCHAR(72)      NOT NULL,
+    sum         DOUBLE PRECISION NOT NULL,
+    reason      TEXT             NOT NULL,
+    created_at  TIMESTAMPTZ      NOT NULL DEFAULT now(),
+    UNIQUE (withdraw_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_withdraw_refunds_username ON withdraw_refunds (username);
+
+CREATE SEQUENCE IF NOT EXISTS ledger_transactions_seq;
+
+CREATE TABLE IF NOT EXISTS ledger_entries
+(
+    id             BIGSERIAL PRIMARY KEY,
+    transaction_id BIGINT           NOT NULL,
+    account        VARCHAR(16)      NOT NULL,
+    username       VARCHAR(72)      NOT NULL,
+    amount         DOUBLE PRECISION NOT NULL,
+    kind           VARCHAR(32)      NOT NULL,
+    source         VARCHAR(16)      NOT NULL,
+    source_id      VARCHAR(72)      NOT NULL,
+    description    TEXT             NOT NULL DEFAULT '',
+    created_at     TIMESTAMPTZ      NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_user
+    ON ledger_entries (username, id) WHERE account = 'USER';
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_source
+    ON ledger_entries (source, source_id);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_transaction_id
+    ON ledger_entries (transaction_id);
+
+CREATE OR REPLACE FUNCTION ledger_entries_immutable() RETURNS TRIGGER AS
+$$
+BEGIN
+    RAISE EXCEPTION 'ledger entries are immutable';
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS ledger_entries_immutable ON ledger_entries;
+
+CREATE TRIGGER ledger_entries_immutable
+    BEFORE UPDATE OR DELETE ON ledger_entries
+    FOR EACH ROW EXECUTE FUNCTION ledger_entries_immutable();
+
+DO
+$$
+BEGIN
+    IF EXISTS (SELECT 1 FROM ledger_entries) THEN
+        RETURN;
+    END IF;
+
+    WITH src AS (
+        SELECT nextval('ledger_transactions_seq') AS tx, username, accrual, number, uploaded_at
+        FROM orders WHERE status = 'PROCESSED' AND accrual <> 0
+    )
+    INSERT INTO ledger_entries (transaction_id, account, username, amount, kind, source, source_id, created_at)
+    SELECT tx, 'ACCRUAL', username, -accrual, 'ACCRUAL', 'order', number, uploaded_at FROM src
+    UNION ALL
+    SELECT tx, 'USER', username, accrual, 'ACCRUAL', 'order', number, uploaded_at FROM src;
+
+    WITH src AS (
+        SELECT nextval('ledger_transactions_seq') AS tx, username, sum, id, processed_at
+        FROM withdraws
+    )
+    INSERT INTO ledger_entries (transaction_id, account, username, amount, kind, source, source_id, created_at)
+    SELECT tx, 'USER', username, -sum, 'WITHDRAWAL', 'withdraw', id::TEXT, processed_at FROM src
+    UNION ALL
+    SELECT tx, 'WITHDRAWALS', username, sum, 'WITHDRAWAL', 'withdraw', id::TEXT, processed_at FROM src;
+
+    WITH src AS (
+        SELECT nextval('ledger_transactions_seq') AS tx, username, sum, id, COALESCE(updated_at, now()) AS created_at
+        FROM withdraws WHERE status = 'CANCELLED'
+    )
+    INSERT INTO ledger_entries (transaction_id, account, username, amount, kind, source, source_id, created_at)
+    SELECT tx, 'WITHDRAWALS', username, -sum, 'WITHDRAWAL_CANCEL', 'withdraw', id::TEXT, created_at FROM src
+    UNION ALL
+    SELECT tx, 'USER', username, sum, 'WITHDRAWAL_CANCEL', 'withdraw', id::TEXT, created_at FROM src;
+
+    WITH src AS (
+        SELECT nextval('ledger_transactions_seq') AS tx, username, sum, withdraw_id, reason, created_at
+        FROM withdraw_refunds
+    )
+    INSERT INTO ledger_entries
+        (transaction_id, account, username, amount, kind, source, source_id, description, created_at)
+    SELECT tx, 'WITHDRAWALS', username, -sum, 'WITHDRAWAL_REFUND', 'withdraw', withdraw_id::TEXT, reason, created_at
+    FROM src
+    UNION ALL
+    SELECT tx, 'USER', username, sum, 'WITHDRAWAL_REFUND', 'withdraw', withdraw_id::TEXT, reason, created_at
+    FROM src;
+END
+$$;
+
+ALTER TABLE mart_users
+    ADD COLUMN IF NOT EXISTS points_expired_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS campaigns
+(
+    id         SERIAL PRIMARY KEY,
+    name       VARCHAR(64)      NOT NULL UNIQUE,
+    kind       VARCHAR(16)      NOT NULL,
+    code       VARCHAR(32) UNIQUE,
+    amount     DOUBLE PRECISION NOT NULL DEFAULT 0,
+    multiplier DOUBLE PRECISION NOT NULL DEFAULT 1,
+    starts_at  TIMESTAMPTZ      NOT NULL,
+    ends_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ      NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_kind
+    ON campaigns (kind, starts_at);
+
+CREATE TABLE IF NOT EXISTS campaign_redemptions
+(
+    campaign_id INT         NOT NULL REFERENCES campaigns (id),
+    username    VARCHAR(72) NOT NULL,
+    redeemed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (campaign_id, username)
+);
+
+CREATE TABLE IF NOT EXISTS referral_codes
+(
+    username VARCHAR(72) PRIMARY KEY,
+    code     VARCHAR(16) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS referrals
+(
+    referee      VARCHAR(72) PRIMARY KEY,
+    referrer     VARCHAR(72) NOT NULL,
+    status       VARCHAR(10) NOT NULL DEFAULT 'PENDING',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    processed_at TIMESTAMPTZ,
+    CHECK (referrer <> referee)
+);
+
+CREATE INDEX IF NOT EXISTS idx_referrals_referrer
+    ON referrals (referrer, created_at);
+
+ALTER TABLE ledger_entries
+    ALTER COLUMN source_id TYPE VARCHAR(72);
+
+CREATE TABLE IF NOT EXISTS schema_migrations
+(
+    version BIGINT  NOT NULL PRIMARY KEY,
+    dirty   BOOLEAN NOT NULL
+);
+
+INSERT INTO schema_migrations(version, dirty)
+    SELECT 0, false WHERE NOT EXISTS (SELECT 1 FROM schema_migrations);
+
+UPDATE schema_migrations SET version = %[1]d, dirty = false WHERE version < %[1]d;
 COMMIT;
 `
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(timeout))
+	sqlString = fmt.Sprintf(sqlString, SchemaVersion)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	if _, err := (*pgConn).Exec(ctx, sqlString); err != nil {
@@ -121,77 +409,145 @@ COMMIT;
 	return nil
 }
 
-func AddCredentials(pgConn *PgxIface, cred *credential.Credentials) error {
+func AddCredentials(ctx context.Context, pgConn *PgxIface, cred *credential.Credentials) error {
+	ctx, done := observe(ctx, "AddCredentials")
+	defer done()
+
 	_, err := (*pgConn).Exec(
-		context.Background(),
+		ctx,
 		"insert into mart_users(name, password) values($1, $2)",
 		cred.Username, cred.HashedPass)
 	if err != nil {
-		return fmt.Errorf("failed to insert into mart_users: %w", err)
+		return failed(ctx, fmt.Errorf("failed to insert into mart_users: %w", err))
 	}
 
 	return nil
 }
 
-func FindUserByUsername(pgConn *PgxIface, username string) (*credential.Credentials, error) {
+func FindUserByUsername(ctx context.Context, pgConn *PgxIface, username string) (*credential.Credentials, error) {
+	ctx, done := observe(ctx, "FindUserByUsername")
+	defer done()
+
 	var cred *credential.Credentials
 	var nameM, valueP string
-	row := (*pgConn).QueryRow(context.Background(), "select name, password from mart_users where name=$1", username)
+	row := (*pgConn).QueryRow(ctx, "select name, password from mart_users where name=$1", username)
 	err := row.Scan(&nameM, &valueP)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return cred, nil
 		}
 
-		return nil, fmt.Errorf("failed to scan a row: %w", err)
+		return nil, failed(ctx, fmt.Errorf("failed to scan a row: %w", err))
 	}
 	cred = credential.NewCredentials(nameM, valueP)
 
 	return cred, nil
 }
 
-func AddOrder(pgConn *PgxIface, order *accrual.OrderExt) error {
+func AddOrder(ctx context.Context, pgConn *PgxIface, order *accrual.OrderExt) error {
+	ctx, done := observe(ctx, "AddOrder")
+	defer done()
+
 	_, err := (*pgConn).Exec(
-		context.Background(),
+		ctx,
 		"insert into orders(number, status, accrual, username, uploaded_at) values($1, $2, $3, $4, $5)",
 		order.Number, order.Status, order.Accrual, order.Username, order.UploadedAt)
 	if err != nil {
-		return fmt.Errorf("failed to insert into orders: %w", err)
+		return failed(ctx, fmt.Errorf("failed to insert into orders: %w", err))
 	}
 
 	return nil
 }
 
-func AddWithdraw(pgConn *PgxIface, withdraw accrual.WithdrawExt) error {
-	_, err := (*pgConn).Exec(
-		context.Background(),
-		"insert into withdraws(number, sum, username, processed_at) values($1, $2, $3, $4)",
-		withdraw.Order, withdraw.Sum, withdraw.Username, withdraw.ProcessedAt)
+// AddWithdraw writes the withdrawal off the balance of the user, it fails with ErrNoMoney
+// if the balance is less than the sum. The user is locked for the transaction,
+// so concurrent withdrawals and transfers may not spend the same points.
+func AddWithdraw(ctx context.Context, pgConn *PgxIface, withdraw accrual.WithdrawExt) error {
+	ctx, done := observe(ctx, "AddWithdraw")
+	defer done()
+
+	err := inTx(ctx, pgConn, func(tx pgx.Tx) error {
+		current, err := lockBalance(ctx, tx, withdraw.Username)
+		if err != nil {
+			return err
+		}
+		if current <= 0 || withdraw.Sum > current {
+			return ErrNoMoney
+		}
+		err = tx.QueryRow(
+			ctx,
+			"insert into withdraws(number, sum, username, processed_at) values($1, $2, $3, $4) RETURNING id",
+			withdraw.Order, withdraw.Sum, withdraw.Username, withdraw.ProcessedAt).Scan(&withdraw.ID)
+		if err != nil {
+			return fmt.Errorf("failed to insert into withdraws: %w", err)
+		}
+		if err = insertLedger(ctx, tx, withdrawLedger(&withdraw, accrual.LedgerKindWithdrawal, "")); err != nil {
+			return err
+		}
+
+		return insertOutbox(ctx, tx, events.TypeWithdrawalCreated, withdraw.Username, withdraw)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to insert into orders: %w", err)
+		return failed(ctx, err)
 	}
 
 	return nil
 }
 
-func UpdateOrder(pgConn *PgxIface, order *accrual.OrderExt) error {
-	_, err := (*pgConn).Exec(
-		context.Background(),
-		"UPDATE orders SET status = $1, accrual = $2 WHERE number = $3",
-		order.Status, order.Accrual, order.Number)
+// UpdateOrder updates the status and the accrual of the order,
+// a real change is written to the outbox and the accrual of a processed order is credited to the ledger
+// in the same transaction with the bonuses of the order campaigns;
+// the tier and the referral bonuses are credited with the accrual unless bonuses is nil.
+func UpdateOrder(ctx context.Context, pgConn *PgxIface, order *accrual.OrderExt, bonuses *OrderBonuses) error {
+	ctx, done := observe(ctx, "UpdateOrder")
+	defer done()
+
+	err := inTx(ctx, pgConn, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(
+			ctx,
+			"UPDATE orders SET status = $1, accrual = $2 WHERE number = $3"+
+				" AND (status IS DISTINCT FROM $1 OR accrual IS DISTINCT FROM $2)",
+			order.Status, order.Accrual, order.Number)
+		if err != nil {
+			return fmt.Errorf("failed to update into orders: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return nil
+		}
+		if order.Status == accrual.OrderStatusProcessed {
+			if err = creditAccrual(ctx, tx, order); err != nil {
+				return err
+			}
+			if err = creditOrderBonuses(ctx, tx, order, bonuses); err != nil {
+				return err
+			}
+		}
+
+		return insertOutbox(ctx, tx, events.TypeOrderUpdated, order.Username, events.OrderEvent{
+			ID:        0,
+			Number:    order.Number,
+			Status:    order.Status,
+			Accrual:   order.Accrual,
+			ChangedAt: time.Now(),
+			Username:  order.Username,
+		})
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update into orders: %w", err)
+		return failed(ctx, err)
 	}
 
 	return nil
 }
 
-func FindOrderByNumber(pgConn *PgxIface, sNumber string) (*accrual.OrderExt, error) {
+func FindOrderByNumber(ctx context.Context, pgConn *PgxIface, sNumber string) (*accrual.OrderExt, error) {
+	ctx, done := observe(ctx, "FindOrderByNumber")
+	defer done()
+
 	var order *accrual.OrderExt
 	var number, status, username string
 	var uploadedAt time.Time
 	var accrualV float32
-	row := (*pgConn).QueryRow(context.Background(),
+	row := (*pgConn).QueryRow(ctx,
 		"SELECT number, status, accrual, username, uploaded_at FROM orders WHERE number=$1", sNumber)
 	err := row.Scan(&number, &status, &accrualV, &username, &uploadedAt)
 	if err != nil {
@@ -199,19 +555,22 @@ func FindOrderByNumber(pgConn *PgxIface, sNumber string) (*accrual.OrderExt, err
 			return order, nil
 		}
 
-		return nil, fmt.Errorf("failed to scan a row: %w", err)
+		return nil, failed(ctx, fmt.Errorf("failed to scan a row: %w", err))
 	}
 	order = accrual.NewOrderExt(number, status, accrualV, uploadedAt, username)
 
 	return order, nil
 }
 
-func FindOrdersByUsername(pgConn *PgxIface, username string) (*[]accrual.OrderExt, error) {
+func FindOrdersByUsername(ctx context.Context, pgConn *PgxIface, username string) (*[]accrual.OrderExt, error) {
+	ctx, done := observe(ctx, "FindOrdersByUsername")
+	defer done()
+
 	result := make([]accrual.OrderExt, 0)
-	rows, err := (*pgConn).Query(context.Background(),
+	rows, err := (*pgConn).Query(ctx,
 		"SELECT number, status, accrual, uploaded_at FROM orders WHERE username=$1", username)
 	if err != nil {
-		return &result, fmt.Errorf("failed to query: %w", err)
+		return &result, failed(ctx, fmt.Errorf("failed to query: %w", err))
 	}
 	defer rows.Close()
 	for rows.Next() {
@@ -220,7 +579,7 @@ func FindOrdersByUsername(pgConn *PgxIface, username string) (*[]accrual.OrderEx
 		var accrualV float32
 		err = rows.Scan(&number, &status, &accrualV, &uploadedAt)
 		if err != nil {
-			return &result, fmt.Errorf("failed to scan a row: %w", err)
+			return &result, failed(ctx, fmt.Errorf("failed to scan a row: %w", err))
 		}
 
 		order := accrual.NewOrderExt(number, status, accrualV, uploadedAt, username)
@@ -230,60 +589,34 @@ func FindOrdersByUsername(pgConn *PgxIface, username string) (*[]accrual.OrderEx
 	return &result, nil
 }
 
-func GetDebitByUsername(pgConn *PgxIface, username string) (float32, error) {
-	row := (*pgConn).QueryRow(context.Background(),
-		"SELECT COALESCE(SUM(accrual),0) FROM orders WHERE username=$1 AND status='PROCESSED'", username)
-	var accrualV float32
-	err := row.Scan(&accrualV)
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return 0, nil
-		}
-
-		return 0, fmt.Errorf("failed to get debit: %w", err)
-	}
-
-	return accrualV, nil
-}
-
-func GetCreditByUsername(pgConn *PgxIface, username string) (float32, error) {
-	row := (*pgConn).QueryRow(context.Background(),
-		"SELECT COALESCE(SUM(sum),0) FROM withdraws WHERE username=$1", username)
-	var accrualV float32
-	err := row.Scan(&accrualV)
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return 0, nil
-		}
-
-		return 0, fmt.Errorf("failed to get credit: %w", err)
-	}
+func FindWithdrawsByUsername(ctx context.Context, pgConn *PgxIface, username string) (*[]accrual.WithdrawExt, error) {
+	ctx, done := observe(ctx, "FindWithdrawsByUsername")
+	defer done()
 
-	return accrualV, nil
-}
-
-func FindWithdrawsByUsername(pgConn *PgxIface, username string) (*[]accrual.WithdrawExt, error) {
 	result := make([]accrual.WithdrawExt, 0)
-	rows, err := (*pgConn).Query(context.Background(),
-		"SELECT number, sum, processed_at FROM withdraws WHERE username=$1 AND sum > 0.01 ORDER BY processed_at ASC",
+	rows, err := (*pgConn).Query(ctx,
+		"SELECT number, sum, processed_at, status FROM withdraws WHERE username=$1 AND sum > 0.01"+
+			" ORDER BY processed_at ASC",
 		username)
 	if err != nil {
-		return &result, fmt.Errorf("failed to query: %w", err)
+		return &result, failed(ctx, fmt.Errorf("failed to query: %w", err))
 	}
 	defer rows.Close()
 	for rows.Next() {
-		var number string
+		var number, status string
 		var processedAt time.Time
 		var sum float32
-		err = rows.Scan(&number, &sum, &processedAt)
+		err = rows.Scan(&number, &sum, &processedAt, &status)
 		if err != nil {
-			return &result, fmt.Errorf("failed to scan a row: %w", err)
+			return &result, failed(ctx, fmt.Errorf("failed to scan a row: %w", err))
 		}
 
 		withdraw := accrual.WithdrawExt{
+			ID:          0,
 			Order:       number,
 			Sum:         sum,
 			ProcessedAt: processedAt,
+			Status:      status,
 			Username:    username,
 		}
 		result = append(result, withdraw)
@@ -291,3 +624,54 @@ func FindWithdrawsByUsername(pgConn *PgxIface, username string) (*[]accrual.With
 
 	return &result, nil
 }
+
+// CountOrdersByStatus returns count of orders grouped by their statuses.
+func CountOrdersByStatus(ctx context.Context, pgConn *PgxIface) (map[string]int64, error) {
+	ctx, done := observe(ctx, "CountOrdersByStatus")
+	defer done()
+
+	result := make(map[string]int64)
+	rows, err := (*pgConn).Query(ctx, "SELECT status, COUNT(*) FROM orders GROUP BY status")
+	if err != nil {
+		return result, failed(ctx, fmt.Errorf("failed to query: %w", err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status string
+		var count int64
+		if err = rows.Scan(&status, &count); err != nil {
+			return result, failed(ctx, fmt.Errorf("failed to scan a row: %w", err))
+		}
+		result[status] = count
+	}
+
+	return result, nil
+}
+
+// Ping checks the DB connection.
+func Ping(ctx context.Context, pgConn *PgxIface) error {
+	ctx, done := observe(ctx, "Ping")
+	defer done()
+
+	if err := (*pgConn).Ping(ctx); err != nil {
+		return failed(ctx, fmt.Errorf("failed to ping: %w", err))
+	}
+
+	return nil
+}
+
+// GetSchemaVersion returns the applied version of the DB schema
+// and true if the last migration has failed.
+func GetSchemaVersion(ctx context.Context, pgConn *PgxIface) (int64, bool, error) {
+	ctx, done := observe(ctx, "GetSchemaVersion")
+	defer done()
+
+	var version int64
+	var dirty bool
+	row := (*pgConn).QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1")
+	if err := row.Scan(&version, &dirty); err != nil {
+		return 0, false, failed(ctx, fmt.Errorf("failed to get schema version: %w", err))
+	}
+
+	return version, dirty, nil
+}
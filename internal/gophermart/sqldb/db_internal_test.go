@@ -11,10 +11,15 @@ import (
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/config"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
 	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/credential"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/pashagolub/pgxmock/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/zap"
 )
 
@@ -55,7 +60,7 @@ func TestCreateTables(t *testing.T) {
 		require.NoError(t, err)
 	}(mock, context.Background())
 	var pgConn PgxIface = mock
-	timeout := 10
+	timeout := 10 * time.Second
 
 	result := pgconn.NewCommandTag("CREATE TABLE")
 	mock.
@@ -80,7 +85,7 @@ func TestCreateTablesErr(t *testing.T) {
 		require.NoError(t, err)
 	}(mock, context.Background())
 	var pgConn PgxIface = mock
-	timeout := 10
+	timeout := 10 * time.Second
 
 	err = createTables(&pgConn, timeout)
 	assert.Error(t, err)
@@ -106,7 +111,7 @@ func TestFindOrderByNumberOk(t *testing.T) {
 		WillReturnRows(rows)
 
 	var pgConn PgxIface = mock
-	order, err := FindOrderByNumber(&pgConn, "79927398713")
+	order, err := FindOrderByNumber(context.Background(), &pgConn, "79927398713")
 	assert.NoError(t, err)
 	zap.S().Infoln("order:", order)
 	err = mock.ExpectationsWereMet()
@@ -119,7 +124,7 @@ func TestFindOrderByNumberOk(t *testing.T) {
 		"SELECT number, status, accrual, uploaded_at FROM orders WHERE username=\\$1").
 		WithArgs("user1").
 		WillReturnRows(rows2)
-	orders, err := FindOrdersByUsername(&pgConn, "user1")
+	orders, err := FindOrdersByUsername(context.Background(), &pgConn, "user1")
 	assert.NoError(t, err)
 	assert.NotNil(t, orders)
 	assert.Len(t, *orders, 1)
@@ -147,7 +152,7 @@ func TestFindOrderByNumberNoRowsErr(t *testing.T) {
 		WillReturnRows(rows)
 
 	var pgConn PgxIface = mock
-	order, err := FindOrderByNumber(&pgConn, "79927398713")
+	order, err := FindOrderByNumber(context.Background(), &pgConn, "79927398713")
 	assert.NoError(t, err)
 	assert.Nil(t, order)
 
@@ -174,7 +179,7 @@ func TestFindOrderByNumberScanErr(t *testing.T) {
 		WillReturnRows(rows)
 
 	var pgConn PgxIface = mock
-	order, err := FindOrderByNumber(&pgConn, "79927398713")
+	order, err := FindOrderByNumber(context.Background(), &pgConn, "79927398713")
 	assert.Error(t, err)
 	assert.Nil(t, order)
 
@@ -201,7 +206,7 @@ func TestFindOrdersByUsernameScanErr(t *testing.T) {
 		"SELECT number, status, accrual, uploaded_at FROM orders WHERE username=\\$1").
 		WithArgs("user1").
 		WillReturnRows(rows2)
-	orders, err := FindOrdersByUsername(&pgConn, "user1")
+	orders, err := FindOrdersByUsername(context.Background(), &pgConn, "user1")
 	assert.Error(t, err)
 	assert.NotNil(t, orders)
 	assert.Len(t, *orders, 0)
@@ -226,7 +231,7 @@ func TestFindOrdersByUsernameErr(t *testing.T) {
 		"SELECT number, status, accrual, uploaded_at FROM orders WHERE username=\\$1").
 		WithArgs("user1").
 		WillReturnError(io.EOF)
-	orders, err := FindOrdersByUsername(&pgConn, "user1")
+	orders, err := FindOrdersByUsername(context.Background(), &pgConn, "user1")
 	assert.Error(t, err)
 	assert.ErrorIs(t, err, io.EOF)
 	assert.NotNil(t, orders)
@@ -254,7 +259,7 @@ func TestFindUserByUsernameReturnsUser(t *testing.T) {
 		WillReturnRows(rs)
 
 	var pgConn PgxIface = mock
-	cred, err := FindUserByUsername(&pgConn, "user1")
+	cred, err := FindUserByUsername(context.Background(), &pgConn, "user1")
 	assert.NoError(t, err)
 	zap.S().Infoln("cred:", cred)
 
@@ -279,7 +284,7 @@ func TestFindUserByUsernameReturnsNil(t *testing.T) {
 		WillReturnRows(rs)
 
 	var pgConn PgxIface = mock
-	cred, err := FindUserByUsername(&pgConn, "user2")
+	cred, err := FindUserByUsername(context.Background(), &pgConn, "user2")
 	assert.NoError(t, err)
 	assert.Nil(t, cred)
 	zap.S().Infoln("cred:", cred)
@@ -303,7 +308,7 @@ func TestFindUserByUsernameReturnsErr(t *testing.T) {
 		WillReturnError(io.EOF)
 
 	var pgConn PgxIface = mock
-	cred, err := FindUserByUsername(&pgConn, "user2")
+	cred, err := FindUserByUsername(context.Background(), &pgConn, "user2")
 	assert.Error(t, err)
 	assert.Nil(t, cred)
 	assert.ErrorIs(t, err, io.EOF)
@@ -313,9 +318,7 @@ func TestFindUserByUsernameReturnsErr(t *testing.T) {
 	assert.NoError(t, err)
 }
 
-const testUsernameDebit = "login2"
-
-func TestGetDebitByUsernameReturnsErr(t *testing.T) {
+func TestAddCredentials(t *testing.T) {
 	mock, err := pgxmock.NewConn()
 	require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
 
@@ -325,23 +328,19 @@ func TestGetDebitByUsernameReturnsErr(t *testing.T) {
 		require.NoError(t, err)
 	}(mock, context.Background())
 
-	var want float32
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(accrual\\),0\\)").
-		WithArgs(testUsernameDebit).
-		WillReturnError(io.EOF)
+	mock.ExpectExec("insert into mart_users").
+		WithArgs("user1", "pass1").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
 	var pgConn PgxIface = mock
-	cred, err := GetDebitByUsername(&pgConn, testUsernameDebit)
-	assert.Error(t, err)
-	assert.Equal(t, want, cred)
-	assert.ErrorIs(t, err, io.EOF)
-	zap.S().Infoln("cred:", cred)
+	err = AddCredentials(context.Background(), &pgConn, credential.NewCredentials("user1", "pass1"))
+	assert.NoError(t, err)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestGetDebitByUsernameReturns0(t *testing.T) {
+func TestAddCredentialsErr(t *testing.T) {
 	mock, err := pgxmock.NewConn()
 	require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
 
@@ -351,48 +350,43 @@ func TestGetDebitByUsernameReturns0(t *testing.T) {
 		require.NoError(t, err)
 	}(mock, context.Background())
 
-	var want float32
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(accrual\\),0\\)").
-		WithArgs(testUsernameDebit).WillReturnRows(pgxmock.NewRows([]string{"accrual"}))
+	mock.ExpectExec("insert into mart_users").
+		WithArgs("user1", "pass1").
+		WillReturnError(io.EOF)
 
 	var pgConn PgxIface = mock
-	cred, err := GetDebitByUsername(&pgConn, testUsernameDebit)
-	assert.NoError(t, err)
-	assert.Equal(t, want, cred)
-	zap.S().Infoln("cred:", cred)
+	err = AddCredentials(context.Background(), &pgConn, credential.NewCredentials("user1", "pass1"))
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, io.EOF)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestGetDebitByUsernameReturns42(t *testing.T) {
+func TestAddOrder(t *testing.T) {
 	mock, err := pgxmock.NewConn()
 	require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
 
-	var want float32 = 42.0
-	rows := mock.NewRows([]string{"accrual"}).
-		AddRow(want)
-
 	defer func(mock pgxmock.PgxConnIface, ctx context.Context) {
 		mock.ExpectClose()
 		err = mock.Close(ctx)
 		require.NoError(t, err)
 	}(mock, context.Background())
-
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(accrual\\),0\\)").
-		WithArgs(testUsernameDebit).WillReturnRows(rows)
+	now := time.Now()
+	mock.ExpectExec("insert into orders").
+		WithArgs("79927398713", "NEW", float32(0), "user1", now).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
 	var pgConn PgxIface = mock
-	cred, err := GetDebitByUsername(&pgConn, testUsernameDebit)
+	order := accrual.NewOrderExt("79927398713", "NEW", float32(0), now, "user1")
+	err = AddOrder(context.Background(), &pgConn, order)
 	assert.NoError(t, err)
-	assert.Equal(t, want, cred)
-	zap.S().Infoln("cred:", cred)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestGetCreditByUsernameErr(t *testing.T) {
+func TestAddOrderErr(t *testing.T) {
 	mock, err := pgxmock.NewConn()
 	require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
 
@@ -401,20 +395,20 @@ func TestGetCreditByUsernameErr(t *testing.T) {
 		err = mock.Close(ctx)
 		require.NoError(t, err)
 	}(mock, context.Background())
-
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(sum\\),0\\)").
-		WithArgs(testUsernameDebit).WillReturnError(io.EOF)
+	now := time.Now()
+	mock.ExpectExec("insert into orders").
+		WithArgs("79927398713", "NEW", float32(0), "user1", now).WillReturnError(io.EOF)
 
 	var pgConn PgxIface = mock
-	_, err = GetCreditByUsername(&pgConn, testUsernameDebit)
+	order := accrual.NewOrderExt("79927398713", "NEW", float32(0), now, "user1")
+	err = AddOrder(context.Background(), &pgConn, order)
 	assert.Error(t, err)
-	assert.ErrorIs(t, err, io.EOF)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestGetCreditByUsername42(t *testing.T) {
+func TestUpdateOrder(t *testing.T) {
 	mock, err := pgxmock.NewConn()
 	require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
 
@@ -423,25 +417,42 @@ func TestGetCreditByUsername42(t *testing.T) {
 		err = mock.Close(ctx)
 		require.NoError(t, err)
 	}(mock, context.Background())
-
-	var want float32 = 42.0
-	rows := mock.NewRows([]string{"sum"}).
-		AddRow(want)
-
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(sum\\),0\\)").
-		WithArgs(testUsernameDebit).WillReturnRows(rows)
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE orders").
+		WithArgs("PROCESSED", float32(500), "79927398713").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\) FROM ledger_entries").
+		WithArgs("79927398713").
+		WillReturnRows(pgxmock.NewRows([]string{"amount"}).AddRow(float32(200)))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("ACCRUAL", "user1", "USER", "user1", float32(300), "ACCRUAL", "order", "79927398713", "").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectQuery("SELECT c.name, c.multiplier").WithArgs("79927398713").
+		WillReturnRows(pgxmock.NewRows([]string{"name", "multiplier", "credited"}))
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs("order.updated", "user1", pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE orders .* IS DISTINCT FROM").
+		WithArgs("PROCESSED", float32(500), "79927398713").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectCommit()
 
 	var pgConn PgxIface = mock
-	cred, err := GetCreditByUsername(&pgConn, testUsernameDebit)
-	assert.NoError(t, err)
-	assert.Equal(t, want, cred)
-	zap.S().Infoln("cred:", cred)
+	order := accrual.NewOrderExt("79927398713", "PROCESSED", float32(500), now, "user1")
+	err = UpdateOrder(context.Background(), &pgConn, order, nil)
+	assert.NoError(t, err, "the difference with the credited accrual is credited")
+
+	err = UpdateOrder(context.Background(), &pgConn, order, nil)
+	assert.NoError(t, err, "an unchanged order is not written to the outbox")
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestGetCreditByUsername0(t *testing.T) {
+func TestUpdateOrderErr(t *testing.T) {
 	mock, err := pgxmock.NewConn()
 	require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
 
@@ -450,22 +461,27 @@ func TestGetCreditByUsername0(t *testing.T) {
 		err = mock.Close(ctx)
 		require.NoError(t, err)
 	}(mock, context.Background())
-
-	var want float32
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(sum\\),0\\)").
-		WithArgs(testUsernameDebit).WillReturnRows(pgxmock.NewRows([]string{"sum"}))
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE orders").
+		WithArgs("NEW", float32(0), "79927398713").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs("order.updated", "user1", pgxmock.AnyArg()).
+		WillReturnError(io.EOF)
+	mock.ExpectRollback()
 
 	var pgConn PgxIface = mock
-	cred, err := GetCreditByUsername(&pgConn, testUsernameDebit)
-	assert.NoError(t, err)
-	assert.Equal(t, want, cred)
-	zap.S().Infoln("cred:", cred)
+	order := accrual.NewOrderExt("79927398713", "NEW", float32(0), now, "user1")
+	err = UpdateOrder(context.Background(), &pgConn, order, nil)
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, io.EOF)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestAddCredentials(t *testing.T) {
+func TestAddWithdraw(t *testing.T) {
 	mock, err := pgxmock.NewConn()
 	require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
 
@@ -474,20 +490,33 @@ func TestAddCredentials(t *testing.T) {
 		err = mock.Close(ctx)
 		require.NoError(t, err)
 	}(mock, context.Background())
-
-	mock.ExpectExec("insert into mart_users").
-		WithArgs("user1", "pass1").
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT 1 FROM mart_users WHERE name = \\$1 FOR UPDATE").WithArgs("user1").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\)").WithArgs("user1").
+		WillReturnRows(pgxmock.NewRows([]string{"current", "withdrawn"}).AddRow(float32(42), float32(0)))
+	mock.ExpectQuery("insert into withdraws").
+		WithArgs("79927398713", float32(10), "user1", now).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int64(7)))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("USER", "user1", "WITHDRAWALS", "user1", float32(10), "WITHDRAWAL", "withdraw", "7", "").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs("withdrawal.created", "user1", pgxmock.AnyArg()).
 		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
 
 	var pgConn PgxIface = mock
-	err = AddCredentials(&pgConn, credential.NewCredentials("user1", "pass1"))
+	withdraw := accrual.NewWithdrawExt("79927398713", float32(10), now, "user1")
+	err = AddWithdraw(context.Background(), &pgConn, *withdraw)
 	assert.NoError(t, err)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestAddCredentialsErr(t *testing.T) {
+func TestAddWithdrawErr(t *testing.T) {
 	mock, err := pgxmock.NewConn()
 	require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
 
@@ -496,13 +525,20 @@ func TestAddCredentialsErr(t *testing.T) {
 		err = mock.Close(ctx)
 		require.NoError(t, err)
 	}(mock, context.Background())
-
-	mock.ExpectExec("insert into mart_users").
-		WithArgs("user1", "pass1").
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT 1 FROM mart_users WHERE name = \\$1 FOR UPDATE").WithArgs("user1").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\)").WithArgs("user1").
+		WillReturnRows(pgxmock.NewRows([]string{"current", "withdrawn"}).AddRow(float32(42), float32(0)))
+	mock.ExpectQuery("insert into withdraws").
+		WithArgs("79927398713", float32(0), "user1", now).
 		WillReturnError(io.EOF)
+	mock.ExpectRollback()
 
 	var pgConn PgxIface = mock
-	err = AddCredentials(&pgConn, credential.NewCredentials("user1", "pass1"))
+	withdraw := accrual.NewWithdrawExt("79927398713", float32(0), now, "user1")
+	err = AddWithdraw(context.Background(), &pgConn, *withdraw)
 	assert.Error(t, err)
 	assert.ErrorIs(t, err, io.EOF)
 
@@ -510,7 +546,7 @@ func TestAddCredentialsErr(t *testing.T) {
 	assert.NoError(t, err)
 }
 
-func TestAddOrder(t *testing.T) {
+func TestAddWithdrawNoMoney(t *testing.T) {
 	mock, err := pgxmock.NewConn()
 	require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
 
@@ -519,21 +555,23 @@ func TestAddOrder(t *testing.T) {
 		err = mock.Close(ctx)
 		require.NoError(t, err)
 	}(mock, context.Background())
-	now := time.Now()
-	mock.ExpectExec("insert into orders").
-		WithArgs("79927398713", "NEW", float32(0), "user1", now).
-		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT 1 FROM mart_users WHERE name = \\$1 FOR UPDATE").WithArgs("user1").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\)").WithArgs("user1").
+		WillReturnRows(pgxmock.NewRows([]string{"current", "withdrawn"}).AddRow(float32(42), float32(0)))
+	mock.ExpectRollback()
 
 	var pgConn PgxIface = mock
-	order := accrual.NewOrderExt("79927398713", "NEW", float32(0), now, "user1")
-	err = AddOrder(&pgConn, order)
-	assert.NoError(t, err)
+	withdraw := accrual.NewWithdrawExt("79927398713", float32(43), time.Now(), "user1")
+	err = AddWithdraw(context.Background(), &pgConn, *withdraw)
+	assert.ErrorIs(t, err, ErrNoMoney)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestAddOrderErr(t *testing.T) {
+func TestFindWithdrawsByUsernameOk(t *testing.T) {
 	mock, err := pgxmock.NewConn()
 	require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
 
@@ -543,19 +581,27 @@ func TestAddOrderErr(t *testing.T) {
 		require.NoError(t, err)
 	}(mock, context.Background())
 	now := time.Now()
-	mock.ExpectExec("insert into orders").
-		WithArgs("79927398713", "NEW", float32(0), "user1", now).WillReturnError(io.EOF)
 
 	var pgConn PgxIface = mock
-	order := accrual.NewOrderExt("79927398713", "NEW", float32(0), now, "user1")
-	err = AddOrder(&pgConn, order)
-	assert.Error(t, err)
+
+	rows2 := pgxmock.NewRows([]string{"number", "sum", "processed_at", "status"}).
+		AddRow("79927398713", float32(0), now, "COMPLETED")
+
+	mock.ExpectQuery(
+		"SELECT number, sum, processed_at, status FROM withdraws WHERE username=\\$1").
+		WithArgs("user1").
+		WillReturnRows(rows2)
+	withdraws, err := FindWithdrawsByUsername(context.Background(), &pgConn, "user1")
+	assert.NoError(t, err)
+	assert.NotNil(t, withdraws)
+	assert.Len(t, *withdraws, 1)
+	zap.S().Infoln("withdraws:", withdraws)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestUpdateOrder(t *testing.T) {
+func TestFindWithdrawsByUsernameErrScan(t *testing.T) {
 	mock, err := pgxmock.NewConn()
 	require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
 
@@ -564,21 +610,27 @@ func TestUpdateOrder(t *testing.T) {
 		err = mock.Close(ctx)
 		require.NoError(t, err)
 	}(mock, context.Background())
-	now := time.Now()
-	mock.ExpectExec("UPDATE orders").
-		WithArgs("NEW", float32(0), "79927398713").
-		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
 
 	var pgConn PgxIface = mock
-	order := accrual.NewOrderExt("79927398713", "NEW", float32(0), now, "user1")
-	err = UpdateOrder(&pgConn, order)
-	assert.NoError(t, err)
+
+	rows2 := pgxmock.NewRows([]string{"number", "sum", "processed_at", "status"}).
+		AddRow("79927398713", float32(0), pgxmock.AnyArg(), "COMPLETED")
+
+	mock.ExpectQuery(
+		"SELECT number, sum, processed_at, status FROM withdraws WHERE username=\\$1").
+		WithArgs("user1").
+		WillReturnRows(rows2)
+	withdraws, err := FindWithdrawsByUsername(context.Background(), &pgConn, "user1")
+	assert.Error(t, err)
+	assert.NotNil(t, withdraws)
+	assert.Len(t, *withdraws, 0)
+	zap.S().Infoln("withdraws:", withdraws)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestUpdateOrderErr(t *testing.T) {
+func TestFindWithdrawsByUsernameErr(t *testing.T) {
 	mock, err := pgxmock.NewConn()
 	require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
 
@@ -587,102 +639,108 @@ func TestUpdateOrderErr(t *testing.T) {
 		err = mock.Close(ctx)
 		require.NoError(t, err)
 	}(mock, context.Background())
-	now := time.Now()
-	mock.ExpectExec("UPDATE orders").
-		WithArgs("NEW", float32(0), "79927398713").
-		WillReturnError(io.EOF)
 
 	var pgConn PgxIface = mock
-	order := accrual.NewOrderExt("79927398713", "NEW", float32(0), now, "user1")
-	err = UpdateOrder(&pgConn, order)
+
+	mock.ExpectQuery(
+		"SELECT number, sum, processed_at, status FROM withdraws WHERE username=\\$1").
+		WithArgs("user1").
+		WillReturnError(io.EOF)
+	withdraws, err := FindWithdrawsByUsername(context.Background(), &pgConn, "user1")
 	assert.Error(t, err)
-	assert.ErrorIs(t, err, io.EOF)
+	assert.NotNil(t, withdraws)
+	assert.Len(t, *withdraws, 0)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestAddWithdraw(t *testing.T) {
+func TestCountOrdersByStatus(t *testing.T) {
 	mock, err := pgxmock.NewConn()
 	require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
-
 	defer func(mock pgxmock.PgxConnIface, ctx context.Context) {
 		mock.ExpectClose()
 		err = mock.Close(ctx)
 		require.NoError(t, err)
 	}(mock, context.Background())
-	now := time.Now()
-	mock.ExpectExec("insert into withdraws").
-		WithArgs("79927398713", float32(0), "user1", now).
-		WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
 	var pgConn PgxIface = mock
-	withdraw := accrual.NewWithdrawExt("79927398713", float32(0), now, "user1")
-	err = AddWithdraw(&pgConn, *withdraw)
+
+	rows := pgxmock.NewRows([]string{"status", "count"}).
+		AddRow("NEW", int64(2)).
+		AddRow("PROCESSED", int64(1))
+	mock.ExpectQuery("SELECT status, COUNT\\(\\*\\) FROM orders GROUP BY status").
+		WillReturnRows(rows)
+
+	got, err := CountOrdersByStatus(context.Background(), &pgConn)
 	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"NEW": 2, "PROCESSED": 1}, got)
+
+	mock.ExpectQuery("SELECT status, COUNT\\(\\*\\) FROM orders GROUP BY status").
+		WillReturnError(io.EOF)
+	_, err = CountOrdersByStatus(context.Background(), &pgConn)
+	assert.ErrorIs(t, err, io.EOF)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestAddWithdrawErr(t *testing.T) {
-	mock, err := pgxmock.NewConn()
+func TestPing(t *testing.T) {
+	mock, err := pgxmock.NewConn(pgxmock.MonitorPingsOption(true))
 	require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
-
 	defer func(mock pgxmock.PgxConnIface, ctx context.Context) {
 		mock.ExpectClose()
 		err = mock.Close(ctx)
 		require.NoError(t, err)
 	}(mock, context.Background())
-	now := time.Now()
-	mock.ExpectExec("insert into withdraws").
-		WithArgs("79927398713", float32(0), "user1", now).
-		WillReturnError(io.EOF)
 
 	var pgConn PgxIface = mock
-	withdraw := accrual.NewWithdrawExt("79927398713", float32(0), now, "user1")
-	err = AddWithdraw(&pgConn, *withdraw)
-	assert.Error(t, err)
-	assert.ErrorIs(t, err, io.EOF)
+
+	mock.ExpectPing()
+	assert.NoError(t, Ping(context.Background(), &pgConn))
+
+	mock.ExpectPing().WillReturnError(io.EOF)
+	assert.ErrorIs(t, Ping(context.Background(), &pgConn), io.EOF)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestFindWithdrawsByUsernameOk(t *testing.T) {
+func TestGetSchemaVersion(t *testing.T) {
 	mock, err := pgxmock.NewConn()
 	require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
-
 	defer func(mock pgxmock.PgxConnIface, ctx context.Context) {
 		mock.ExpectClose()
 		err = mock.Close(ctx)
 		require.NoError(t, err)
 	}(mock, context.Background())
-	now := time.Now()
 
 	var pgConn PgxIface = mock
 
-	rows2 := pgxmock.NewRows([]string{"number", "sum", "processed_at"}).
-		AddRow("79927398713", float32(0), now)
-
-	mock.ExpectQuery(
-		"SELECT number, sum, processed_at FROM withdraws WHERE username=\\$1").
-		WithArgs("user1").
-		WillReturnRows(rows2)
-	withdraws, err := FindWithdrawsByUsername(&pgConn, "user1")
+	mock.ExpectQuery("SELECT version, dirty FROM schema_migrations").
+		WillReturnRows(pgxmock.NewRows([]string{"version", "dirty"}).AddRow(int64(SchemaVersion), false))
+	version, dirty, err := GetSchemaVersion(context.Background(), &pgConn)
 	assert.NoError(t, err)
-	assert.NotNil(t, withdraws)
-	assert.Len(t, *withdraws, 1)
-	zap.S().Infoln("withdraws:", withdraws)
+	assert.Equal(t, int64(SchemaVersion), version)
+	assert.False(t, dirty)
+
+	mock.ExpectQuery("SELECT version, dirty FROM schema_migrations").
+		WillReturnError(io.EOF)
+	_, _, err = GetSchemaVersion(context.Background(), &pgConn)
+	assert.ErrorIs(t, err, io.EOF)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestFindWithdrawsByUsernameErrScan(t *testing.T) {
+func TestQuerySpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	original := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(original) })
+
 	mock, err := pgxmock.NewConn()
 	require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
-
 	defer func(mock pgxmock.PgxConnIface, ctx context.Context) {
 		mock.ExpectClose()
 		err = mock.Close(ctx)
@@ -691,44 +749,57 @@ func TestFindWithdrawsByUsernameErrScan(t *testing.T) {
 
 	var pgConn PgxIface = mock
 
-	rows2 := pgxmock.NewRows([]string{"number", "sum", "processed_at"}).
-		AddRow("79927398713", float32(0), pgxmock.AnyArg())
-
-	mock.ExpectQuery(
-		"SELECT number, sum, processed_at FROM withdraws WHERE username=\\$1").
-		WithArgs("user1").
-		WillReturnRows(rows2)
-	withdraws, err := FindWithdrawsByUsername(&pgConn, "user1")
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE orders SET status").
+		WithArgs("NEW", float32(0), "1").
+		WillReturnError(io.EOF)
+	mock.ExpectRollback()
+	err = UpdateOrder(context.Background(), &pgConn, accrual.NewOrderExt("1", "NEW", 0, time.Now(), "user1"), nil)
 	assert.Error(t, err)
-	assert.NotNil(t, withdraws)
-	assert.Len(t, *withdraws, 0)
-	zap.S().Infoln("withdraws:", withdraws)
 
-	err = mock.ExpectationsWereMet()
-	assert.NoError(t, err)
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "sqldb.UpdateOrder", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
 }
 
-func TestFindWithdrawsByUsernameErr(t *testing.T) {
+func TestTakeRateLimitToken(t *testing.T) {
 	mock, err := pgxmock.NewConn()
-	require.NoError(t, err, fmt.Sprintf("an error '%s' was not expected when opening a stub database connection", err))
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
 
-	defer func(mock pgxmock.PgxConnIface, ctx context.Context) {
-		mock.ExpectClose()
-		err = mock.Close(ctx)
-		require.NoError(t, err)
-	}(mock, context.Background())
+	mock.ExpectQuery("INSERT INTO rate_limits").
+		WithArgs("POST /api/user/login|ip:192.0.2.1", 1.0, 10).
+		WillReturnRows(pgxmock.NewRows([]string{"tokens", "allowed"}).AddRow(9.0, true))
+	mock.ExpectQuery("INSERT INTO rate_limits").
+		WithArgs("POST /api/user/login|ip:192.0.2.1", 1.0, 10).
+		WillReturnError(pgx.ErrTxClosed)
+
+	allowed, tokens, err := TakeRateLimitToken(context.Background(), &pgConn, "POST /api/user/login|ip:192.0.2.1", 1, 10)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, 9.0, tokens)
+
+	_, _, err = TakeRateLimitToken(context.Background(), &pgConn, "POST /api/user/login|ip:192.0.2.1", 1, 10)
+	assert.ErrorIs(t, err, pgx.ErrTxClosed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
+func TestDeleteRefilledRateLimits(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
 	var pgConn PgxIface = mock
 
-	mock.ExpectQuery(
-		"SELECT number, sum, processed_at FROM withdraws WHERE username=\\$1").
-		WithArgs("user1").
-		WillReturnError(io.EOF)
-	withdraws, err := FindWithdrawsByUsername(&pgConn, "user1")
-	assert.Error(t, err)
-	assert.NotNil(t, withdraws)
-	assert.Len(t, *withdraws, 0)
+	mock.ExpectExec("DELETE FROM rate_limits WHERE full_at").
+		WillReturnResult(pgconn.NewCommandTag("DELETE 3"))
+	mock.ExpectExec("DELETE FROM rate_limits WHERE full_at").
+		WillReturnError(pgx.ErrTxClosed)
 
-	err = mock.ExpectationsWereMet()
-	assert.NoError(t, err)
+	deleted, err := DeleteRefilledRateLimits(context.Background(), &pgConn)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+
+	_, err = DeleteRefilledRateLimits(context.Background(), &pgConn)
+	assert.ErrorIs(t, err, pgx.ErrTxClosed)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -0,0 +1,145 @@
+package sqldb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/jackc/pgx/v5"
+)
+
+const ledgerSourceExpiry = "expiry"
+
+// ledgerLotsSQL returns the credits of the user account of $1 which are not spent yet
+// with the time they expire in $2 seconds after they are credited.
+// The debits spend the oldest credits first, so a credit is spent by the part of the debits
+// which exceeds the credits before it; less than half a hundredth left is a float leftover, not a lot.
+const ledgerLotsSQL = `
+WITH entries AS (
+    SELECT id, amount, created_at,
+           SUM(amount) FILTER (WHERE amount > 0) OVER (ORDER BY id) AS credited,
+           COALESCE(-SUM(amount) FILTER (WHERE amount < 0) OVER (), 0) AS debited
+    FROM ledger_entries
+    WHERE account = 'USER' AND username = $1
+), lots AS (
+    SELECT created_at + make_interval(secs => $2::DOUBLE PRECISION) AS expires_at,
+           LEAST(amount, credited - debited) AS remaining
+    FROM entries
+    WHERE amount > 0 AND credited - debited >= 0.005
+)`
+
+// expiryCandidatesSQL returns up to $2 users who have credits made before $1 which are not checked yet,
+// points_expired_at of a user is the time the credits are checked until by the last expiry.
+const expiryCandidatesSQL = `
+SELECT u.name FROM mart_users u
+WHERE EXISTS (
+    SELECT 1 FROM ledger_entries l
+    WHERE l.account = 'USER' AND l.username = u.name AND l.amount > 0
+      AND l.created_at <= $1 AND l.created_at > COALESCE(u.points_expired_at, '-infinity')
+)
+ORDER BY u.name
+LIMIT $2`
+
+// FindUsersWithExpiredPoints returns up to limit users who have points credited more than expireAfter before now
+// since their last expiry, the points may be spent already.
+func FindUsersWithExpiredPoints(
+	ctx context.Context, pgConn *PgxIface, expireAfter time.Duration, now time.Time, limit int,
+) ([]string, error) {
+	ctx, done := observe(ctx, "FindUsersWithExpiredPoints")
+	defer done()
+
+	result := make([]string, 0)
+	rows, err := (*pgConn).Query(ctx, expiryCandidatesSQL, now.Add(-expireAfter), limit)
+	if err != nil {
+		return result, failed(ctx, fmt.Errorf("failed to query: %w", err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var username string
+		if err = rows.Scan(&username); err != nil {
+			return result, failed(ctx, fmt.Errorf("failed to scan a row: %w", err))
+		}
+		result = append(result, username)
+	}
+	if err = rows.Err(); err != nil {
+		return result, failed(ctx, fmt.Errorf("failed to read rows: %w", err))
+	}
+
+	return result, nil
+}
+
+// ExpirePoints moves the points of the user credited more than expireAfter before now and not spent yet
+// to the expired account and marks the credits as checked. The balance of the user is locked for the transaction,
+// so the points are expired once. It returns the expired amount rounded to hundredths.
+func ExpirePoints(
+	ctx context.Context, pgConn *PgxIface, username string, expireAfter time.Duration, now time.Time,
+) (float32, error) {
+	ctx, done := observe(ctx, "ExpirePoints")
+	defer done()
+
+	var expired float32
+	err := inTx(ctx, pgConn, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx,
+			"UPDATE mart_users SET points_expired_at = GREATEST(points_expired_at, $2) WHERE name = $1",
+			username, now.Add(-expireAfter))
+		if err != nil {
+			return fmt.Errorf("failed to lock the user: %w", err)
+		}
+		err = tx.QueryRow(ctx,
+			ledgerLotsSQL+" SELECT COALESCE(SUM(remaining), 0) FROM lots WHERE expires_at <= $3",
+			username, expireAfter.Seconds(), now).Scan(&expired)
+		if err != nil {
+			return fmt.Errorf("failed to get expired points: %w", err)
+		}
+		if expired = roundPoints(expired); expired <= 0 {
+			return nil
+		}
+
+		return insertLedger(ctx, tx, ledgerTransaction{
+			From:        userAccount(username),
+			To:          ledgerAccount{Account: accrual.LedgerAccountExpired, Username: username},
+			Amount:      expired,
+			Kind:        accrual.LedgerKindExpiry,
+			Source:      ledgerSourceExpiry,
+			SourceID:    "",
+			Description: "",
+		})
+	})
+	if err != nil {
+		return 0, failed(ctx, err)
+	}
+
+	return expired, nil
+}
+
+// FindExpiringPoints returns the points of the user which expire until the time by the days they expire.
+func FindExpiringPoints(
+	ctx context.Context, pgConn *PgxIface, username string, expireAfter time.Duration, until time.Time,
+) ([]accrual.ExpiringPoints, error) {
+	ctx, done := observe(ctx, "FindExpiringPoints")
+	defer done()
+
+	result := make([]accrual.ExpiringPoints, 0)
+	rows, err := (*pgConn).Query(ctx,
+		ledgerLotsSQL+" SELECT SUM(remaining), date_trunc('day', expires_at) AS day FROM lots"+
+			" WHERE expires_at <= $3 GROUP BY day ORDER BY day",
+		username, expireAfter.Seconds(), until)
+	if err != nil {
+		return result, failed(ctx, fmt.Errorf("failed to query: %w", err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var points accrual.ExpiringPoints
+		if err = rows.Scan(&points.Amount, &points.Date); err != nil {
+			return result, failed(ctx, fmt.Errorf("failed to scan a row: %w", err))
+		}
+		points.Amount = roundPoints(points.Amount)
+		result = append(result, points)
+	}
+	if err = rows.Err(); err != nil {
+		return result, failed(ctx, fmt.Errorf("failed to read rows: %w", err))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,87 @@
+package sqldb
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testExpireAfter = 365 * 24 * time.Hour
+
+func TestFindUsersWithExpiredPoints(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT u.name FROM mart_users u WHERE EXISTS").
+		WithArgs(now.Add(-testExpireAfter), 10).
+		WillReturnRows(pgxmock.NewRows([]string{"username"}).AddRow("user1").AddRow("user2"))
+
+	usernames, err := FindUsersWithExpiredPoints(context.Background(), &pgConn, testExpireAfter, now, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user1", "user2"}, usernames)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExpirePoints(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	now := time.Now()
+	expectExpired := func(expired float32) {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE mart_users SET points_expired_at").WithArgs("user1", now.Add(-testExpireAfter)).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		mock.ExpectQuery("WITH entries AS .* SELECT COALESCE\\(SUM\\(remaining\\), 0\\) FROM lots").
+			WithArgs("user1", testExpireAfter.Seconds(), now).
+			WillReturnRows(pgxmock.NewRows([]string{"expired"}).AddRow(expired))
+	}
+	expectExpired(30.004)
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("USER", "user1", "EXPIRED", "user1", float32(30), "EXPIRY", "expiry", "", "").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectCommit()
+	expectExpired(0.004)
+	mock.ExpectCommit()
+
+	expired, err := ExpirePoints(context.Background(), &pgConn, "user1", testExpireAfter, now)
+	require.NoError(t, err)
+	assert.Equal(t, float32(30), expired)
+
+	expired, err = ExpirePoints(context.Background(), &pgConn, "user1", testExpireAfter, now)
+	require.NoError(t, err)
+	assert.Zero(t, expired, "the expired points are not expired again, a float leftover is not expired")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindExpiringPoints(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	until := time.Now().Add(30 * 24 * time.Hour)
+	day := time.Date(2023, 11, 14, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("WITH entries AS .* GROUP BY day ORDER BY day").
+		WithArgs("user1", testExpireAfter.Seconds(), until).
+		WillReturnRows(pgxmock.NewRows([]string{"sum", "day"}).AddRow(float32(12.5), day))
+	mock.ExpectQuery("WITH entries AS .* GROUP BY day ORDER BY day").
+		WithArgs("user1", testExpireAfter.Seconds(), until).
+		WillReturnError(io.EOF)
+
+	points, err := FindExpiringPoints(context.Background(), &pgConn, "user1", testExpireAfter, until)
+	require.NoError(t, err)
+	require.Len(t, points, 1)
+	assert.Equal(t, float32(12.5), points[0].Amount)
+	assert.Equal(t, day, points[0].Date)
+
+	_, err = FindExpiringPoints(context.Background(), &pgConn, "user1", testExpireAfter, until)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,315 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/jackc/pgx/v5"
+)
+
+// Sources of the ledger transactions, a transaction refers to its source by source and source_id.
+const (
+	ledgerSourceOrder      = "order"
+	ledgerSourceWithdraw   = "withdraw"
+	ledgerSourceAdjustment = "adjustment"
+)
+
+// insertLedgerSQL writes both entries of a transaction: $5 is moved from the account $1 of $2
+// to the account $3 of $4. An empty source id ($8) refers the transaction to itself.
+const insertLedgerSQL = `
+WITH t AS (SELECT nextval('ledger_transactions_seq') AS id)
+INSERT INTO ledger_entries (transaction_id, account, username, amount, kind, source, source_id, description)
+SELECT t.id, e.account, e.username, e.amount, $6, $7, COALESCE(NULLIF($8, ''), t.id::TEXT), $9
+FROM t, (VALUES
+    ($1::VARCHAR, $2::VARCHAR, -$5::DOUBLE PRECISION),
+    ($3::VARCHAR, $4::VARCHAR, $5::DOUBLE PRECISION)
+) AS e (account, username, amount)`
+
+// getBalanceSQL sums the entries of the user account,
+// the withdrawn sum is the withdrawals which are not cancelled or refunded.
+const getBalanceSQL = `
+SELECT COALESCE(SUM(amount), 0),
+       COALESCE(-SUM(amount) FILTER (WHERE kind IN ('WITHDRAWAL', 'WITHDRAWAL_CANCEL', 'WITHDRAWAL_REFUND')), 0)
+FROM ledger_entries
+WHERE account = 'USER' AND username = $1`
+
+// findTransactionsSQL returns up to $3 entries of the user account $1 after the entry $2
+// in chronological order with the balance after each of them, the order of the withdrawals
+// and the other user of the transfers and the referral bonuses.
+const findTransactionsSQL = `
+WITH entries AS (
+    SELECT id, transaction_id, kind, amount, source, source_id, description, created_at,
+           SUM(amount) OVER (ORDER BY id) AS balance
+    FROM ledger_entries
+    WHERE account = 'USER' AND username = $1
+)
+SELECT e.id, e.kind, e.amount, e.balance,
+       CASE WHEN e.source = 'order' THEN e.source_id ELSE COALESCE(w.number, '') END,
+       CASE WHEN e.source = 'referral' THEN e.source_id ELSE COALESCE(c.username, '') END,
+       e.description, e.created_at
+FROM entries e
+LEFT JOIN withdraws w ON e.source = 'withdraw' AND w.id::TEXT = e.source_id
+LEFT JOIN ledger_entries c ON e.kind = 'TRANSFER' AND c.transaction_id = e.transaction_id AND c.id <> e.id
+WHERE e.id > $2
+ORDER BY e.id
+LIMIT $3`
+
+// ledgerAccount is an account of the ledger, every user has an account of each kind.
+type ledgerAccount struct {
+	Account  string
+	Username string
+}
+
+// ledgerTransaction moves Amount from the account From to the account To.
+type ledgerTransaction struct {
+	From        ledgerAccount
+	To          ledgerAccount
+	Amount      float32
+	Kind        string
+	Source      string
+	SourceID    string
+	Description string
+}
+
+func userAccount(username string) ledgerAccount {
+	return ledgerAccount{Account: accrual.LedgerAccountUser, Username: username}
+}
+
+// insertLedger writes the transaction as two entries which sum to zero.
+func insertLedger(ctx context.Context, tx pgx.Tx, transaction ledgerTransaction) error {
+	_, err := tx.Exec(ctx, insertLedgerSQL,
+		transaction.From.Account, transaction.From.Username, transaction.To.Account, transaction.To.Username,
+		transaction.Amount, transaction.Kind, transaction.Source, transaction.SourceID, transaction.Description)
+	if err != nil {
+		return fmt.Errorf("failed to insert into ledger_entries: %w", err)
+	}
+
+	return nil
+}
+
+// roundPoints rounds the points to hundredths as the balances are kept in them.
+func roundPoints(points float32) float32 {
+	return float32(math.Round(float64(points)*100) / 100) //nolint:gomnd
+}
+
+// creditAccrual credits the difference between the accrual of the order and the sum credited for it before,
+// so a corrected accrual is credited once.
+func creditAccrual(ctx context.Context, tx pgx.Tx, order *accrual.OrderExt) error {
+	var credited float32
+	err := tx.QueryRow(ctx,
+		"SELECT COALESCE(SUM(amount), 0) FROM ledger_entries"+
+			" WHERE account = 'USER' AND kind = 'ACCRUAL' AND source = 'order' AND source_id = $1",
+		order.Number).Scan(&credited)
+	if err != nil {
+		return fmt.Errorf("failed to get credited accrual: %w", err)
+	}
+	amount := order.Accrual - credited
+	if amount == 0 {
+		return nil
+	}
+
+	return insertLedger(ctx, tx, ledgerTransaction{
+		From:        ledgerAccount{Account: accrual.LedgerAccountAccrual, Username: order.Username},
+		To:          userAccount(order.Username),
+		Amount:      amount,
+		Kind:        accrual.LedgerKindAccrual,
+		Source:      ledgerSourceOrder,
+		SourceID:    order.Number,
+		Description: "",
+	})
+}
+
+// tierBonusSQL returns the lifetime accrual of the user $1 without the order $2
+// and the tier bonus credited for the order before.
+const tierBonusSQL = `
+SELECT COALESCE((SELECT SUM(accrual) FROM orders WHERE username = $1 AND status = 'PROCESSED' AND number <> $2), 0),
+       COALESCE((SELECT SUM(amount) FROM ledger_entries
+                 WHERE account = 'USER' AND kind = 'TIER_BONUS' AND source = 'order' AND source_id = $2), 0)`
+
+// OrderBonuses are the bonuses credited with the accrual of a processed order.
+type OrderBonuses struct {
+	// Multiplier returns the multiplier of the tier of the lifetime accrual, nil credits no tier bonus.
+	Multiplier func(lifetime float32) float32
+	// ReferralBonus is credited to the referrer on the first processed order of the user, zero disables it;
+	// ReferralMaxRewards limits the rewarded referrals of a referrer, zero is unlimited.
+	ReferralBonus      float32
+	ReferralMaxRewards int
+}
+
+// creditOrderBonuses credits the bonuses of the processed order after its accrual.
+func creditOrderBonuses(ctx context.Context, tx pgx.Tx, order *accrual.OrderExt, bonuses *OrderBonuses) error {
+	if bonuses != nil && bonuses.Multiplier != nil {
+		if err := creditTierBonus(ctx, tx, order, bonuses.Multiplier); err != nil {
+			return err
+		}
+	}
+	if err := creditCampaignBonuses(ctx, tx, order); err != nil {
+		return err
+	}
+	if bonuses != nil && bonuses.ReferralBonus > 0 {
+		return rewardReferral(ctx, tx, order.Username, bonuses.ReferralBonus, bonuses.ReferralMaxRewards)
+	}
+
+	return nil
+}
+
+// creditTierBonus credits the accrual of the order multiplied by the multiplier of the tier of the user
+// less the accrual itself; the tier is of the lifetime accrual of the other orders of the user.
+// Like the accrual, the difference with the bonus credited for the order before is credited.
+func creditTierBonus(
+	ctx context.Context, tx pgx.Tx, order *accrual.OrderExt, multiplier func(lifetime float32) float32,
+) error {
+	var lifetime, credited float32
+	if err := tx.QueryRow(ctx, tierBonusSQL, order.Username, order.Number).Scan(&lifetime, &credited); err != nil {
+		return fmt.Errorf("failed to get tier bonus: %w", err)
+	}
+	amount := roundPoints(order.Accrual*(multiplier(lifetime)-1)) - credited
+	if amount == 0 {
+		return nil
+	}
+
+	return insertLedger(ctx, tx, ledgerTransaction{
+		From:        ledgerAccount{Account: accrual.LedgerAccountBonuses, Username: order.Username},
+		To:          userAccount(order.Username),
+		Amount:      amount,
+		Kind:        accrual.LedgerKindTierBonus,
+		Source:      ledgerSourceOrder,
+		SourceID:    order.Number,
+		Description: "",
+	})
+}
+
+// GetLifetimeAccrual returns the sum of the accrual of the processed orders of the user.
+func GetLifetimeAccrual(ctx context.Context, pgConn *PgxIface, username string) (float32, error) {
+	ctx, done := observe(ctx, "GetLifetimeAccrual")
+	defer done()
+
+	var lifetime float32
+	err := (*pgConn).QueryRow(ctx,
+		"SELECT COALESCE(SUM(accrual), 0) FROM orders WHERE username = $1 AND status = 'PROCESSED'",
+		username).Scan(&lifetime)
+	if err != nil {
+		return 0, failed(ctx, fmt.Errorf("failed to get lifetime accrual: %w", err))
+	}
+
+	return lifetime, nil
+}
+
+// withdrawLedger returns the transaction of the withdrawal of the kind:
+// the withdrawal moves the sum from the user account, the cancellation and the refund return it.
+func withdrawLedger(withdraw *accrual.WithdrawExt, kind string, description string) ledgerTransaction {
+	user := userAccount(withdraw.Username)
+	withdrawals := ledgerAccount{Account: accrual.LedgerAccountWithdrawals, Username: withdraw.Username}
+	transaction := ledgerTransaction{
+		From:        withdrawals,
+		To:          user,
+		Amount:      withdraw.Sum,
+		Kind:        kind,
+		Source:      ledgerSourceWithdraw,
+		SourceID:    strconv.FormatInt(withdraw.ID, 10),
+		Description: description,
+	}
+	if kind == accrual.LedgerKindWithdrawal {
+		transaction.From, transaction.To = user, withdrawals
+	}
+
+	return transaction
+}
+
+// GetBalanceByUsername returns the current balance of the user and the withdrawn sum.
+func GetBalanceByUsername(ctx context.Context, pgConn *PgxIface, username string) (float32, float32, error) {
+	ctx, done := observe(ctx, "GetBalanceByUsername")
+	defer done()
+
+	var current, withdrawn float32
+	err := (*pgConn).QueryRow(ctx, getBalanceSQL, username).Scan(&current, &withdrawn)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, 0, nil
+		}
+
+		return 0, 0, failed(ctx, fmt.Errorf("failed to get balance: %w", err))
+	}
+
+	return current, withdrawn, nil
+}
+
+// lockBalance locks the user for the transaction and returns the current balance of the user,
+// so the points may not be spent by a concurrent transaction until it is done.
+func lockBalance(ctx context.Context, tx pgx.Tx, username string) (float32, error) {
+	if _, err := tx.Exec(ctx, "SELECT 1 FROM mart_users WHERE name = $1 FOR UPDATE", username); err != nil {
+		return 0, fmt.Errorf("failed to lock the user: %w", err)
+	}
+	var current, withdrawn float32
+	if err := tx.QueryRow(ctx, getBalanceSQL, username).Scan(&current, &withdrawn); err != nil {
+		return 0, fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	return current, nil
+}
+
+// AddAdjustment adds the amount to the balance of the user, a negative amount is taken from it.
+// The user is locked for the transaction, it fails with ErrNoMoney if the balance is less than a negative amount.
+func AddAdjustment(ctx context.Context, pgConn *PgxIface, username string, amount float32, reason string) error {
+	ctx, done := observe(ctx, "AddAdjustment")
+	defer done()
+
+	err := inTx(ctx, pgConn, func(tx pgx.Tx) error {
+		current, err := lockBalance(ctx, tx, username)
+		if err != nil {
+			return err
+		}
+		if amount < 0 && -amount > current {
+			return ErrNoMoney
+		}
+
+		return insertLedger(ctx, tx, ledgerTransaction{
+			From:        ledgerAccount{Account: accrual.LedgerAccountAdjustments, Username: username},
+			To:          userAccount(username),
+			Amount:      amount,
+			Kind:        accrual.LedgerKindAdjustment,
+			Source:      ledgerSourceAdjustment,
+			SourceID:    "",
+			Description: reason,
+		})
+	})
+	if err != nil {
+		return failed(ctx, err)
+	}
+
+	return nil
+}
+
+// FindTransactionsByUsername returns up to limit transactions of the user after the transaction after
+// from the oldest one, zero after starts from the first transaction.
+func FindTransactionsByUsername(
+	ctx context.Context, pgConn *PgxIface, username string, after int64, limit int,
+) ([]accrual.TransactionExt, error) {
+	ctx, done := observe(ctx, "FindTransactionsByUsername")
+	defer done()
+
+	result := make([]accrual.TransactionExt, 0)
+	rows, err := (*pgConn).Query(ctx, findTransactionsSQL, username, after, limit)
+	if err != nil {
+		return result, failed(ctx, fmt.Errorf("failed to query: %w", err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var transaction accrual.TransactionExt
+		err = rows.Scan(&transaction.ID, &transaction.Type, &transaction.Amount, &transaction.Balance,
+			&transaction.Order, &transaction.Counterparty, &transaction.Description, &transaction.CreatedAt)
+		if err != nil {
+			return result, failed(ctx, fmt.Errorf("failed to scan a row: %w", err))
+		}
+		result = append(result, transaction)
+	}
+	if err = rows.Err(); err != nil {
+		return result, failed(ctx, fmt.Errorf("failed to read rows: %w", err))
+	}
+
+	return result, nil
+}
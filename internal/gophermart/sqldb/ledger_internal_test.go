@@ -0,0 +1,146 @@
+package sqldb
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBalanceByUsername(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\)").WithArgs("user1").
+		WillReturnRows(pgxmock.NewRows([]string{"current", "withdrawn"}).AddRow(float32(42), float32(2)))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\)").WithArgs("user1").
+		WillReturnError(io.EOF)
+
+	current, withdrawn, err := GetBalanceByUsername(context.Background(), &pgConn, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, float32(42), current)
+	assert.Equal(t, float32(2), withdrawn)
+
+	_, _, err = GetBalanceByUsername(context.Background(), &pgConn, "user1")
+	assert.ErrorIs(t, err, io.EOF)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddAdjustment(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	expectBalance := func(current float32) {
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT 1 FROM mart_users WHERE name = \\$1 FOR UPDATE").WithArgs("user1").
+			WillReturnResult(pgxmock.NewResult("SELECT", 1))
+		mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\)").WithArgs("user1").
+			WillReturnRows(pgxmock.NewRows([]string{"current", "withdrawn"}).AddRow(current, float32(0)))
+	}
+	expectBalance(5)
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("ADJUSTMENTS", "user1", "USER", "user1", float32(-5), "ADJUSTMENT", "adjustment", "", "typo").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectCommit()
+	expectBalance(0)
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("ADJUSTMENTS", "user1", "USER", "user1", float32(5), "ADJUSTMENT", "adjustment", "", "typo").
+		WillReturnError(io.EOF)
+	mock.ExpectRollback()
+	expectBalance(4)
+	mock.ExpectRollback()
+
+	require.NoError(t, AddAdjustment(context.Background(), &pgConn, "user1", -5, "typo"))
+	assert.ErrorIs(t, AddAdjustment(context.Background(), &pgConn, "user1", 5, "typo"), io.EOF)
+	assert.ErrorIs(t, AddAdjustment(context.Background(), &pgConn, "user1", -5, "typo"), ErrNoMoney,
+		"the balance may not go below zero")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithdrawLedger(t *testing.T) {
+	withdraw := &accrual.WithdrawExt{ID: 7, Order: "79927398713", Sum: 10, Username: "user1"} //nolint:exhaustruct
+
+	transaction := withdrawLedger(withdraw, accrual.LedgerKindWithdrawal, "")
+	assert.Equal(t, ledgerAccount{Account: accrual.LedgerAccountUser, Username: "user1"}, transaction.From)
+	assert.Equal(t, accrual.LedgerAccountWithdrawals, transaction.To.Account)
+	assert.Equal(t, "7", transaction.SourceID)
+
+	transaction = withdrawLedger(withdraw, accrual.LedgerKindWithdrawRefund, "fraud")
+	assert.Equal(t, accrual.LedgerAccountWithdrawals, transaction.From.Account)
+	assert.Equal(t, ledgerAccount{Account: accrual.LedgerAccountUser, Username: "user1"}, transaction.To)
+	assert.Equal(t, "fraud", transaction.Description)
+}
+
+func TestFindTransactionsByUsernameScanErr(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	mock.ExpectQuery("WITH entries AS").WithArgs("user1", int64(0), 10).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int64(1)))
+
+	transactions, err := FindTransactionsByUsername(context.Background(), &pgConn, "user1", 0, 10)
+	assert.Error(t, err)
+	assert.Empty(t, transactions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateOrderTierBonus(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE orders").WithArgs("PROCESSED", float32(100), "79927398713").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\) FROM ledger_entries").WithArgs("79927398713").
+		WillReturnRows(pgxmock.NewRows([]string{"amount"}).AddRow(float32(100)))
+	mock.ExpectQuery("SELECT COALESCE\\(\\(SELECT SUM\\(accrual\\)").WithArgs("user1", "79927398713").
+		WillReturnRows(pgxmock.NewRows([]string{"lifetime", "credited"}).AddRow(float32(1500), float32(2)))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("BONUSES", "user1", "USER", "user1", float32(3), "TIER_BONUS", "order", "79927398713", "").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectQuery("SELECT c.name, c.multiplier").WithArgs("79927398713").
+		WillReturnRows(pgxmock.NewRows([]string{"name", "multiplier", "credited"}))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs("order.updated", "user1", pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	var gotLifetime float32
+	multiplier := func(lifetime float32) float32 {
+		gotLifetime = lifetime
+
+		return 1.05
+	}
+	order := accrual.NewOrderExt("79927398713", "PROCESSED", float32(100), time.Now(), "user1")
+	bonuses := &OrderBonuses{Multiplier: multiplier, ReferralBonus: 0, ReferralMaxRewards: 0}
+	require.NoError(t, UpdateOrder(context.Background(), &pgConn, order, bonuses))
+	assert.Equal(t, float32(1500), gotLifetime, "the tier is of the other orders")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetLifetimeAccrual(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(accrual\\), 0\\) FROM orders").WithArgs("user1").
+		WillReturnRows(pgxmock.NewRows([]string{"lifetime"}).AddRow(float32(1500)))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(accrual\\), 0\\) FROM orders").WithArgs("user1").
+		WillReturnError(io.EOF)
+
+	lifetime, err := GetLifetimeAccrual(context.Background(), &pgConn, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, float32(1500), lifetime)
+
+	_, err = GetLifetimeAccrual(context.Background(), &pgConn, "user1")
+	assert.ErrorIs(t, err, io.EOF)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,118 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/jackc/pgx/v5"
+)
+
+// claimPendingOrdersSQL leases the due orders by moving their next check,
+// the locked rows are skipped so the instances sharing the DB never poll an order at once.
+const claimPendingOrdersSQL = `
+WITH due AS (
+    SELECT id FROM orders
+    WHERE status IN ($1, $2) AND NOT needs_review AND next_check_at <= now()
+    ORDER BY next_check_at
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE orders AS o
+SET next_check_at = now() + make_interval(secs => $4::DOUBLE PRECISION)
+FROM due
+WHERE o.id = due.id
+RETURNING o.number, o.status, o.accrual, o.username, o.uploaded_at, o.attempts`
+
+// claimOrderSQL leases the order if it is due like claimPendingOrdersSQL.
+const claimOrderSQL = `
+UPDATE orders
+SET next_check_at = now() + make_interval(secs => $4::DOUBLE PRECISION)
+WHERE number = $1 AND status IN ($2, $3) AND NOT needs_review AND next_check_at <= now()
+RETURNING number, status, accrual, username, uploaded_at, attempts`
+
+// ClaimPendingOrders leases up to limit due orders which are not final and not flagged for review,
+// the most overdue first. The lease expires after lease, so the orders of a dead instance are claimed again.
+func ClaimPendingOrders(
+	ctx context.Context, pgConn *PgxIface, limit int, lease time.Duration,
+) ([]accrual.PendingOrder, error) {
+	ctx, done := observe(ctx, "ClaimPendingOrders")
+	defer done()
+
+	rows, err := (*pgConn).Query(ctx, claimPendingOrdersSQL,
+		accrual.OrderStatusNew, accrual.OrderStatusProcessing, limit, lease.Seconds())
+	if err != nil {
+		return nil, failed(ctx, fmt.Errorf("failed to claim orders: %w", err))
+	}
+	defer rows.Close()
+	orders := make([]accrual.PendingOrder, 0)
+	for rows.Next() {
+		var order accrual.PendingOrder
+		if err = scanPendingOrder(rows, &order); err != nil {
+			return nil, failed(ctx, err)
+		}
+		orders = append(orders, order)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, failed(ctx, fmt.Errorf("failed to read rows: %w", err))
+	}
+
+	return orders, nil
+}
+
+// ClaimOrder leases the order if it is due like ClaimPendingOrders does,
+// it returns nil if the order is final, flagged for review, not due or leased by another instance.
+func ClaimOrder(
+	ctx context.Context, pgConn *PgxIface, number string, lease time.Duration,
+) (*accrual.PendingOrder, error) {
+	ctx, done := observe(ctx, "ClaimOrder")
+	defer done()
+
+	var claimed *accrual.PendingOrder
+	var order accrual.PendingOrder
+	row := (*pgConn).QueryRow(ctx, claimOrderSQL,
+		number, accrual.OrderStatusNew, accrual.OrderStatusProcessing, lease.Seconds())
+	if err := scanPendingOrder(row, &order); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return claimed, nil
+		}
+
+		return nil, failed(ctx, err)
+	}
+	claimed = &order
+
+	return claimed, nil
+}
+
+func scanPendingOrder(row pgx.Row, order *accrual.PendingOrder) error {
+	err := row.Scan(&order.Number, &order.Status, &order.Accrual, &order.Username, &order.UploadedAt, &order.Attempts)
+	if err != nil {
+		return fmt.Errorf("failed to scan a row: %w", err)
+	}
+
+	return nil
+}
+
+// ScheduleOrderCheck records the failed poll of the order and moves its next check,
+// the order is flagged for manual review if it was uploaded before reviewBefore.
+// It returns true if the order is flagged.
+func ScheduleOrderCheck(
+	ctx context.Context, pgConn *PgxIface, number string,
+	attempts int, nextCheckAt time.Time, lastError string, reviewBefore time.Time,
+) (bool, error) {
+	ctx, done := observe(ctx, "ScheduleOrderCheck")
+	defer done()
+
+	var flagged bool
+	err := (*pgConn).QueryRow(ctx,
+		"UPDATE orders SET attempts = $2, next_check_at = $3, last_error = $4, needs_review = uploaded_at < $5"+
+			" WHERE number = $1 RETURNING needs_review",
+		number, attempts, nextCheckAt, lastError, reviewBefore).Scan(&flagged)
+	if err != nil {
+		return false, failed(ctx, fmt.Errorf("failed to schedule order check: %w", err))
+	}
+
+	return flagged, nil
+}
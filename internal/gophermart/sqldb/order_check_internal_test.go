@@ -0,0 +1,95 @@
+package sqldb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pendingOrderColumns = []string{"number", "status", "accrual", "username", "uploaded_at", "attempts"}
+
+func TestClaimPendingOrders(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	uploadedAt := time.Now()
+	mock.ExpectQuery("WITH due AS").
+		WithArgs(accrual.OrderStatusNew, accrual.OrderStatusProcessing, 2, float64(120)).
+		WillReturnRows(pgxmock.NewRows(pendingOrderColumns).
+			AddRow("79927398713", accrual.OrderStatusNew, float32(0), "user1", uploadedAt, 3))
+	mock.ExpectQuery("WITH due AS").
+		WithArgs(accrual.OrderStatusNew, accrual.OrderStatusProcessing, 2, float64(120)).
+		WillReturnError(pgx.ErrTxClosed)
+
+	orders, err := ClaimPendingOrders(context.Background(), &pgConn, 2, 2*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []accrual.PendingOrder{{
+		OrderExt: *accrual.NewOrderExt("79927398713", accrual.OrderStatusNew, 0, uploadedAt, "user1"),
+		Attempts: 3,
+	}}, orders)
+
+	_, err = ClaimPendingOrders(context.Background(), &pgConn, 2, 2*time.Minute)
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimOrder(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	uploadedAt := time.Now()
+	args := []interface{}{"79927398713", accrual.OrderStatusNew, accrual.OrderStatusProcessing, float64(60)}
+	mock.ExpectQuery("UPDATE orders SET next_check_at").WithArgs(args...).
+		WillReturnRows(pgxmock.NewRows(pendingOrderColumns).
+			AddRow("79927398713", accrual.OrderStatusProcessing, float32(0), "user1", uploadedAt, 1))
+	mock.ExpectQuery("UPDATE orders SET next_check_at").WithArgs(args...).
+		WillReturnRows(pgxmock.NewRows(pendingOrderColumns))
+	mock.ExpectQuery("UPDATE orders SET next_check_at").WithArgs(args...).
+		WillReturnError(pgx.ErrTxClosed)
+
+	claimed, err := ClaimOrder(context.Background(), &pgConn, "79927398713", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	assert.Equal(t, accrual.OrderStatusProcessing, claimed.Status)
+	assert.Equal(t, 1, claimed.Attempts)
+
+	claimed, err = ClaimOrder(context.Background(), &pgConn, "79927398713", time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, claimed)
+
+	_, err = ClaimOrder(context.Background(), &pgConn, "79927398713", time.Minute)
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScheduleOrderCheck(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	nextCheckAt := time.Now().Add(time.Minute)
+	reviewBefore := time.Now().Add(-time.Hour)
+	mock.ExpectQuery("UPDATE orders SET attempts").
+		WithArgs("79927398713", 2, nextCheckAt, "timeout", reviewBefore).
+		WillReturnRows(pgxmock.NewRows([]string{"needs_review"}).AddRow(true))
+	mock.ExpectQuery("UPDATE orders SET attempts").
+		WithArgs("79927398713", 2, nextCheckAt, "timeout", reviewBefore).
+		WillReturnError(pgx.ErrNoRows)
+
+	flagged, err := ScheduleOrderCheck(context.Background(), &pgConn, "79927398713",
+		2, nextCheckAt, "timeout", reviewBefore)
+	require.NoError(t, err)
+	assert.True(t, flagged)
+
+	_, err = ScheduleOrderCheck(context.Background(), &pgConn, "79927398713", 2, nextCheckAt, "timeout", reviewBefore)
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,139 @@
+package sqldb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/events"
+	"github.com/jackc/pgx/v5"
+)
+
+// inTx runs fn in a transaction, it is committed if fn succeeds and rolled back otherwise.
+func inTx(ctx context.Context, pgConn *PgxIface, fn func(tx pgx.Tx) error) error {
+	tx, err := (*pgConn).Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err = fn(tx); err != nil {
+		if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			return fmt.Errorf("%w (rollback failed: %s)", err, rbErr.Error())
+		}
+
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// insertOutbox writes the domain event in the transaction of the change.
+func insertOutbox(ctx context.Context, tx pgx.Tx, eventType string, username string, entity interface{}) error {
+	payload, err := json.Marshal(entity)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", eventType, err)
+	}
+	_, err = tx.Exec(ctx,
+		"INSERT INTO outbox (event, username, payload) VALUES ($1, $2, $3)",
+		eventType, username, string(payload))
+	if err != nil {
+		return fmt.Errorf("failed to insert into outbox: %w", err)
+	}
+
+	return nil
+}
+
+// DispatchOutbox passes up to limit pending records to dispatch in order of their IDs
+// and marks the dispatched ones as delivered.
+// The records are locked until they are marked, so the instances sharing the DB never dispatch a record at once;
+// dispatching stops at the first failed record, it is passed again on the next call.
+// It returns count of the delivered records and the error of dispatch if any.
+func DispatchOutbox(
+	ctx context.Context, pgConn *PgxIface, limit int, dispatch func(record events.Record) error,
+) (int, error) {
+	ctx, done := observe(ctx, "DispatchOutbox")
+	defer done()
+
+	var delivered []int64
+	var dispatchErr error
+	err := inTx(ctx, pgConn, func(tx pgx.Tx) error {
+		records, err := lockPendingOutbox(ctx, tx, limit)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if dispatchErr = dispatch(record); dispatchErr != nil {
+				break
+			}
+			delivered = append(delivered, record.ID)
+		}
+		if len(delivered) == 0 {
+			return nil
+		}
+		if _, err = tx.Exec(ctx, "UPDATE outbox SET delivered_at = now() WHERE id = ANY($1)", delivered); err != nil {
+			return fmt.Errorf("failed to update outbox: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, failed(ctx, err)
+	}
+
+	return len(delivered), dispatchErr
+}
+
+func lockPendingOutbox(ctx context.Context, tx pgx.Tx, limit int) ([]events.Record, error) {
+	rows, err := tx.Query(ctx,
+		"SELECT id, event, username, payload, created_at FROM outbox WHERE delivered_at IS NULL"+
+			" ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED",
+		limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	defer rows.Close()
+	records := make([]events.Record, 0)
+	for rows.Next() {
+		var record events.Record
+		var payload string
+		if err = rows.Scan(&record.ID, &record.Type, &record.Username, &payload, &record.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan a row: %w", err)
+		}
+		record.Payload = []byte(payload)
+		records = append(records, record)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rows: %w", err)
+	}
+
+	return records, nil
+}
+
+// DeleteDeliveredOutbox deletes the records delivered before the time.
+func DeleteDeliveredOutbox(ctx context.Context, pgConn *PgxIface, before time.Time) (int64, error) {
+	ctx, done := observe(ctx, "DeleteDeliveredOutbox")
+	defer done()
+
+	tag, err := (*pgConn).Exec(ctx, "DELETE FROM outbox WHERE delivered_at < $1", before)
+	if err != nil {
+		return 0, failed(ctx, fmt.Errorf("failed to delete from outbox: %w", err))
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// Notify sends the payload to the sessions listening to the channel.
+func Notify(ctx context.Context, pgConn *PgxIface, channel string, payload string) error {
+	ctx, done := observe(ctx, "Notify")
+	defer done()
+
+	if _, err := (*pgConn).Exec(ctx, "SELECT pg_notify($1, $2)", channel, payload); err != nil {
+		return failed(ctx, fmt.Errorf("failed to notify %s: %w", channel, err))
+	}
+
+	return nil
+}
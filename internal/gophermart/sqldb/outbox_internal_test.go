@@ -0,0 +1,120 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/events"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func outboxRows(createdAt time.Time) *pgxmock.Rows {
+	return pgxmock.NewRows([]string{"id", "event", "username", "payload", "created_at"}).
+		AddRow(int64(1), "order.updated", "user1", `{"number":"1"}`, createdAt).
+		AddRow(int64(2), "withdrawal.created", "user1", `{"order":"2"}`, createdAt).
+		AddRow(int64(3), "order.updated", "user2", `{"number":"3"}`, createdAt)
+}
+
+func TestDispatchOutbox(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	createdAt := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox WHERE delivered_at IS NULL .* FOR UPDATE SKIP LOCKED").
+		WithArgs(100).
+		WillReturnRows(outboxRows(createdAt))
+	mock.ExpectExec("UPDATE outbox SET delivered_at = now\\(\\) WHERE id = ANY").
+		WithArgs([]int64{1, 2, 3}).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 3))
+	mock.ExpectCommit()
+
+	got := make([]events.Record, 0)
+	delivered, err := DispatchOutbox(context.Background(), &pgConn, 100, func(record events.Record) error {
+		got = append(got, record)
+
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, delivered)
+	require.Len(t, got, 3)
+	assert.Equal(t, events.Record{
+		ID: 2, Type: "withdrawal.created", Username: "user1", Payload: []byte(`{"order":"2"}`), CreatedAt: createdAt,
+	}, got[1])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDispatchOutboxStopsAtFailure(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	errHandler := errors.New("handler failed")
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox").WithArgs(100).WillReturnRows(outboxRows(time.Now()))
+	mock.ExpectExec("UPDATE outbox SET delivered_at").
+		WithArgs([]int64{1}).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectCommit()
+	// nothing is dispatched, so nothing is marked
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox").WithArgs(100).WillReturnRows(outboxRows(time.Now()))
+	mock.ExpectCommit()
+
+	delivered, err := DispatchOutbox(context.Background(), &pgConn, 100, func(record events.Record) error {
+		if record.ID == 2 {
+			return errHandler
+		}
+
+		return nil
+	})
+	assert.ErrorIs(t, err, errHandler)
+	assert.Equal(t, 1, delivered)
+
+	delivered, err = DispatchOutbox(context.Background(), &pgConn, 100, func(events.Record) error {
+		return errHandler
+	})
+	assert.ErrorIs(t, err, errHandler)
+	assert.Zero(t, delivered)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDispatchOutboxRollsBack(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox").WithArgs(100).WillReturnRows(outboxRows(time.Now()))
+	mock.ExpectExec("UPDATE outbox SET delivered_at").
+		WithArgs([]int64{1, 2, 3}).
+		WillReturnError(io.EOF)
+	mock.ExpectRollback()
+
+	delivered, err := DispatchOutbox(context.Background(), &pgConn, 100, func(events.Record) error { return nil })
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Zero(t, delivered)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteDeliveredOutbox(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	before := time.Now()
+	mock.ExpectExec("DELETE FROM outbox WHERE delivered_at < \\$1").
+		WithArgs(before).
+		WillReturnResult(pgxmock.NewResult("DELETE", 5))
+
+	deleted, err := DeleteDeliveredOutbox(context.Background(), &pgConn, before)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
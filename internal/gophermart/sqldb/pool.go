@@ -0,0 +1,56 @@
+package sqldb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/config"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// poolConn adapts *pgxpool.Pool to PgxIface,
+// so the queries may run concurrently on the pooled connections.
+type poolConn struct {
+	*pgxpool.Pool
+}
+
+func (p poolConn) Prepare(ctx context.Context, name, sql string) (*pgconn.StatementDescription, error) {
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire a connection: %w", err)
+	}
+	defer conn.Release()
+
+	desc, err := conn.Conn().Prepare(ctx, name, sql)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare %s: %w", name, err)
+	}
+
+	return desc, nil
+}
+
+func (p poolConn) Close(context.Context) error {
+	p.Pool.Close()
+
+	return nil
+}
+
+func connectPool(cfg *config.Config) (PgxIface, error) {
+	poolCfg, err := pgxpool.ParseConfig(cfg.ConnectionDB)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	poolCfg.MaxConns = int32(cfg.DBMaxConns)
+	poolCfg.MinConns = int32(cfg.DBMinConns)
+	poolCfg.ConnConfig.ConnectTimeout = cfg.DBConnectTimeout
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DBConnectTimeout)
+	defer cancel()
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return poolConn{Pool: pool}, nil
+}
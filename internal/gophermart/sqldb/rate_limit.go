@@ -0,0 +1,61 @@
+package sqldb
+
+import (
+	"context"
+	"fmt"
+)
+
+// takeRateLimitTokenSQL refills the bucket by the time passed since its update
+// and takes a token if there is one, all in one statement,
+// so the instances sharing the DB never race on a bucket.
+const takeRateLimitTokenSQL = `
+INSERT INTO rate_limits AS rl (key, tokens, allowed, full_at, updated_at)
+VALUES ($1, $3::DOUBLE PRECISION - 1, true, now() + make_interval(secs => 1 / $2::DOUBLE PRECISION), now())
+ON CONFLICT (key) DO UPDATE SET (tokens, allowed, full_at, updated_at) = (
+    SELECT refill.rest,
+           refill.allowed,
+           now() + make_interval(secs => ($3::DOUBLE PRECISION - refill.rest) / $2::DOUBLE PRECISION),
+           now()
+    FROM (
+        SELECT bucket.tokens - CASE WHEN bucket.tokens >= 1 THEN 1 ELSE 0 END AS rest,
+               bucket.tokens >= 1                                         AS allowed
+        FROM (
+            SELECT LEAST($3::DOUBLE PRECISION,
+                         rl.tokens + EXTRACT(EPOCH FROM now() - rl.updated_at)::DOUBLE PRECISION * $2::DOUBLE PRECISION
+                   ) AS tokens
+        ) AS bucket
+    ) AS refill
+)
+RETURNING tokens, allowed`
+
+// TakeRateLimitToken takes a token from the bucket of key,
+// it returns whether the token has been taken and the tokens left.
+func TakeRateLimitToken(
+	ctx context.Context, pgConn *PgxIface, key string, rate float64, burst int,
+) (bool, float64, error) {
+	ctx, done := observe(ctx, "TakeRateLimitToken")
+	defer done()
+
+	var tokens float64
+	var allowed bool
+	row := (*pgConn).QueryRow(ctx, takeRateLimitTokenSQL, key, rate, burst)
+	if err := row.Scan(&tokens, &allowed); err != nil {
+		return false, 0, failed(ctx, fmt.Errorf("failed to take rate limit token: %w", err))
+	}
+
+	return allowed, tokens, nil
+}
+
+// DeleteRefilledRateLimits deletes the buckets which have been refilled,
+// they are the same as the absent ones.
+func DeleteRefilledRateLimits(ctx context.Context, pgConn *PgxIface) (int64, error) {
+	ctx, done := observe(ctx, "DeleteRefilledRateLimits")
+	defer done()
+
+	tag, err := (*pgConn).Exec(ctx, "DELETE FROM rate_limits WHERE full_at < now()")
+	if err != nil {
+		return 0, failed(ctx, fmt.Errorf("failed to delete rate limits: %w", err))
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,145 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/referral"
+	"github.com/jackc/pgx/v5"
+)
+
+const ledgerSourceReferral = "referral"
+
+// getReferralCodeSQL sets the code $2 to the user $1 unless the user has a code and returns the code of the user.
+// The no-op update of a conflict returns the existing code, so a concurrent first request waits for the other one
+// and gets its code.
+const getReferralCodeSQL = `
+INSERT INTO referral_codes (username, code) VALUES ($1, $2)
+ON CONFLICT (username) DO UPDATE SET code = referral_codes.code
+RETURNING code`
+
+// processReferralSQL marks the pending referral of the user $1 processed: it is rewarded
+// unless the referrer has got $2 rewards before, zero $2 is unlimited.
+const processReferralSQL = `
+UPDATE referrals
+SET status = CASE
+        WHEN $2 = 0 OR (SELECT COUNT(*) FROM referrals r WHERE r.referrer = referrals.referrer
+                                                         AND r.status = 'REWARDED') < $2 THEN 'REWARDED'
+        ELSE 'CAPPED' END,
+    processed_at = now()
+WHERE referee = $1 AND status = 'PENDING'
+RETURNING status`
+
+// GetReferralCode returns the referral code of the user, the code is set to newCode if the user has none.
+// The codes are assigned lazily: a user gets one on the first request of it, not on registration.
+func GetReferralCode(ctx context.Context, pgConn *PgxIface, username string, newCode string) (string, error) {
+	ctx, done := observe(ctx, "GetReferralCode")
+	defer done()
+
+	var code string
+	if err := (*pgConn).QueryRow(ctx, getReferralCodeSQL, username, newCode).Scan(&code); err != nil {
+		return "", failed(ctx, fmt.Errorf("failed to get referral code: %w", err))
+	}
+
+	return code, nil
+}
+
+// FindReferrerByCode returns the user of the referral code, it returns an empty string if there is none.
+func FindReferrerByCode(ctx context.Context, pgConn *PgxIface, code string) (string, error) {
+	ctx, done := observe(ctx, "FindReferrerByCode")
+	defer done()
+
+	var username string
+	err := (*pgConn).QueryRow(ctx, "SELECT username FROM referral_codes WHERE code = $1", code).Scan(&username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+
+		return "", failed(ctx, fmt.Errorf("failed to find referrer: %w", err))
+	}
+
+	return username, nil
+}
+
+// AddReferral records that the referee is registered by the code of the referrer,
+// it returns false if the referee has a referrer already.
+func AddReferral(ctx context.Context, pgConn *PgxIface, referrer string, referee string) (bool, error) {
+	ctx, done := observe(ctx, "AddReferral")
+	defer done()
+
+	tag, err := (*pgConn).Exec(ctx,
+		"INSERT INTO referrals (referee, referrer) VALUES ($1, $2) ON CONFLICT (referee) DO NOTHING",
+		referee, referrer)
+	if err != nil {
+		return false, failed(ctx, fmt.Errorf("failed to insert into referrals: %w", err))
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// FindReferralsByReferrer returns the referrals of the referrer from the latest one.
+func FindReferralsByReferrer(ctx context.Context, pgConn *PgxIface, referrer string) ([]referral.Referral, error) {
+	ctx, done := observe(ctx, "FindReferralsByReferrer")
+	defer done()
+
+	result := make([]referral.Referral, 0)
+	rows, err := (*pgConn).Query(ctx,
+		"SELECT referee, status, created_at, processed_at FROM referrals WHERE referrer = $1 ORDER BY created_at DESC",
+		referrer)
+	if err != nil {
+		return result, failed(ctx, fmt.Errorf("failed to query: %w", err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item referral.Referral
+		if err = rows.Scan(&item.Login, &item.Status, &item.RegisteredAt, &item.ProcessedAt); err != nil {
+			return result, failed(ctx, fmt.Errorf("failed to scan a row: %w", err))
+		}
+		result = append(result, item)
+	}
+	if err = rows.Err(); err != nil {
+		return result, failed(ctx, fmt.Errorf("failed to read rows: %w", err))
+	}
+
+	return result, nil
+}
+
+// rewardReferral credits the bonus to the referrer of the user on the first processed order of the user.
+// The referrer is locked for the transaction, so the rewards of the referrer never exceed maxRewards.
+func rewardReferral(ctx context.Context, tx pgx.Tx, username string, bonus float32, maxRewards int) error {
+	var referrer string
+	err := tx.QueryRow(ctx,
+		"SELECT referrer FROM referrals WHERE referee = $1 AND status = 'PENDING'", username).Scan(&referrer)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to find referral: %w", err)
+	}
+	if _, err = tx.Exec(ctx, "SELECT 1 FROM mart_users WHERE name = $1 FOR UPDATE", referrer); err != nil {
+		return fmt.Errorf("failed to lock the referrer: %w", err)
+	}
+	var status string
+	err = tx.QueryRow(ctx, processReferralSQL, username, maxRewards).Scan(&status)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to update referrals: %w", err)
+	}
+	if status != referral.StatusRewarded {
+		// The referral is capped or processed by another order meanwhile.
+		return nil
+	}
+
+	return insertLedger(ctx, tx, ledgerTransaction{
+		From:        ledgerAccount{Account: accrual.LedgerAccountReferrals, Username: referrer},
+		To:          userAccount(referrer),
+		Amount:      bonus,
+		Kind:        accrual.LedgerKindReferralBonus,
+		Source:      ledgerSourceReferral,
+		SourceID:    username,
+		Description: "",
+	})
+}
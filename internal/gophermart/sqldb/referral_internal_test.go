@@ -0,0 +1,118 @@
+package sqldb
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectProcessedOrder expects the update of the processed order of user2 without the accrual and campaign bonuses.
+func expectProcessedOrder(mock pgxmock.PgxConnIface) {
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE orders").WithArgs("PROCESSED", float32(100), "79927398713").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\) FROM ledger_entries").WithArgs("79927398713").
+		WillReturnRows(pgxmock.NewRows([]string{"amount"}).AddRow(float32(100)))
+	mock.ExpectQuery("SELECT c.name, c.multiplier").WithArgs("79927398713").
+		WillReturnRows(pgxmock.NewRows([]string{"name", "multiplier", "credited"}))
+}
+
+func TestUpdateOrderReferralBonus(t *testing.T) {
+	tests := []struct {
+		name       string
+		referrer   string
+		status     string
+		wantReward bool
+	}{
+		{name: "rewarded", referrer: "user1", status: "REWARDED", wantReward: true},
+		{name: "capped", referrer: "user1", status: "CAPPED"},
+		{name: "processed meanwhile", referrer: "user1"},
+		{name: "not referred"},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			mock, err := pgxmock.NewConn()
+			require.NoError(t, err)
+			var pgConn PgxIface = mock
+
+			expectProcessedOrder(mock)
+			rows := pgxmock.NewRows([]string{"referrer"})
+			if test.referrer != "" {
+				rows.AddRow(test.referrer)
+			}
+			mock.ExpectQuery("SELECT referrer FROM referrals").WithArgs("user2").WillReturnRows(rows)
+			if test.referrer != "" {
+				mock.ExpectExec("SELECT 1 FROM mart_users").WithArgs("user1").
+					WillReturnResult(pgxmock.NewResult("SELECT", 1))
+				statuses := pgxmock.NewRows([]string{"status"})
+				if test.status != "" {
+					statuses.AddRow(test.status)
+				}
+				mock.ExpectQuery("UPDATE referrals").WithArgs("user2", 10).WillReturnRows(statuses)
+			}
+			if test.wantReward {
+				mock.ExpectExec("INSERT INTO ledger_entries").
+					WithArgs("REFERRALS", "user1", "USER", "user1", float32(50),
+						"REFERRAL_BONUS", "referral", "user2", "").
+					WillReturnResult(pgxmock.NewResult("INSERT", 2))
+			}
+			mock.ExpectExec("INSERT INTO outbox").WithArgs("order.updated", "user2", pgxmock.AnyArg()).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			mock.ExpectCommit()
+
+			order := accrual.NewOrderExt("79927398713", "PROCESSED", float32(100), time.Now(), "user2")
+			bonuses := &OrderBonuses{Multiplier: nil, ReferralBonus: 50, ReferralMaxRewards: 10}
+			require.NoError(t, UpdateOrder(context.Background(), &pgConn, order, bonuses))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetReferralCode(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	mock.ExpectQuery("INSERT INTO referral_codes").WithArgs("user1", "NEWCODE1").
+		WillReturnRows(pgxmock.NewRows([]string{"code"}).AddRow("OLDCODE1"))
+	mock.ExpectQuery("INSERT INTO referral_codes").WithArgs("user1", "NEWCODE2").WillReturnError(io.EOF)
+
+	code, err := GetReferralCode(context.Background(), &pgConn, "user1", "NEWCODE1")
+	require.NoError(t, err)
+	assert.Equal(t, "OLDCODE1", code, "the code of the user is kept")
+
+	_, err = GetReferralCode(context.Background(), &pgConn, "user1", "NEWCODE2")
+	assert.ErrorIs(t, err, io.EOF)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddReferral(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	mock.ExpectExec("INSERT INTO referrals").WithArgs("user2", "user1").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO referrals").WithArgs("user2", "user3").
+		WillReturnResult(pgxmock.NewResult("INSERT", 0))
+	mock.ExpectExec("INSERT INTO referrals").WithArgs("user2", "user1").WillReturnError(io.EOF)
+
+	added, err := AddReferral(context.Background(), &pgConn, "user1", "user2")
+	require.NoError(t, err)
+	assert.True(t, added)
+
+	added, err = AddReferral(context.Background(), &pgConn, "user3", "user2")
+	require.NoError(t, err)
+	assert.False(t, added, "a user has one referrer")
+
+	_, err = AddReferral(context.Background(), &pgConn, "user1", "user2")
+	assert.ErrorIs(t, err, io.EOF)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
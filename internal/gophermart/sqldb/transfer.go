@@ -0,0 +1,72 @@
+package sqldb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/jackc/pgx/v5"
+)
+
+const ledgerSourceTransfer = "transfer"
+
+// transferStateSQL reads the state of the sender $1 and the recipient $2,
+// the sent sum and count are of the transfers made since $3.
+const transferStateSQL = `
+SELECT EXISTS (SELECT 1 FROM mart_users WHERE name = $2),
+       COALESCE(SUM(amount), 0),
+       COALESCE(-SUM(amount) FILTER (WHERE kind = 'TRANSFER' AND amount < 0 AND created_at >= $3), 0),
+       COUNT(*) FILTER (WHERE kind = 'TRANSFER' AND amount < 0 AND created_at >= $3)
+FROM ledger_entries
+WHERE account = 'USER' AND username = $1`
+
+// TransferState is a state of the sender and the recipient of a transfer,
+// SentSum and SentCount are of the transfers made by the sender since the start of the limit window.
+type TransferState struct {
+	RecipientExists bool
+	Balance         float32
+	SentSum         float32
+	SentCount       int
+}
+
+// AddTransfer moves the amount of the transfer from the balance of the sender to the balance of the recipient.
+// The balance of the sender is locked for the transaction, so check gets the state
+// which may not change until the transfer is written; the transfer is not written if check fails.
+func AddTransfer(
+	ctx context.Context, pgConn *PgxIface, transfer accrual.TransferExt, since time.Time,
+	check func(state TransferState) error,
+) error {
+	ctx, done := observe(ctx, "AddTransfer")
+	defer done()
+
+	err := inTx(ctx, pgConn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SELECT 1 FROM mart_users WHERE name = $1 FOR UPDATE", transfer.Username); err != nil {
+			return fmt.Errorf("failed to lock the sender: %w", err)
+		}
+		var state TransferState
+		err := tx.QueryRow(ctx, transferStateSQL, transfer.Username, transfer.Recipient, since).
+			Scan(&state.RecipientExists, &state.Balance, &state.SentSum, &state.SentCount)
+		if err != nil {
+			return fmt.Errorf("failed to get state of transfer: %w", err)
+		}
+		if err = check(state); err != nil {
+			return err
+		}
+
+		return insertLedger(ctx, tx, ledgerTransaction{
+			From:        userAccount(transfer.Username),
+			To:          userAccount(transfer.Recipient),
+			Amount:      transfer.Amount,
+			Kind:        accrual.LedgerKindTransfer,
+			Source:      ledgerSourceTransfer,
+			SourceID:    "",
+			Description: "",
+		})
+	})
+	if err != nil {
+		return failed(ctx, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddTransfer(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	since := time.Now().Add(-24 * time.Hour)
+	transfer := accrual.TransferExt{Recipient: "user2", Amount: 10, ProcessedAt: time.Now(), Username: "user1"}
+	expectState := func() {
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT 1 FROM mart_users WHERE name = \\$1 FOR UPDATE").WithArgs("user1").
+			WillReturnResult(pgxmock.NewResult("SELECT", 1))
+		mock.ExpectQuery("SELECT EXISTS").WithArgs("user1", "user2", since).
+			WillReturnRows(pgxmock.NewRows([]string{"recipient_exists", "balance", "sent_sum", "sent_count"}).
+				AddRow(true, float32(42), float32(5), 2))
+	}
+	expectState()
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("USER", "user1", "USER", "user2", float32(10), "TRANSFER", "transfer", "", "").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectCommit()
+	expectState()
+	mock.ExpectRollback()
+
+	var checked TransferState
+	err = AddTransfer(context.Background(), &pgConn, transfer, since, func(state TransferState) error {
+		checked = state
+
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, TransferState{RecipientExists: true, Balance: 42, SentSum: 5, SentCount: 2}, checked)
+
+	errCheck := errors.New("check")
+	err = AddTransfer(context.Background(), &pgConn, transfer, since, func(TransferState) error { return errCheck })
+	assert.ErrorIs(t, err, errCheck, "the failed check rolls the transfer back")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddTransferErr(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT 1 FROM mart_users WHERE name = \\$1 FOR UPDATE").WithArgs("user1").
+		WillReturnError(io.EOF)
+	mock.ExpectRollback()
+
+	transfer := accrual.TransferExt{Recipient: "user2", Amount: 10, ProcessedAt: time.Now(), Username: "user1"}
+	err = AddTransfer(context.Background(), &pgConn, transfer, time.Now(), func(TransferState) error { return nil })
+	assert.ErrorIs(t, err, io.EOF)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
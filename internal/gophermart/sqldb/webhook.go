@@ -0,0 +1,259 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/webhook"
+	"github.com/jackc/pgx/v5"
+)
+
+// claimWebhookDeliveriesSQL leases the due deliveries by moving their next attempt,
+// the locked rows are skipped so the instances sharing the DB never send a delivery twice at once.
+const claimWebhookDeliveriesSQL = `
+WITH due AS (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'PENDING' AND next_attempt_at <= now()
+    ORDER BY next_attempt_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE webhook_deliveries AS d
+SET next_attempt_at = now() + make_interval(secs => $2::DOUBLE PRECISION)
+FROM due, webhooks AS w
+WHERE d.id = due.id AND w.id = d.webhook_id
+RETURNING d.id, d.webhook_id, d.event, d.payload, d.attempts, d.created_at, w.url, w.secret`
+
+// deadLetterWebhookDeliverySQL marks the delivery as dead and copies it to the dead letters,
+// the copy keeps the URL as the webhook may be deleted later.
+const deadLetterWebhookDeliverySQL = `
+WITH dead AS (
+    UPDATE webhook_deliveries AS d
+    SET status = 'DEAD', attempts = d.attempts + 1, last_status_code = $2, last_error = $3
+    FROM webhooks AS w
+    WHERE d.id = $1 AND w.id = d.webhook_id
+    RETURNING d.id, d.webhook_id, w.url, d.event, d.payload, d.attempts, d.last_status_code, d.last_error
+)
+INSERT INTO webhook_dead_letters
+    (delivery_id, webhook_id, url, event, payload, attempts, last_status_code, last_error)
+SELECT * FROM dead`
+
+// AddWebhook inserts the webhook and sets its ID and creation time,
+// it returns false if the user has already registered the URL.
+func AddWebhook(ctx context.Context, pgConn *PgxIface, hook *webhook.Webhook) (bool, error) {
+	ctx, done := observe(ctx, "AddWebhook")
+	defer done()
+
+	row := (*pgConn).QueryRow(ctx,
+		"INSERT INTO webhooks (username, url, secret) VALUES ($1, $2, $3)"+
+			" ON CONFLICT (username, url) DO NOTHING RETURNING id, created_at",
+		hook.Username, hook.URL, hook.Secret)
+	if err := row.Scan(&hook.ID, &hook.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+
+		return false, failed(ctx, fmt.Errorf("failed to insert into webhooks: %w", err))
+	}
+
+	return true, nil
+}
+
+// CountWebhooksByUsername returns count of the webhooks of the user.
+func CountWebhooksByUsername(ctx context.Context, pgConn *PgxIface, username string) (int, error) {
+	ctx, done := observe(ctx, "CountWebhooksByUsername")
+	defer done()
+
+	var count int
+	row := (*pgConn).QueryRow(ctx, "SELECT COUNT(*) FROM webhooks WHERE username = $1", username)
+	if err := row.Scan(&count); err != nil {
+		return 0, failed(ctx, fmt.Errorf("failed to count webhooks: %w", err))
+	}
+
+	return count, nil
+}
+
+// FindWebhooksByUsername returns the webhooks of the user without their secrets.
+func FindWebhooksByUsername(ctx context.Context, pgConn *PgxIface, username string) ([]webhook.Webhook, error) {
+	ctx, done := observe(ctx, "FindWebhooksByUsername")
+	defer done()
+
+	result := make([]webhook.Webhook, 0)
+	rows, err := (*pgConn).Query(ctx,
+		"SELECT id, url, created_at FROM webhooks WHERE username = $1 ORDER BY id", username)
+	if err != nil {
+		return result, failed(ctx, fmt.Errorf("failed to query: %w", err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		hook := webhook.Webhook{ID: 0, URL: "", Secret: "", CreatedAt: time.Time{}, Username: username}
+		if err = rows.Scan(&hook.ID, &hook.URL, &hook.CreatedAt); err != nil {
+			return result, failed(ctx, fmt.Errorf("failed to scan a row: %w", err))
+		}
+		result = append(result, hook)
+	}
+
+	return result, nil
+}
+
+// WebhookExists reports whether the user has the webhook.
+func WebhookExists(ctx context.Context, pgConn *PgxIface, username string, id int64) (bool, error) {
+	ctx, done := observe(ctx, "WebhookExists")
+	defer done()
+
+	var exists bool
+	row := (*pgConn).QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = $1 AND username = $2)", id, username)
+	if err := row.Scan(&exists); err != nil {
+		return false, failed(ctx, fmt.Errorf("failed to find webhook: %w", err))
+	}
+
+	return exists, nil
+}
+
+// DeleteWebhook deletes the webhook of the user with its pending deliveries,
+// it returns false if the user has no such webhook.
+func DeleteWebhook(ctx context.Context, pgConn *PgxIface, username string, id int64) (bool, error) {
+	ctx, done := observe(ctx, "DeleteWebhook")
+	defer done()
+
+	tag, err := (*pgConn).Exec(ctx, "DELETE FROM webhooks WHERE id = $1 AND username = $2", id, username)
+	if err != nil {
+		return false, failed(ctx, fmt.Errorf("failed to delete webhook: %w", err))
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// EnqueueWebhookDeliveries adds a pending delivery of the payload to each webhook of the user,
+// it returns count of the added deliveries.
+func EnqueueWebhookDeliveries(
+	ctx context.Context, pgConn *PgxIface, username string, event string, payload string,
+) (int64, error) {
+	ctx, done := observe(ctx, "EnqueueWebhookDeliveries")
+	defer done()
+
+	tag, err := (*pgConn).Exec(ctx,
+		"INSERT INTO webhook_deliveries (webhook_id, event, payload, status)"+
+			" SELECT id, $2, $3, 'PENDING' FROM webhooks WHERE username = $1",
+		username, event, payload)
+	if err != nil {
+		return 0, failed(ctx, fmt.Errorf("failed to insert into webhook_deliveries: %w", err))
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// ClaimWebhookDeliveries returns up to limit due deliveries with URLs and secrets of their webhooks,
+// the deliveries are not due again for lease, so they are retried if the instance dies meanwhile.
+func ClaimWebhookDeliveries(
+	ctx context.Context, pgConn *PgxIface, limit int, lease time.Duration,
+) ([]webhook.Delivery, error) {
+	ctx, done := observe(ctx, "ClaimWebhookDeliveries")
+	defer done()
+
+	result := make([]webhook.Delivery, 0)
+	rows, err := (*pgConn).Query(ctx, claimWebhookDeliveriesSQL, limit, lease.Seconds())
+	if err != nil {
+		return result, failed(ctx, fmt.Errorf("failed to claim webhook deliveries: %w", err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		delivery := webhook.Delivery{Status: webhook.DeliveryStatusPending} //nolint:exhaustruct
+		err = rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.Event, &delivery.Payload,
+			&delivery.Attempts, &delivery.CreatedAt, &delivery.URL, &delivery.Secret)
+		if err != nil {
+			return result, failed(ctx, fmt.Errorf("failed to scan a row: %w", err))
+		}
+		result = append(result, delivery)
+	}
+
+	return result, nil
+}
+
+// MarkWebhookDelivered marks the delivery as delivered with the status code of the response.
+func MarkWebhookDelivered(ctx context.Context, pgConn *PgxIface, id int64, statusCode int) error {
+	ctx, done := observe(ctx, "MarkWebhookDelivered")
+	defer done()
+
+	_, err := (*pgConn).Exec(ctx,
+		"UPDATE webhook_deliveries SET status = 'DELIVERED', attempts = attempts + 1,"+
+			" last_status_code = $2, last_error = '', delivered_at = now() WHERE id = $1",
+		id, statusCode)
+	if err != nil {
+		return failed(ctx, fmt.Errorf("failed to update webhook_deliveries: %w", err))
+	}
+
+	return nil
+}
+
+// RetryWebhookDelivery records the failed attempt and schedules the next one.
+func RetryWebhookDelivery(
+	ctx context.Context, pgConn *PgxIface, id int64, statusCode int, lastError string, nextAttemptAt time.Time,
+) error {
+	ctx, done := observe(ctx, "RetryWebhookDelivery")
+	defer done()
+
+	_, err := (*pgConn).Exec(ctx,
+		"UPDATE webhook_deliveries SET attempts = attempts + 1,"+
+			" last_status_code = $2, last_error = $3, next_attempt_at = $4 WHERE id = $1",
+		id, statusCode, lastError, nextAttemptAt)
+	if err != nil {
+		return failed(ctx, fmt.Errorf("failed to update webhook_deliveries: %w", err))
+	}
+
+	return nil
+}
+
+// DeadLetterWebhookDelivery records the last failed attempt and moves the delivery to the dead letters.
+func DeadLetterWebhookDelivery(
+	ctx context.Context, pgConn *PgxIface, id int64, statusCode int, lastError string,
+) error {
+	ctx, done := observe(ctx, "DeadLetterWebhookDelivery")
+	defer done()
+
+	_, err := (*pgConn).Exec(ctx, deadLetterWebhookDeliverySQL, id, statusCode, lastError)
+	if err != nil {
+		return failed(ctx, fmt.Errorf("failed to insert into webhook_dead_letters: %w", err))
+	}
+
+	return nil
+}
+
+// FindWebhookDeliveries returns up to limit latest deliveries of the webhook of the user.
+func FindWebhookDeliveries(
+	ctx context.Context, pgConn *PgxIface, username string, webhookID int64, limit int,
+) ([]webhook.Delivery, error) {
+	ctx, done := observe(ctx, "FindWebhookDeliveries")
+	defer done()
+
+	result := make([]webhook.Delivery, 0)
+	rows, err := (*pgConn).Query(ctx,
+		"SELECT d.id, d.event, d.status, d.attempts, d.last_status_code, d.last_error,"+
+			" d.created_at, d.next_attempt_at, d.delivered_at"+
+			" FROM webhook_deliveries AS d JOIN webhooks AS w ON w.id = d.webhook_id"+
+			" WHERE w.id = $1 AND w.username = $2 ORDER BY d.id DESC LIMIT $3",
+		webhookID, username, limit)
+	if err != nil {
+		return result, failed(ctx, fmt.Errorf("failed to query: %w", err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		delivery := webhook.Delivery{WebhookID: webhookID} //nolint:exhaustruct
+		var nextAttemptAt time.Time
+		err = rows.Scan(&delivery.ID, &delivery.Event, &delivery.Status, &delivery.Attempts,
+			&delivery.LastStatusCode, &delivery.LastError,
+			&delivery.CreatedAt, &nextAttemptAt, &delivery.DeliveredAt)
+		if err != nil {
+			return result, failed(ctx, fmt.Errorf("failed to scan a row: %w", err))
+		}
+		if delivery.Status == webhook.DeliveryStatusPending {
+			delivery.NextAttemptAt = &nextAttemptAt
+		}
+		result = append(result, delivery)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,175 @@
+package sqldb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/webhook"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddWebhook(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	createdAt := time.Now()
+	mock.ExpectQuery("INSERT INTO webhooks").
+		WithArgs("user1", "https://example.com/hook", "secret").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "created_at"}).AddRow(int64(3), createdAt))
+	mock.ExpectQuery("INSERT INTO webhooks").
+		WithArgs("user1", "https://example.com/hook", "secret").
+		WillReturnError(pgx.ErrNoRows)
+	mock.ExpectQuery("INSERT INTO webhooks").
+		WithArgs("user1", "https://example.com/hook", "secret").
+		WillReturnError(pgx.ErrTxClosed)
+
+	hook := &webhook.Webhook{URL: "https://example.com/hook", Secret: "secret", Username: "user1"} //nolint:exhaustruct
+	added, err := AddWebhook(context.Background(), &pgConn, hook)
+	require.NoError(t, err)
+	assert.True(t, added)
+	assert.Equal(t, int64(3), hook.ID)
+	assert.Equal(t, createdAt, hook.CreatedAt)
+
+	added, err = AddWebhook(context.Background(), &pgConn, hook)
+	require.NoError(t, err)
+	assert.False(t, added)
+
+	_, err = AddWebhook(context.Background(), &pgConn, hook)
+	assert.ErrorIs(t, err, pgx.ErrTxClosed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindWebhooksByUsername(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	createdAt := time.Now()
+	mock.ExpectQuery("SELECT id, url, created_at FROM webhooks WHERE username = \\$1").
+		WithArgs("user1").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "url", "created_at"}).
+			AddRow(int64(3), "https://example.com/hook", createdAt))
+
+	got, err := FindWebhooksByUsername(context.Background(), &pgConn, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, []webhook.Webhook{
+		{ID: 3, URL: "https://example.com/hook", Secret: "", CreatedAt: createdAt, Username: "user1"},
+	}, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	mock.ExpectExec("DELETE FROM webhooks").WithArgs(int64(3), "user1").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec("DELETE FROM webhooks").WithArgs(int64(3), "user2").
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+
+	deleted, err := DeleteWebhook(context.Background(), &pgConn, "user1", 3)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = DeleteWebhook(context.Background(), &pgConn, "user2", 3)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnqueueWebhookDeliveries(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	mock.ExpectExec("INSERT INTO webhook_deliveries \\(webhook_id, event, payload, status\\)").
+		WithArgs("user1", "order.updated", "{}").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectExec("INSERT INTO webhook_deliveries").
+		WithArgs("user1", "order.updated", "{}").
+		WillReturnError(pgx.ErrTxClosed)
+
+	added, err := EnqueueWebhookDeliveries(context.Background(), &pgConn, "user1", "order.updated", "{}")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), added)
+	_, err = EnqueueWebhookDeliveries(context.Background(), &pgConn, "user1", "order.updated", "{}")
+	assert.ErrorIs(t, err, pgx.ErrTxClosed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimWebhookDeliveries(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	createdAt := time.Now()
+	mock.ExpectQuery("FOR UPDATE SKIP LOCKED").
+		WithArgs(32, 30.0).
+		WillReturnRows(pgxmock.NewRows([]string{
+			"id", "webhook_id", "event", "payload", "attempts", "created_at", "url", "secret",
+		}).AddRow(int64(7), int64(3), "order.updated", "{}", 1, createdAt, "https://example.com/hook", "secret"))
+
+	got, err := ClaimWebhookDeliveries(context.Background(), &pgConn, 32, 30*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, []webhook.Delivery{{ //nolint:exhaustruct
+		ID: 7, WebhookID: 3, Event: "order.updated", Status: webhook.DeliveryStatusPending, Attempts: 1,
+		CreatedAt: createdAt, Payload: "{}", URL: "https://example.com/hook", Secret: "secret",
+	}}, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordWebhookAttempts(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	nextAttemptAt := time.Now().Add(time.Minute)
+	mock.ExpectExec("SET status = 'DELIVERED'").WithArgs(int64(7), 200).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("next_attempt_at = \\$4").WithArgs(int64(8), 500, "unexpected status: 500", nextAttemptAt).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec("INSERT INTO webhook_dead_letters").WithArgs(int64(9), 0, "timeout").
+		WillReturnError(pgx.ErrTxClosed)
+
+	require.NoError(t, MarkWebhookDelivered(context.Background(), &pgConn, 7, 200))
+	require.NoError(t, RetryWebhookDelivery(context.Background(), &pgConn, 8, 500, "unexpected status: 500",
+		nextAttemptAt))
+	err = DeadLetterWebhookDelivery(context.Background(), &pgConn, 9, 0, "timeout")
+	assert.ErrorIs(t, err, pgx.ErrTxClosed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindWebhookDeliveries(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	createdAt := time.Now()
+	nextAttemptAt := createdAt.Add(time.Minute)
+	deliveredAt := createdAt.Add(time.Second)
+	mock.ExpectQuery("FROM webhook_deliveries AS d JOIN webhooks AS w").
+		WithArgs(int64(3), "user1", 100).
+		WillReturnRows(pgxmock.NewRows([]string{
+			"id", "event", "status", "attempts", "last_status_code", "last_error",
+			"created_at", "next_attempt_at", "delivered_at",
+		}).
+			AddRow(int64(8), "withdrawal.created", "PENDING", 1, 500, "unexpected status: 500",
+				createdAt, nextAttemptAt, nil).
+			AddRow(int64(7), "order.updated", "DELIVERED", 1, 200, "", createdAt, createdAt, &deliveredAt))
+
+	got, err := FindWebhookDeliveries(context.Background(), &pgConn, "user1", 3, 100)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, &nextAttemptAt, got[0].NextAttemptAt)
+	assert.Nil(t, got[0].DeliveredAt)
+	assert.Equal(t, "unexpected status: 500", got[0].LastError)
+	assert.Nil(t, got[1].NextAttemptAt)
+	assert.Equal(t, &deliveredAt, got[1].DeliveredAt)
+	assert.Equal(t, int64(3), got[1].WebhookID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,154 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/events"
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/jackc/pgx/v5"
+)
+
+// cancelWithdrawSQL cancels the latest pending withdrawal of the user for the order
+// made after $3, its sum is returned to the balance by a compensating ledger transaction.
+const cancelWithdrawSQL = `
+UPDATE withdraws SET status = 'CANCELLED', updated_at = now()
+WHERE id = (
+    SELECT id FROM withdraws
+    WHERE username = $1 AND number = $2 AND status = 'PENDING' AND processed_at > $3
+    ORDER BY id DESC
+    LIMIT 1
+    FOR UPDATE
+)
+RETURNING id, number, sum, processed_at, status, username`
+
+// refundWithdrawSQL marks the latest pending or completed withdrawal for the order as refunded.
+const refundWithdrawSQL = `
+UPDATE withdraws SET status = 'REFUNDED', updated_at = now()
+WHERE id = (
+    SELECT id FROM withdraws
+    WHERE number = $1 AND status IN ('PENDING', 'COMPLETED')
+    ORDER BY id DESC
+    LIMIT 1
+    FOR UPDATE
+)
+RETURNING id, number, sum, processed_at, status, username`
+
+// CancelWithdraw cancels the pending withdrawal of the user for the order if it was made after cancellableAfter,
+// the change is written to the outbox in the same transaction.
+// It returns nil if there is no such withdrawal.
+func CancelWithdraw(
+	ctx context.Context, pgConn *PgxIface, username string, number string, cancellableAfter time.Time,
+) (*accrual.WithdrawExt, error) {
+	ctx, done := observe(ctx, "CancelWithdraw")
+	defer done()
+
+	var cancelled *accrual.WithdrawExt
+	err := inTx(ctx, pgConn, func(tx pgx.Tx) error {
+		withdraw, err := scanWithdraw(tx.QueryRow(ctx, cancelWithdrawSQL, username, number, cancellableAfter))
+		if err != nil || withdraw == nil {
+			return err
+		}
+		if err = insertLedger(ctx, tx, withdrawLedger(withdraw, accrual.LedgerKindWithdrawCancel, "")); err != nil {
+			return err
+		}
+		cancelled = withdraw
+
+		return insertOutbox(ctx, tx, events.TypeWithdrawalCancelled, withdraw.Username, withdraw)
+	})
+	if err != nil {
+		return nil, failed(ctx, err)
+	}
+
+	return cancelled, nil
+}
+
+// RefundWithdraw refunds the pending or completed withdrawal for the order:
+// it is marked as refunded and its sum is returned to the user by a compensating ledger transaction,
+// the change is written to the outbox in the same transaction.
+// It returns nil if there is no such withdrawal.
+func RefundWithdraw(ctx context.Context, pgConn *PgxIface, number string, reason string) (*accrual.WithdrawExt, error) {
+	ctx, done := observe(ctx, "RefundWithdraw")
+	defer done()
+
+	var refunded *accrual.WithdrawExt
+	err := inTx(ctx, pgConn, func(tx pgx.Tx) error {
+		withdraw, err := scanWithdraw(tx.QueryRow(ctx, refundWithdrawSQL, number))
+		if err != nil || withdraw == nil {
+			return err
+		}
+		_, err = tx.Exec(ctx,
+			"INSERT INTO withdraw_refunds (withdraw_id, username, sum, reason)"+
+				" SELECT id, username, sum, $2 FROM withdraws WHERE id = $1",
+			withdraw.ID, reason)
+		if err != nil {
+			return fmt.Errorf("failed to insert into withdraw_refunds: %w", err)
+		}
+		if err = insertLedger(ctx, tx, withdrawLedger(withdraw, accrual.LedgerKindWithdrawRefund, reason)); err != nil {
+			return err
+		}
+		refunded = withdraw
+
+		return insertOutbox(ctx, tx, events.TypeWithdrawalRefunded, withdraw.Username, withdraw)
+	})
+	if err != nil {
+		return nil, failed(ctx, err)
+	}
+
+	return refunded, nil
+}
+
+// FindLastWithdraw returns the latest withdrawal for the order,
+// it is looked up among the withdrawals of the user unless username is empty.
+// It returns nil if there is no such withdrawal.
+func FindLastWithdraw(
+	ctx context.Context, pgConn *PgxIface, username string, number string,
+) (*accrual.WithdrawExt, error) {
+	ctx, done := observe(ctx, "FindLastWithdraw")
+	defer done()
+
+	withdraw, err := scanWithdraw((*pgConn).QueryRow(ctx,
+		"SELECT id, number, sum, processed_at, status, username FROM withdraws"+
+			" WHERE number = $1 AND ($2 = '' OR username = $2) ORDER BY id DESC LIMIT 1",
+		number, username))
+	if err != nil {
+		return nil, failed(ctx, err)
+	}
+
+	return withdraw, nil
+}
+
+// CompleteWithdraws completes the pending withdrawals made before, they may not be cancelled anymore.
+func CompleteWithdraws(ctx context.Context, pgConn *PgxIface, before time.Time) (int64, error) {
+	ctx, done := observe(ctx, "CompleteWithdraws")
+	defer done()
+
+	tag, err := (*pgConn).Exec(ctx,
+		"UPDATE withdraws SET status = 'COMPLETED', updated_at = now() WHERE status = 'PENDING' AND processed_at <= $1",
+		before)
+	if err != nil {
+		return 0, failed(ctx, fmt.Errorf("failed to complete withdraws: %w", err))
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// scanWithdraw returns nil if there is no row.
+func scanWithdraw(row pgx.Row) (*accrual.WithdrawExt, error) {
+	var scanned *accrual.WithdrawExt
+	var withdraw accrual.WithdrawExt
+	err := row.Scan(&withdraw.ID, &withdraw.Order, &withdraw.Sum, &withdraw.ProcessedAt, &withdraw.Status,
+		&withdraw.Username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return scanned, nil
+		}
+
+		return nil, fmt.Errorf("failed to scan a row: %w", err)
+	}
+	scanned = &withdraw
+
+	return scanned, nil
+}
@@ -0,0 +1,127 @@
+package sqldb
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/model/accrual"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var withdrawColumns = []string{"id", "number", "sum", "processed_at", "status", "username"}
+
+func TestCancelWithdraw(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	processedAt := time.Now()
+	after := processedAt.Add(-time.Minute)
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE withdraws SET status = 'CANCELLED'").WithArgs("user1", "79927398713", after).
+		WillReturnRows(pgxmock.NewRows(withdrawColumns).
+			AddRow(int64(5), "79927398713", float32(10), processedAt, accrual.WithdrawStatusCancelled, "user1"))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("WITHDRAWALS", "user1", "USER", "user1", float32(10), "WITHDRAWAL_CANCEL", "withdraw", "5", "").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs("withdrawal.cancelled", "user1", pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE withdraws SET status = 'CANCELLED'").WithArgs("user1", "79927398713", after).
+		WillReturnRows(pgxmock.NewRows(withdrawColumns))
+	mock.ExpectCommit()
+
+	withdraw, err := CancelWithdraw(context.Background(), &pgConn, "user1", "79927398713", after)
+	require.NoError(t, err)
+	require.NotNil(t, withdraw)
+	assert.Equal(t, int64(5), withdraw.ID)
+	assert.Equal(t, accrual.WithdrawStatusCancelled, withdraw.Status)
+
+	withdraw, err = CancelWithdraw(context.Background(), &pgConn, "user1", "79927398713", after)
+	require.NoError(t, err)
+	assert.Nil(t, withdraw)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefundWithdraw(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE withdraws SET status = 'REFUNDED'").WithArgs("79927398713").
+		WillReturnRows(pgxmock.NewRows(withdrawColumns).
+			AddRow(int64(5), "79927398713", float32(10), time.Now(), accrual.WithdrawStatusRefunded, "user1"))
+	mock.ExpectExec("INSERT INTO withdraw_refunds").WithArgs(int64(5), "fraud").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs("WITHDRAWALS", "user1", "USER", "user1", float32(10), "WITHDRAWAL_REFUND", "withdraw", "5", "fraud").
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs("withdrawal.refunded", "user1", pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE withdraws SET status = 'REFUNDED'").WithArgs("79927398713").
+		WillReturnRows(pgxmock.NewRows(withdrawColumns).
+			AddRow(int64(5), "79927398713", float32(10), time.Now(), accrual.WithdrawStatusRefunded, "user1"))
+	mock.ExpectExec("INSERT INTO withdraw_refunds").WithArgs(int64(5), "fraud").WillReturnError(io.EOF)
+	mock.ExpectRollback()
+
+	withdraw, err := RefundWithdraw(context.Background(), &pgConn, "79927398713", "fraud")
+	require.NoError(t, err)
+	require.NotNil(t, withdraw)
+	assert.Equal(t, "user1", withdraw.Username)
+
+	_, err = RefundWithdraw(context.Background(), &pgConn, "79927398713", "fraud")
+	assert.ErrorIs(t, err, io.EOF)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindLastWithdraw(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	mock.ExpectQuery("SELECT id, number, sum, processed_at, status, username FROM withdraws").
+		WithArgs("79927398713", "").
+		WillReturnRows(pgxmock.NewRows(withdrawColumns).
+			AddRow(int64(5), "79927398713", float32(10), time.Now(), accrual.WithdrawStatusCompleted, "user1"))
+	mock.ExpectQuery("SELECT id, number, sum, processed_at, status, username FROM withdraws").
+		WithArgs("79927398713", "user2").
+		WillReturnRows(pgxmock.NewRows(withdrawColumns))
+
+	withdraw, err := FindLastWithdraw(context.Background(), &pgConn, "", "79927398713")
+	require.NoError(t, err)
+	require.NotNil(t, withdraw)
+	assert.Equal(t, accrual.WithdrawStatusCompleted, withdraw.Status)
+
+	withdraw, err = FindLastWithdraw(context.Background(), &pgConn, "user2", "79927398713")
+	require.NoError(t, err)
+	assert.Nil(t, withdraw)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCompleteWithdraws(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	var pgConn PgxIface = mock
+
+	before := time.Now()
+	mock.ExpectExec("UPDATE withdraws SET status = 'COMPLETED'").WithArgs(before).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+	mock.ExpectExec("UPDATE withdraws SET status = 'COMPLETED'").WithArgs(before).
+		WillReturnError(io.EOF)
+
+	completed, err := CompleteWithdraws(context.Background(), &pgConn, before)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), completed)
+
+	_, err = CompleteWithdraws(context.Background(), &pgConn, before)
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,116 @@
+package tlscert
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Reloader serves a certificate from cert/key files and reloads it
+// when the files change, so a renewed certificate is used without a restart.
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewReloader loads the certificate from the files.
+func NewReloader(certFile string, keyFile string) (*Reloader, error) {
+	reloader := &Reloader{certFile: certFile, keyFile: keyFile, mu: sync.RWMutex{}, cert: nil, modTime: time.Time{}}
+	if err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+// GetCertificate is suitable for tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+// Reload loads the certificate from the files,
+// the current certificate is kept when the files are broken.
+func (r *Reloader) Reload() error {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mu.Unlock()
+
+	return nil
+}
+
+// ReloadIfChanged reloads the certificate when any of the files has been modified
+// since the last load, it reports whether the certificate has been reloaded.
+func (r *Reloader) ReloadIfChanged() (bool, error) {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	changed := !modTime.Equal(r.modTime)
+	r.mu.RUnlock()
+	if !changed {
+		return false, nil
+	}
+	if err = r.Reload(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Watch checks the files every interval until ctx is done.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.ReloadIfChanged()
+			if err != nil {
+				zap.S().Warnf("tlscert: %s", err.Error())
+			} else if reloaded {
+				zap.S().Infof("tlscert: certificate %s has been reloaded", r.certFile)
+			}
+		}
+	}
+}
+
+// filesModTime returns the latest modification time of the files.
+func (r *Reloader) filesModTime() (time.Time, error) {
+	var result time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to stat certificate file: %w", err)
+		}
+		if info.ModTime().After(result) {
+			result = info.ModTime()
+		}
+	}
+
+	return result, nil
+}
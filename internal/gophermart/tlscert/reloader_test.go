@@ -0,0 +1,128 @@
+package tlscert_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/tlscert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert writes a self-signed certificate for commonName and returns paths of cert and key files.
+func writeCert(t *testing.T, dir string, commonName string, modTime time.Time) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{ //nolint:exhaustruct
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName}, //nolint:exhaustruct
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+
+	return certFile, keyFile
+}
+
+func commonName(t *testing.T, reloader *tlscert.Reloader) string {
+	t.Helper()
+	cert, err := reloader.GetCertificate(&tls.ClientHelloInfo{}) //nolint:exhaustruct
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+
+	return leaf.Subject.CommonName
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Hour)
+	certFile, keyFile := writeCert(t, dir, "first", start)
+
+	reloader, err := tlscert.NewReloader(certFile, keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, "first", commonName(t, reloader))
+
+	reloaded, err := reloader.ReloadIfChanged()
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+
+	writeCert(t, dir, "second", start.Add(time.Minute))
+	reloaded, err = reloader.ReloadIfChanged()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "second", commonName(t, reloader))
+}
+
+func TestReloaderKeepsCertOnBrokenFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "first", time.Now().Add(-time.Hour))
+	reloader, err := tlscert.NewReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, []byte("broken"), 0o600))
+	_, err = reloader.ReloadIfChanged()
+	assert.Error(t, err)
+	assert.Equal(t, "first", commonName(t, reloader))
+
+	require.NoError(t, os.Remove(keyFile))
+	assert.Error(t, reloader.Reload())
+	assert.Equal(t, "first", commonName(t, reloader))
+}
+
+func TestNewReloaderErr(t *testing.T) {
+	dir := t.TempDir()
+	_, err := tlscert.NewReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	assert.Error(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	require.NoError(t, os.WriteFile(certFile, []byte("broken"), 0o600))
+	_, err = tlscert.NewReloader(certFile, certFile)
+	assert.Error(t, err)
+}
+
+func TestReloaderWatch(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Hour)
+	certFile, keyFile := writeCert(t, dir, "first", start)
+	reloader, err := tlscert.NewReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		reloader.Watch(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	writeCert(t, dir, "second", start.Add(time.Minute))
+	assert.Eventually(t, func() bool {
+		return commonName(t, reloader) == "second"
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,133 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName is a name of the service in traces.
+const ServiceName = "gophermart"
+
+// Supported exporters of spans.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterOTLP   = "otlp"
+)
+
+const instrumentationName = "github.com/DimaKoz/go-musthave-diploma-impl"
+
+var (
+	ErrUnknownExporter = errors.New("unknown trace exporter")
+	errNoTraceFile     = errors.New("trace file is empty")
+)
+
+// Shutdown flushes and stops the exporter.
+type Shutdown func(ctx context.Context) error
+
+// Setup sets up the global tracer provider and propagator,
+// exporter is one of ExporterNone, ExporterStdout, ExporterFile or ExporterOTLP,
+// endpoint (host:port) is used by ExporterOTLP and file is used by ExporterFile.
+func Setup(exporter string, endpoint string, file string) (Shutdown, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	spanExporter, closer, err := newExporter(exporter, endpoint, file)
+	if err != nil {
+		return nil, err
+	}
+	if spanExporter == nil {
+		return func(ctx context.Context) error { return nil }, nil
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName)))
+	if err != nil {
+		res = resource.Default()
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			_ = closer.Close()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to shutdown tracer provider: %w", err)
+		}
+
+		return nil
+	}, nil
+}
+
+func newExporter(exporter string, endpoint string, file string) (sdktrace.SpanExporter, io.Closer, error) {
+	switch exporter {
+	case "", ExporterNone:
+		return nil, nil, nil
+	case ExporterStdout:
+		spanExporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create stdout exporter: %w", err)
+		}
+
+		return spanExporter, nil, nil
+	case ExporterFile:
+		if file == "" {
+			return nil, nil, errNoTraceFile
+		}
+		traceFile, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) //nolint:gomnd
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		spanExporter, err := stdouttrace.New(stdouttrace.WithWriter(traceFile))
+		if err != nil {
+			_ = traceFile.Close()
+
+			return nil, nil, fmt.Errorf("failed to create file exporter: %w", err)
+		}
+
+		return spanExporter, traceFile, nil
+	case ExporterOTLP:
+		opts := make([]otlptracehttp.Option, 0)
+		if endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(endpoint))
+		}
+		spanExporter, err := otlptracehttp.New(context.Background(), opts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create otlp exporter: %w", err)
+		}
+
+		return spanExporter, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnknownExporter, exporter)
+	}
+}
+
+// Tracer returns the tracer of the service.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Detach returns a new context which carries the trace of ctx,
+// but it is not canceled when ctx is.
+// Use it to continue the trace in background work started by a request.
+func Detach(ctx context.Context) context.Context {
+	return trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+}
@@ -0,0 +1,81 @@
+package tracing_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DimaKoz/go-musthave-diploma-impl/internal/gophermart/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetup(t *testing.T) {
+	original := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(original) })
+
+	traceFile := filepath.Join(t.TempDir(), "trace.json")
+	tests := []struct {
+		name     string
+		exporter string
+		file     string
+		wantErr  bool
+	}{
+		{name: "default", exporter: ""},
+		{name: "none", exporter: tracing.ExporterNone},
+		{name: "stdout", exporter: tracing.ExporterStdout},
+		{name: "file", exporter: tracing.ExporterFile, file: traceFile},
+		{name: "file without path", exporter: tracing.ExporterFile, wantErr: true},
+		{name: "file bad path", exporter: tracing.ExporterFile, file: t.TempDir(), wantErr: true},
+		{name: "otlp", exporter: tracing.ExporterOTLP},
+		{name: "unknown", exporter: "zipkin", wantErr: true},
+	}
+	for _, tt := range tests {
+		test := tt
+		t.Run(test.name, func(t *testing.T) {
+			shutdown, err := tracing.Setup(test.exporter, "127.0.0.1:4318", test.file)
+			if test.wantErr {
+				assert.Error(t, err)
+
+				return
+			}
+			require.NoError(t, err)
+			assert.NoError(t, shutdown(context.Background()))
+		})
+	}
+}
+
+func TestSetupFileWritesSpans(t *testing.T) {
+	original := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(original) })
+
+	traceFile := filepath.Join(t.TempDir(), "trace.json")
+	shutdown, err := tracing.Setup(tracing.ExporterFile, "", traceFile)
+	require.NoError(t, err)
+
+	_, span := tracing.Tracer().Start(context.Background(), "test-span")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	got, err := os.ReadFile(traceFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(got), "test-span")
+	assert.Contains(t, string(got), tracing.ServiceName)
+}
+
+func TestDetach(t *testing.T) {
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{ //nolint:exhaustruct
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})
+	ctx, cancel := context.WithCancel(trace.ContextWithSpanContext(context.Background(), spanCtx))
+	cancel()
+
+	got := tracing.Detach(ctx)
+	assert.NoError(t, got.Err())
+	assert.Equal(t, spanCtx.TraceID(), trace.SpanContextFromContext(got).TraceID())
+	assert.Equal(t, spanCtx.SpanID(), trace.SpanContextFromContext(got).SpanID())
+}